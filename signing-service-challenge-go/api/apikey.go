@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/google/uuid"
+)
+
+// GetAPIKeys lists all API keys including revoked ones. The keys themselves are never returned.
+func (s *Server) GetAPIKeys(response http.ResponseWriter, request *http.Request) {
+	keys, err := s.store().ReadAPIKeys(request.Context())
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "GetAPIKeys read keys", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, keys)
+}
+
+// PostAPIKey issues an API key. The key is only part of this response, afterwards just its hash is known.
+func (s *Server) PostAPIKey(response http.ResponseWriter, request *http.Request) {
+	payload := APIKeyRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostAPIKey decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if strings.TrimSpace(payload.Name) == "" {
+		writeError(response, request, http.StatusBadRequest, []string{
+			"name missing",
+		})
+		return
+	}
+	deviceIDs := make([]uuid.UUID, len(payload.DeviceIDs))
+	for i, id := range payload.DeviceIDs {
+		uid, err := uuid.Parse(id)
+		if err != nil || uid == uuid.Nil {
+			writeError(response, request, http.StatusBadRequest, []string{
+				"invalid device id",
+			})
+			return
+		}
+		deviceIDs[i] = uid
+	}
+
+	key, secret, err := domain.NewAPIKey(payload.Name, payload.Scopes, deviceIDs, s.Clock.Now())
+	if errors.Is(err, domain.ErrInvalidScope) {
+		writeError(response, request, http.StatusBadRequest, []string{
+			domain.ErrInvalidScope.Error(),
+		})
+		return
+	}
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "PostAPIKey new key", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+	if err := s.store().CreateAPIKey(request.Context(), key); err != nil {
+		s.Logger.ErrorContext(request.Context(), "PostAPIKey store key", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+	s.Logger.InfoContext(request.Context(), "api key issued", "key_id", key.ID, "name", key.Name, "scopes", key.Scopes)
+
+	writeResponse(response, request, http.StatusCreated, APIKeyResponse{APIKey: key, Key: secret})
+}
+
+// GetAPIKey returns a single API key.
+func (s *Server) GetAPIKey(response http.ResponseWriter, request *http.Request) {
+	key, err := s.store().ReadAPIKey(request.Context(), request.PathValue("id"))
+	if errors.Is(err, persistence.ErrNotFound) {
+		writeError(response, request, http.StatusNotFound, []string{
+			http.StatusText(http.StatusNotFound),
+		})
+		return
+	}
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "GetAPIKey read key", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, key)
+}
+
+// DeleteAPIKey revokes an API key. Requests with the key are rejected afterwards; the key stays listed.
+func (s *Server) DeleteAPIKey(response http.ResponseWriter, request *http.Request) {
+	key, err := s.store().RevokeAPIKey(request.Context(), request.PathValue("id"), s.Clock.Now())
+	if errors.Is(err, persistence.ErrNotFound) {
+		writeError(response, request, http.StatusNotFound, []string{
+			http.StatusText(http.StatusNotFound),
+		})
+		return
+	}
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "DeleteAPIKey revoke key", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+	s.Logger.InfoContext(request.Context(), "api key revoked", "key_id", key.ID, "name", key.Name)
+
+	writeResponse(response, request, http.StatusOK, key)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	const adminKey = "admin-key-for-tests"
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	otherDeviceID := "1727d3e0-e1ae-410c-97d2-70da0ae0abc4"
+
+	var logs logBuffer
+	s := NewServer("")
+	s.Storer = getStorerWithData(t)
+	logger, err := NewLogger(&logs, LogFormatJSON, slog.LevelInfo)
+	require.Nil(t, err)
+	s.Logger = logger
+	s.RequireAPIKey = true
+	s.AdminKey = adminKey
+	handler := s.Handler()
+
+	// do sends the request to handler with key as bearer token, if not empty.
+	do := func(t *testing.T, key string, method string, url string, payload interface{}, v interface{}) *httptest.ResponseRecorder {
+		r := newJSONRequest(t, method, url, payload)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		return serve(t, handler, r, v)
+	}
+	issue := func(t *testing.T, request APIKeyRequest) APIKeyResponse {
+		issued := APIKeyResponse{}
+		require.Equal(t, http.StatusCreated, do(t, adminKey, "POST", "/api/v1/keys", request, &issued).Code)
+		return issued
+	}
+	sign := func(t *testing.T, key string, id string) int {
+		return do(t, key, "POST", "/api/v1/devices/"+id+"/signatures:batch", BatchSignatureRequest{ClientID: testClientID, Data: []string{"a"}}, nil).Code
+	}
+
+	t.Run("public routes", func(t *testing.T) {
+		for _, url := range []string{"/livez", "/readyz", "/metrics", "/api/v0/health", "/api/v1/tsa/certificate"} {
+			assert.Less(t, do(t, "", "GET", url, nil, nil).Code, 300, url)
+		}
+		assert.Equal(t, http.StatusNotFound, do(t, "", "GET", "/api/v1/unknown", nil, nil).Code)
+	})
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := do(t, "", "GET", "/api/v1/devices", nil, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, http.StatusUnauthorized, do(t, "sk_unknown", "GET", "/api/v1/devices", nil, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, sign(t, "", deviceID))
+		assert.Equal(t, http.StatusUnauthorized, do(t, "", "POST", "/api/v0/devices/sign", SignatureRequest{ID: deviceID, ClientID: testClientID, Data: "a"}, nil).Code)
+	})
+	t.Run("issue", func(t *testing.T) {
+		issued := issue(t, APIKeyRequest{Name: "pos", Scopes: []domain.Scope{domain.ScopeSign}})
+		assert.True(t, strings.HasPrefix(issued.Key, "sk_"))
+		assert.Equal(t, "pos", issued.Name)
+
+		read := do(t, adminKey, "GET", "/api/v1/keys/"+issued.ID, nil, nil)
+		require.Equal(t, http.StatusOK, read.Code)
+		assert.NotContains(t, read.Body.String(), issued.Key, "the key is only returned once")
+		assert.NotContains(t, read.Body.String(), domain.HashAPIKey(issued.Key))
+
+		keys := []domain.APIKey{}
+		require.Equal(t, http.StatusOK, do(t, adminKey, "GET", "/api/v1/keys", nil, &keys).Code)
+		assert.NotEmpty(t, keys)
+		assert.Equal(t, http.StatusNotFound, do(t, adminKey, "GET", "/api/v1/keys/"+uuid.NewString(), nil, nil).Code)
+	})
+	t.Run("issue invalid", func(t *testing.T) {
+		for name, request := range map[string]APIKeyRequest{
+			"no name":           {Scopes: []domain.Scope{domain.ScopeSign}},
+			"no scopes":         {Name: "pos"},
+			"unknown scope":     {Name: "pos", Scopes: []domain.Scope{"devices:delete"}},
+			"invalid device id": {Name: "pos", Scopes: []domain.Scope{domain.ScopeSign}, DeviceIDs: []string{"invalid"}},
+		} {
+			assert.Equal(t, http.StatusBadRequest, do(t, adminKey, "POST", "/api/v1/keys", request, nil).Code, name)
+		}
+	})
+	t.Run("scopes", func(t *testing.T) {
+		signer := issue(t, APIKeyRequest{Name: "signer", Scopes: []domain.Scope{domain.ScopeSign}}).Key
+		assert.Equal(t, http.StatusOK, sign(t, signer, deviceID))
+		w := do(t, signer, "GET", "/api/v1/devices", nil, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), string(domain.ScopeDevicesRead))
+		assert.Equal(t, http.StatusForbidden, do(t, signer, "POST", "/api/v1/devices", SignatureDeviceRequest{ID: uuid.NewString(), Algorithm: "ECDSA"}, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do(t, signer, "DELETE", "/api/v1/devices/"+deviceID, nil, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do(t, signer, "GET", "/api/v1/keys", nil, nil).Code)
+
+		reader := issue(t, APIKeyRequest{Name: "reader", Scopes: []domain.Scope{domain.ScopeDevicesRead}}).Key
+		assert.Equal(t, http.StatusOK, do(t, reader, "GET", "/api/v1/devices/"+deviceID, nil, nil).Code)
+		assert.Equal(t, http.StatusForbidden, sign(t, reader, deviceID))
+
+		admin := issue(t, APIKeyRequest{Name: "admin", Scopes: []domain.Scope{domain.ScopeAdmin}}).Key
+		assert.Equal(t, http.StatusOK, sign(t, admin, deviceID), "admin permits everything")
+		assert.Equal(t, http.StatusOK, do(t, admin, "GET", "/api/v1/keys", nil, nil).Code)
+	})
+	t.Run("device restriction", func(t *testing.T) {
+		key := issue(t, APIKeyRequest{
+			Name:      "restricted",
+			Scopes:    []domain.Scope{domain.ScopeSign, domain.ScopeDevicesRead, domain.ScopeDevicesCreate},
+			DeviceIDs: []string{deviceID},
+		})
+		assert.Equal(t, []uuid.UUID{uuid.MustParse(deviceID)}, key.DeviceIDs)
+
+		assert.Equal(t, http.StatusOK, sign(t, key.Key, deviceID))
+		w := do(t, key.Key, "POST", "/api/v1/devices/"+otherDeviceID+"/signatures:batch", BatchSignatureRequest{ClientID: testClientID, Data: []string{"a"}}, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), errDeviceNotAllowed)
+
+		devices := []domain.DeviceInfo{}
+		require.Equal(t, http.StatusOK, do(t, key.Key, "GET", "/api/v1/devices", nil, &devices).Code)
+		require.Len(t, devices, 1)
+		assert.Equal(t, deviceID, devices[0].ID.String())
+
+		result := BulkSignatureResponse{}
+		payload := BulkSignatureRequest{Items: []BulkSignatureItem{
+			{DeviceID: deviceID, ClientID: testClientID, Data: "a"},
+			{DeviceID: otherDeviceID, ClientID: testClientID, Data: "a"},
+		}}
+		require.Equal(t, http.StatusOK, do(t, key.Key, "POST", "/api/v1/signatures:bulk", payload, &result).Code)
+		assert.Empty(t, result.Results[0].Error)
+		assert.Equal(t, errDeviceNotAllowed, result.Results[1].Error)
+
+		assert.Equal(t, http.StatusForbidden, do(t, key.Key, "POST", "/api/v1/devices", SignatureDeviceRequest{ID: uuid.NewString(), Algorithm: "ECDSA"}, nil).Code)
+	})
+	t.Run("revoke", func(t *testing.T) {
+		key := issue(t, APIKeyRequest{Name: "revoked", Scopes: []domain.Scope{domain.ScopeSign}})
+		require.Equal(t, http.StatusOK, sign(t, key.Key, deviceID))
+
+		revoked := domain.APIKey{}
+		require.Equal(t, http.StatusOK, do(t, adminKey, "DELETE", "/api/v1/keys/"+key.ID, nil, &revoked).Code)
+		assert.True(t, revoked.Revoked())
+		assert.Equal(t, http.StatusUnauthorized, sign(t, key.Key, deviceID))
+		assert.Equal(t, http.StatusNotFound, do(t, adminKey, "DELETE", "/api/v1/keys/"+uuid.NewString(), nil, nil).Code)
+	})
+	t.Run("log", func(t *testing.T) {
+		key := issue(t, APIKeyRequest{Name: "logged", Scopes: []domain.Scope{domain.ScopeSign}})
+		logs.Reset()
+		require.Equal(t, http.StatusOK, sign(t, key.Key, deviceID))
+		records := logRecords(t, &logs)
+		require.NotEmpty(t, records)
+		assert.Equal(t, key.ID, records[len(records)-1][logKeyAPIKey])
+		assert.NotContains(t, logs.String(), key.Key)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		s := NewServer("")
+		s.Storer = getStorerWithData(t)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/devices", nil))
+		assert.Equal(t, http.StatusOK, w.Code, "no key is required without RequireAPIKey")
+	})
+}
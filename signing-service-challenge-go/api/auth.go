@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scopePublic marks routes that can be called without API key.
+const scopePublic domain.Scope = ""
+
+// adminKeyID identifies Server.AdminKey in logs, it is never stored.
+const adminKeyID = "admin"
+
+const (
+	// errUnauthenticated is returned for requests without valid API key.
+	errUnauthenticated = "missing or invalid api key"
+	// errDeviceNotAllowed is returned for devices the API key is restricted from.
+	errDeviceNotAllowed = "device not allowed for api key"
+)
+
+// errInvalidAPIKey is returned by authenticate for missing, unknown and revoked API keys.
+var errInvalidAPIKey = errors.New("invalid api key")
+
+// routes registers handlers together with the scope API keys need to call them.
+type routes struct {
+	mux    *http.ServeMux
+	scopes map[string]domain.Scope
+}
+
+func (r routes) handle(pattern string, scope domain.Scope, handler http.Handler) {
+	r.mux.Handle(pattern, handler)
+	r.scopes[pattern] = scope
+}
+
+type apiKeyKey struct{}
+
+// apiKeyFrom returns the API key the request has been authenticated with.
+func apiKeyFrom(ctx context.Context) (domain.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(domain.APIKey)
+	return key, ok
+}
+
+// withAuth requires an API key with the scope of the route if Server.RequireAPIKey is set. Requests without
+// valid key are rejected with 401, keys without the scope with 403. Unknown routes are left to the mux.
+func (s *Server) withAuth(mux *http.ServeMux, scopes map[string]domain.Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		_, route := mux.Handler(request)
+		scope, ok := scopes[route]
+		if !s.RequireAPIKey || !ok || scope == scopePublic {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		key, err := s.authenticate(request)
+		if errors.Is(err, errInvalidAPIKey) {
+			s.Logger.WarnContext(request.Context(), "authenticate", "err", err)
+			response.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(response, request, http.StatusUnauthorized, []string{
+				errUnauthenticated,
+			})
+			return
+		}
+		if err != nil {
+			s.Logger.ErrorContext(request.Context(), "authenticate", "err", err)
+			writeError(response, request, http.StatusInternalServerError, []string{
+				http.StatusText(http.StatusInternalServerError),
+			})
+			return
+		}
+
+		annotateAPIKey(request.Context(), key.ID)
+		trace.SpanFromContext(request.Context()).SetAttributes(attribute.String("api_key.id", key.ID))
+		if !key.Permits(scope) {
+			writeError(response, request, http.StatusForbidden, []string{
+				fmt.Sprintf("api key lacks scope %s", scope),
+			})
+			return
+		}
+		next.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), apiKeyKey{}, key)))
+	})
+}
+
+// authenticate looks up the API key sent as bearer token. Server.AdminKey is accepted without lookup.
+func (s *Server) authenticate(request *http.Request) (domain.APIKey, error) {
+	token, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return domain.APIKey{}, fmt.Errorf("authenticate | no bearer token | %w", errInvalidAPIKey)
+	}
+	hash := domain.HashAPIKey(token)
+	if s.AdminKey != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(domain.HashAPIKey(s.AdminKey))) == 1 {
+		return domain.APIKey{ID: adminKeyID, Name: adminKeyID, Scopes: []domain.Scope{domain.ScopeAdmin}}, nil
+	}
+
+	key, err := s.store().ReadAPIKeyByHash(request.Context(), hash)
+	if errors.Is(err, persistence.ErrNotFound) {
+		return domain.APIKey{}, fmt.Errorf("authenticate | unknown key | %w", errInvalidAPIKey)
+	}
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("authenticate | %w", err)
+	}
+	if key.Revoked() {
+		return domain.APIKey{}, fmt.Errorf("authenticate | key: %s revoked | %w", key.ID, errInvalidAPIKey)
+	}
+	return key, nil
+}
+
+// deviceAccess returns why the caller of the request may not use the device, or an empty string if it may. The
+// device has to be owned by the identity of the caller, if it has an owner, and allowed by the API key. Keys
+// with the admin scope may use devices of all owners.
+func deviceAccess(ctx context.Context, sd *domain.SignatureDevice) string {
+	key, authenticated := apiKeyFrom(ctx)
+	if !sd.AccessibleBy(identityFrom(ctx)) && !(authenticated && key.Permits(domain.ScopeAdmin)) {
+		return errDeviceForbidden
+	}
+	if !apiKeyAllowsDevice(ctx, sd.ID) {
+		return errDeviceNotAllowed
+	}
+	return ""
+}
+
+// apiKeyAllowsDevice reports whether the API key of the request, if any, may be used for the device.
+func apiKeyAllowsDevice(ctx context.Context, id uuid.UUID) bool {
+	key, authenticated := apiKeyFrom(ctx)
+	return !authenticated || key.AllowsDevice(id)
+}
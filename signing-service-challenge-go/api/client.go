@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+)
+
+// errClientIDMissing is returned by the v1 signing endpoints for requests without client ID.
+const errClientIDMissing = "client_id missing"
+
+// requireClientID checks that the v1 signing request names its client. Only the v0 endpoint signs without client
+// ID, as described in the README. If the client ID is missing, an error response is written and false is
+// returned.
+func requireClientID(response http.ResponseWriter, request *http.Request, clientID string) bool {
+	if clientID == "" {
+		writeError(response, request, http.StatusBadRequest, []string{
+			errClientIDMissing,
+		})
+		return false
+	}
+	return true
+}
+
+// GetClients lists the IDs of all clients registered to a device.
+func (s *Server) GetClients(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, sd.Clients())
+}
+
+// PutClient registers a client to a device, so it is allowed to sign with it.
+func (s *Server) PutClient(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	if err := sd.RegisterClient(request.PathValue("client_id")); err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PutClient register", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, sd.Clients())
+}
+
+// DeleteClient deregisters a client from a device. Further signature requests of the client are rejected.
+func (s *Server) DeleteClient(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	if err := sd.DeregisterClient(request.PathValue("client_id")); err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "DeleteClient deregister", "err", err)
+		writeError(response, request, http.StatusNotFound, []string{
+			"client not registered",
+		})
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, sd.Clients())
+}
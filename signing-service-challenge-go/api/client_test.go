@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientEndpoints(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	baseURL := "http://localhost:8080/api/v1/devices/" + deviceID + "/clients"
+	s := NewServer(":8080")
+	s.Storer = getStorerWithData(t)
+	handler := s.Handler()
+
+	do := func(method string, url string) (int, []string) {
+		r := httptest.NewRequest(method, url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		resp := w.Result()
+		body := struct {
+			Data []string `json:"data"`
+		}{}
+		if resp.StatusCode == http.StatusOK {
+			require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		}
+		return resp.StatusCode, body.Data
+	}
+	sign := func(clientID string) int {
+		raw, err := json.Marshal(SignatureRequest{ID: deviceID, ClientID: clientID, Data: "data"})
+		require.Nil(t, err)
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result().StatusCode
+	}
+
+	t.Run("list", func(t *testing.T) {
+		code, clients := do("GET", baseURL)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{testClientID}, clients)
+	})
+	t.Run("register", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, sign("register-2"))
+
+		code, clients := do("PUT", baseURL+"/register-2")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{testClientID, "register-2"}, clients)
+
+		assert.Equal(t, http.StatusOK, sign("register-2"))
+	})
+	t.Run("invalid client id", func(t *testing.T) {
+		code, _ := do("PUT", baseURL+"/register_3")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("deregister", func(t *testing.T) {
+		code, clients := do("DELETE", baseURL+"/register-2")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{testClientID}, clients)
+
+		assert.Equal(t, http.StatusForbidden, sign("register-2"))
+
+		code, _ = do("DELETE", baseURL+"/register-2")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
@@ -1,122 +1,427 @@
 package api
 
 import (
-	"encoding/json"
-	"log"
+	"encoding/base64"
+	"errors"
 	"net/http"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
 	"github.com/google/uuid"
 )
 
+// SignatureFormatCOSE requests signatures to be additionally returned as COSE_Sign1 message.
+const SignatureFormatCOSE = "cose"
+
+// errTimestampsUnavailable is returned for devices requesting timestamps from a server without timestamper.
+const errTimestampsUnavailable = "timestamps not available"
+
+// errUnsupportedFormat is returned for devices requesting an unknown secured data format.
+const errUnsupportedFormat = "unsupported secured data format"
+
+// isSupportedFormat checks the requested secured data format of a new device. No format selects the default.
+func isSupportedFormat(format string) bool {
+	return format == "" || domain.IsSupportedSecuredDataFormat(format)
+}
+
+// errDeviceExists is returned for devices that cannot be created because the ID is taken.
+const errDeviceExists = "device already exists"
+
 // GetSignatureDevices lists all stored SignatureDevices
 func (s *Server) GetSignatureDevices(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
-		WriteErrorResponse(response, http.StatusMethodNotAllowed, []string{
+		writeError(response, request, http.StatusMethodNotAllowed, []string{
 			http.StatusText(http.StatusMethodNotAllowed),
 		})
 		return
 	}
 
-	devices, err := s.Storer.ReadSignatureDevices()
+	devices, err := s.store().ReadSignatureDevices(request.Context())
 	if err != nil {
-		log.Printf("GetSignatureDevices read devices | %s", err)
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{http.StatusText(http.StatusInternalServerError)})
+		s.Logger.ErrorContext(request.Context(), "GetSignatureDevices read devices", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	devices = accessibleDevices(request, devices)
+	infos := make([]domain.DeviceInfo, len(devices))
+	for i, sd := range devices {
+		infos[i] = sd.Info()
 	}
 
-	WriteAPIResponse(response, http.StatusOK, devices)
+	writeResponse(response, request, http.StatusOK, infos)
 }
 
 // PostSignatureDevie creates a new signature device and stores it with the storer
 func (s *Server) PostSignatureDevice(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
-		WriteErrorResponse(response, http.StatusMethodNotAllowed, []string{
+		writeError(response, request, http.StatusMethodNotAllowed, []string{
 			http.StatusText(http.StatusMethodNotAllowed),
 		})
 		return
 	}
 
 	// handle inputs
-	id := request.URL.Query().Get("id")
-	label := request.URL.Query().Get("label")
-	algorithm := request.URL.Query().Get("algorithm")
-	if !crypto.IsSupportedAlgorithm(algorithm) {
-		log.Printf("PostSignatureDevice unsupported algorithm: %s", algorithm)
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
+	payload := SignatureDeviceRequest{
+		ID:                request.URL.Query().Get("id"),
+		Label:             request.URL.Query().Get("label"),
+		Algorithm:         request.URL.Query().Get("algorithm"),
+		Timestamps:        request.URL.Query().Get("timestamps") == "true",
+		Deterministic:     request.URL.Query().Get("deterministic") == "true",
+		SecuredDataFormat: request.URL.Query().Get("secured_data_format"),
+	}
+	if isCBORRequest(request) {
+		if err := DecodeRequest(request, &payload); err != nil {
+			s.Logger.WarnContext(request.Context(), "PostSignatureDevice decode", "err", err)
+			writeError(response, request, http.StatusBadRequest, []string{
+				http.StatusText(http.StatusBadRequest),
+			})
+			return
+		}
+	}
+	if !s.algorithmEnabled(payload.Algorithm) {
+		s.Logger.WarnContext(request.Context(), "PostSignatureDevice unsupported algorithm", "algorithm", payload.Algorithm)
+		writeError(response, request, http.StatusBadRequest, []string{
 			http.StatusText(http.StatusBadRequest),
 		})
 		return
 	}
-	uid, err := uuid.Parse(id)
+	if !isSupportedFormat(payload.SecuredDataFormat) {
+		writeError(response, request, http.StatusBadRequest, []string{
+			errUnsupportedFormat,
+		})
+		return
+	}
+	if payload.SecuredDataFormat == "" {
+		// devices of the README sign in its format
+		payload.SecuredDataFormat = string(domain.SecuredDataV0)
+	}
+	uid, err := uuid.Parse(payload.ID)
 	if err != nil {
-		log.Printf("PostSignatureDevice invalid | err: %s", err)
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
+		s.Logger.WarnContext(request.Context(), "PostSignatureDevice invalid", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
 			http.StatusText(http.StatusBadRequest),
 		})
 		return
 	}
+	annotateDevice(request.Context(), uid.String(), crypto.SignatureAlgorithm(payload.Algorithm))
+	if !apiKeyAllowsDevice(request.Context(), uid) {
+		writeError(response, request, http.StatusForbidden, []string{
+			errDeviceNotAllowed,
+		})
+		return
+	}
+	if payload.Timestamps && s.Timestamper == nil {
+		writeError(response, request, http.StatusBadRequest, []string{
+			errTimestampsUnavailable,
+		})
+		return
+	}
 
-	sd, err := domain.NewSignatureDevice(uid, label, crypto.SignatureAlgorithm(algorithm))
+	sd, err := domain.NewSignatureDevice(uid, payload.Label, crypto.SignatureAlgorithm(payload.Algorithm), s.deviceOptions(request.Context(), payload)...)
 	if err != nil {
-		log.Printf("PostSignatureDevice New Signaturedevice: %s | err: %s", sd.ID, err)
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
+		s.Logger.ErrorContext(request.Context(), "PostSignatureDevice new signature device", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
 			http.StatusText(http.StatusInternalServerError),
 		})
 		return
 	}
+	if payload.Timestamps {
+		sd.EnableTimestamps(s.Timestamper)
+	}
 
-	sd, err = s.Storer.CreateSignatureDevice(sd)
+	sd, err = s.store().CreateSignatureDevice(request.Context(), sd)
+	if errors.Is(err, persistence.ErrConflict) {
+		writeError(response, request, http.StatusConflict, []string{
+			errDeviceExists,
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("PostSignatureDevice store signatureDevice | err: %s", err)
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
+		s.Logger.ErrorContext(request.Context(), "PostSignatureDevice store signatureDevice", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
 			http.StatusText(http.StatusInternalServerError),
 		})
 		return
 	}
 
-	WriteAPIResponse(response, http.StatusOK, sd)
+	writeResponse(response, request, http.StatusOK, sd.Info())
 }
 
+// PostSignature signs the provided data with the requested signature device. The signature is additionally
+// returned as COSE_Sign1 message if the client asks for format=cose or accepts application/cose.
 func (s *Server) PostSignature(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
-		WriteErrorResponse(response, http.StatusMethodNotAllowed, []string{
+		writeError(response, request, http.StatusMethodNotAllowed, []string{
 			http.StatusText(http.StatusMethodNotAllowed),
 		})
 		return
 	}
 
 	payload := SignatureRequest{}
-	err := json.NewDecoder(request.Body).Decode(&payload)
+	err := DecodeRequest(request, &payload)
 	if err != nil {
-		log.Printf("PostSignautre decode | err: %s", err)
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
+		s.Logger.WarnContext(request.Context(), "PostSignature decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
 			http.StatusText(http.StatusBadRequest),
 		})
 		return
 	}
 
-	sd, err := s.Storer.ReadSignatureDevice(payload.ID)
+	if _, err := uuid.Parse(payload.ID); err != nil {
+		writeError(response, request, http.StatusBadRequest, []string{
+			"invalid device id",
+		})
+		return
+	}
+	annotateDevice(request.Context(), payload.ID, "")
+	sd, err := s.store().ReadSignatureDevice(request.Context(), payload.ID)
+	if errors.Is(err, persistence.ErrNotFound) {
+		writeError(response, request, http.StatusNotFound, []string{
+			http.StatusText(http.StatusNotFound),
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("PostSignature read device | err: %s", err)
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
+		s.Logger.ErrorContext(request.Context(), "PostSignature read device", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
 			http.StatusText(http.StatusInternalServerError),
 		})
 		return
 	}
+	if !deviceAccessible(response, request, sd) {
+		return
+	}
+	annotateDevice(request.Context(), payload.ID, sd.Algorithm)
+
+	acceptsCOSE := accepts(request, ContentTypeCOSE)
+	var signature domain.Signature
+	var coseSign1 []byte
+	commit := s.commitSignatures(payload.ID)
+	if acceptsCOSE || request.URL.Query().Get("format") == SignatureFormatCOSE {
+		signature, coseSign1, err = sd.SignCOSE(request.Context(), payload.ClientID, payload.Data, commit)
+	} else {
+		signature, err = sd.Sign(request.Context(), payload.ClientID, payload.Data, commit)
+	}
+	if err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PostSignature sign", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+	s.logSignatures(request.Context(), signature)
+
+	if acceptsCOSE {
+		response.Header().Set("Content-Type", contentTypeCOSESign1)
+		response.WriteHeader(http.StatusOK)
+		response.Write(coseSign1)
+		return
+	}
+	if acceptsCBOR(request) {
+		rawSig, err := base64.StdEncoding.DecodeString(signature.Signature)
+		if err != nil {
+			s.Logger.ErrorContext(request.Context(), "PostSignature decode signature", "err", err)
+			WriteCBORErrorResponse(response, http.StatusInternalServerError, []string{
+				http.StatusText(http.StatusInternalServerError),
+			})
+			return
+		}
+		WriteCBORResponse(response, http.StatusOK, CBORSignatureResponse{
+			Counter:    signature.Counter,
+			ClientID:   signature.ClientID,
+			SignedData: signature.SignedData,
+			Format:     signature.Format,
+			Mode:       signature.Mode,
+			Signature:  rawSig,
+			COSESign1:  coseSign1,
+			Timestamp:  signature.Timestamp,
+		})
+		return
+	}
+
+	resp := newSignatureResponse(signature)
+	if coseSign1 != nil {
+		resp.COSESign1 = base64.StdEncoding.EncodeToString(coseSign1)
+	}
 
-	signature, signedData, err := sd.Sign(payload.Data)
+	WriteAPIResponse(response, http.StatusOK, resp)
+}
+
+// GetDevices lists the state of all signature devices ordered by ID.
+func (s *Server) GetDevices(response http.ResponseWriter, request *http.Request) {
+	devices, err := s.store().ReadSignatureDevices(request.Context())
 	if err != nil {
-		log.Printf("PostSignature sign | err: %s", err)
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
+		s.Logger.ErrorContext(request.Context(), "GetDevices read devices", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
 			http.StatusText(http.StatusInternalServerError),
 		})
 		return
 	}
-	resp := SignatureResponse{
-		SignedData: signedData,
-		Signature:  signature,
+
+	devices = accessibleDevices(request, devices)
+	infos := make([]domain.DeviceInfo, len(devices))
+	for i, sd := range devices {
+		infos[i] = sd.Info()
 	}
 
-	WriteAPIResponse(response, http.StatusOK, resp)
+	writeResponse(response, request, http.StatusOK, infos)
+}
+
+// PostDevice creates a new signature device from the request body. Existing devices are not replaced.
+func (s *Server) PostDevice(response http.ResponseWriter, request *http.Request) {
+	payload := SignatureDeviceRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostDevice decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if !s.algorithmEnabled(payload.Algorithm) {
+		writeError(response, request, http.StatusBadRequest, []string{
+			"unsupported algorithm",
+		})
+		return
+	}
+	if !isSupportedFormat(payload.SecuredDataFormat) {
+		writeError(response, request, http.StatusBadRequest, []string{
+			errUnsupportedFormat,
+		})
+		return
+	}
+	uid, err := uuid.Parse(payload.ID)
+	if err != nil || uid == uuid.Nil {
+		writeError(response, request, http.StatusBadRequest, []string{
+			"invalid device id",
+		})
+		return
+	}
+	annotateDevice(request.Context(), uid.String(), crypto.SignatureAlgorithm(payload.Algorithm))
+	if !apiKeyAllowsDevice(request.Context(), uid) {
+		writeError(response, request, http.StatusForbidden, []string{
+			errDeviceNotAllowed,
+		})
+		return
+	}
+	if payload.Timestamps && s.Timestamper == nil {
+		writeError(response, request, http.StatusBadRequest, []string{
+			errTimestampsUnavailable,
+		})
+		return
+	}
+
+	// checked upfront to skip the key generation, the store rejects concurrent creations anyway
+	_, err = s.store().ReadSignatureDevice(request.Context(), uid.String())
+	if err == nil {
+		writeError(response, request, http.StatusConflict, []string{
+			errDeviceExists,
+		})
+		return
+	}
+	if !errors.Is(err, persistence.ErrNotFound) {
+		s.Logger.ErrorContext(request.Context(), "PostDevice read device", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+
+	sd, err := domain.NewSignatureDevice(uid, payload.Label, crypto.SignatureAlgorithm(payload.Algorithm), s.deviceOptions(request.Context(), payload)...)
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "PostDevice new signature device", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+	if payload.Timestamps {
+		sd.EnableTimestamps(s.Timestamper)
+	}
+	sd, err = s.store().CreateSignatureDevice(request.Context(), sd)
+	if errors.Is(err, persistence.ErrConflict) {
+		writeError(response, request, http.StatusConflict, []string{
+			errDeviceExists,
+		})
+		return
+	}
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "PostDevice store signature device", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+
+	writeResponse(response, request, http.StatusCreated, sd.Info())
+}
+
+// GetDevice returns the state of a single signature device.
+func (s *Server) GetDevice(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, sd.Info())
+}
+
+// PatchDevice changes the label of a signature device.
+func (s *Server) PatchDevice(response http.ResponseWriter, request *http.Request) {
+	payload := UpdateSignatureDeviceRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PatchDevice decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	if payload.Label != nil {
+		sd.SetLabel(*payload.Label)
+	}
+
+	writeResponse(response, request, http.StatusOK, sd.Info())
+}
+
+// DeleteDevice decommissions a signature device. The device and its signatures are kept for verification and
+// export, but it cannot sign anymore.
+func (s *Server) DeleteDevice(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	if err := sd.Decommission(); err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "DeleteDevice decommission", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, sd.Info())
+}
+
+// GetPublicKey returns the PEM encoded public key of a signature device to verify its signatures.
+func (s *Server) GetPublicKey(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	publicKey, err := sd.PublicKey()
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "GetPublicKey", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, PublicKeyResponse{
+		Algorithm: sd.Algorithm,
+		PublicKey: string(publicKey),
+	})
 }
@@ -2,19 +2,28 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+const testClientID = "register-1"
+
 func TestGetSignatureDevices(t *testing.T) {
 	s := NewServer(":8080")
 	s.Storer = getStorerWithData(t)
@@ -38,14 +47,21 @@ func TestPostSignatureDevice(t *testing.T) {
 	resp := w.Result()
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("existing", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PostSignatureDevice(w, r)
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	})
 }
 
 func TestPostSignature(t *testing.T) {
 	s := NewServer(":8080")
 	s.Storer = getStorerWithData(t)
 	payload := SignatureRequest{
-		ID:   "38da2fb6-c293-4a63-a349-835330f0aca7",
-		Data: "data",
+		ID:       "38da2fb6-c293-4a63-a349-835330f0aca7",
+		ClientID: testClientID,
+		Data:     "data",
 	}
 	raw, err := json.Marshal(payload)
 	require.Nil(t, err)
@@ -58,7 +74,278 @@ func TestPostSignature(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
-func getDeviceMap(t *testing.T) map[string]*domain.SignatureDevice {
+
+func TestPostSignatureDeviceCBOR(t *testing.T) {
+	s := NewServer(":8080")
+	payload := SignatureDeviceRequest{
+		ID:        "38da2fb6-c293-4a63-a349-835330f0aca7",
+		Label:     "myDev",
+		Algorithm: "ECDSA",
+	}
+	raw, err := cbor.Marshal(payload)
+	require.Nil(t, err)
+
+	r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/create", bytes.NewBuffer(raw))
+	r.Header.Set("Content-Type", ContentTypeCBOR)
+	r.Header.Set("Accept", ContentTypeCBOR)
+	w := httptest.NewRecorder()
+	s.PostSignatureDevice(w, r)
+
+	resp := w.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ContentTypeCBOR, resp.Header.Get("Content-Type"))
+
+	body := struct {
+		Data domain.SignatureDevice `cbor:"data"`
+	}{}
+	require.Nil(t, cbor.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, payload.ID, body.Data.ID.String())
+	assert.Equal(t, payload.Label, body.Data.Label)
+	assert.Equal(t, crypto.SignautreECDSA, body.Data.Algorithm)
+}
+
+func TestPostSignatureCBOR(t *testing.T) {
+	s := NewServer(":8080")
+	s.Storer = getStorerWithData(t)
+	payload := SignatureRequest{
+		ID:       "1727d3e0-e1ae-410c-97d2-70da0ae0abc4",
+		ClientID: testClientID,
+		Data:     "data",
+	}
+	raw, err := cbor.Marshal(payload)
+	require.Nil(t, err)
+
+	t.Run("cbor", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewBuffer(raw))
+		r.Header.Set("Content-Type", ContentTypeCBOR)
+		r.Header.Set("Accept", ContentTypeCBOR)
+		w := httptest.NewRecorder()
+		s.PostSignature(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data CBORSignatureResponse `cbor:"data"`
+		}{}
+		require.Nil(t, cbor.NewDecoder(resp.Body).Decode(&body))
+		assert.NotEmpty(t, body.Data.Signature)
+		assert.Empty(t, body.Data.COSESign1)
+	})
+	t.Run("cbor with cose format", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign?format=cose", bytes.NewBuffer(raw))
+		r.Header.Set("Content-Type", ContentTypeCBOR)
+		r.Header.Set("Accept", ContentTypeCBOR)
+		w := httptest.NewRecorder()
+		s.PostSignature(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data CBORSignatureResponse `cbor:"data"`
+		}{}
+		require.Nil(t, cbor.NewDecoder(resp.Body).Decode(&body))
+		assert.NotEmpty(t, body.Data.COSESign1)
+	})
+	t.Run("cose", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewBuffer(raw))
+		r.Header.Set("Content-Type", ContentTypeCBOR)
+		r.Header.Set("Accept", ContentTypeCOSE)
+		w := httptest.NewRecorder()
+		s.PostSignature(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypeCOSESign1, resp.Header.Get("Content-Type"))
+
+		var tag cbor.RawTag
+		require.Nil(t, cbor.NewDecoder(resp.Body).Decode(&tag))
+		assert.Equal(t, uint64(18), tag.Number, "expect a tagged COSE_Sign1 message")
+	})
+	t.Run("json with cose format", func(t *testing.T) {
+		jsonRaw, err := json.Marshal(payload)
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign?format=cose", bytes.NewBuffer(jsonRaw))
+		w := httptest.NewRecorder()
+		s.PostSignature(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data SignatureResponse `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.NotEmpty(t, body.Data.COSESign1)
+	})
+}
+
+func TestPostSignatureWithoutClientID(t *testing.T) {
+	s := NewServer(":8080")
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	w := httptest.NewRecorder()
+	s.PostSignatureDevice(w, httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/create?id="+deviceID+"&label=myDev&algorithm=RSA", nil))
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	raw, err := json.Marshal(SignatureRequest{ID: deviceID, Data: "data"})
+	require.Nil(t, err)
+	w = httptest.NewRecorder()
+	s.PostSignature(w, httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewBuffer(raw)))
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	body := struct {
+		Data SignatureResponse `json:"data"`
+	}{}
+	require.Nil(t, json.NewDecoder(w.Result().Body).Decode(&body))
+	assert.Equal(t, "0_data_"+base64.StdEncoding.EncodeToString([]byte(deviceID)), body.Data.SignedData)
+	assert.Empty(t, body.Data.ClientID)
+
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("PUT", "http://localhost:8080/api/v1/devices/"+deviceID+"/clients/"+testClientID, nil))
+	assert.Equal(t, http.StatusConflict, w.Result().StatusCode, "the format of the README does not record clients")
+}
+
+func TestPostSignatureUnknownDevice(t *testing.T) {
+	s := NewServer(":8080")
+	raw, err := json.Marshal(SignatureRequest{ID: uuid.NewString(), ClientID: testClientID, Data: "data"})
+	require.Nil(t, err)
+
+	r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewBuffer(raw))
+	w := httptest.NewRecorder()
+	s.PostSignature(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestPostSignatureUnregisteredClient(t *testing.T) {
+	s := NewServer(":8080")
+	s.Storer = getStorerWithData(t)
+	raw, err := json.Marshal(SignatureRequest{ID: "38da2fb6-c293-4a63-a349-835330f0aca7", ClientID: "register-2", Data: "data"})
+	require.Nil(t, err)
+
+	r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewBuffer(raw))
+	w := httptest.NewRecorder()
+	s.PostSignature(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+}
+
+func TestDeviceEndpoints(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	baseURL := "http://localhost:8080/api/v1/devices"
+	s := NewServer(":8080")
+	s.Storer = getStorerWithData(t)
+	handler := s.Handler()
+
+	t.Run("list", func(t *testing.T) {
+		devices := []domain.DeviceInfo{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL, nil, &devices).Code)
+		require.Len(t, devices, 4)
+		assert.Equal(t, "1727d3e0-e1ae-410c-97d2-70da0ae0abc4", devices[0].ID.String(), "ordered by id")
+	})
+	t.Run("create", func(t *testing.T) {
+		id := uuid.New().String()
+		device := domain.DeviceInfo{}
+		code := serveJSON(t, handler, "POST", baseURL, SignatureDeviceRequest{ID: id, Label: "new", Algorithm: "ECDSA"}, &device).Code
+		require.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, id, device.ID.String())
+		assert.Equal(t, domain.DeviceActive, device.Status)
+		assert.Equal(t, domain.SecuredDataV1, device.SecuredDataFormat)
+
+		assert.Equal(t, http.StatusConflict, serveJSON(t, handler, "POST", baseURL, SignatureDeviceRequest{ID: id, Algorithm: "RSA"}, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "POST", baseURL, SignatureDeviceRequest{ID: "invalid", Algorithm: "RSA"}, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "POST", baseURL, SignatureDeviceRequest{ID: uuid.New().String(), Algorithm: "DSA"}, nil).Code)
+	})
+	t.Run("create deterministic", func(t *testing.T) {
+		device := domain.DeviceInfo{}
+		payload := SignatureDeviceRequest{ID: uuid.New().String(), Algorithm: "ECDSA", Deterministic: true}
+		require.Equal(t, http.StatusCreated, serveJSON(t, handler, "POST", baseURL, payload, &device).Code)
+		assert.True(t, device.Deterministic)
+	})
+	t.Run("create secured data format", func(t *testing.T) {
+		device := domain.DeviceInfo{}
+		payload := SignatureDeviceRequest{ID: uuid.New().String(), Algorithm: "ECDSA", SecuredDataFormat: "v1"}
+		require.Equal(t, http.StatusCreated, serveJSON(t, handler, "POST", baseURL, payload, &device).Code)
+		assert.Equal(t, domain.SecuredDataV1, device.SecuredDataFormat)
+
+		payload = SignatureDeviceRequest{ID: uuid.New().String(), Algorithm: "ECDSA", SecuredDataFormat: "v9"}
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "POST", baseURL, payload, nil).Code)
+	})
+	t.Run("create with configured algorithms", func(t *testing.T) {
+		s.Algorithms = []crypto.SignatureAlgorithm{crypto.SignautreECDSA}
+		s.KeySizes = map[crypto.SignatureAlgorithm]int{crypto.SignautreECDSA: 256}
+		defer func() { s.Algorithms, s.KeySizes = nil, nil }()
+
+		id := uuid.New().String()
+		require.Equal(t, http.StatusCreated, serveJSON(t, handler, "POST", baseURL, SignatureDeviceRequest{ID: id, Algorithm: "ECDSA"}, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "POST", baseURL, SignatureDeviceRequest{ID: uuid.New().String(), Algorithm: "RSA"}, nil).Code, "disabled")
+
+		publicKey := PublicKeyResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/"+id+"/public-key", nil, &publicKey).Code)
+		block, _ := pem.Decode([]byte(publicKey.PublicKey))
+		require.NotNil(t, block)
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		require.Nil(t, err)
+		assert.Equal(t, "P-256", key.(*ecdsa.PublicKey).Curve.Params().Name)
+	})
+	t.Run("get", func(t *testing.T) {
+		device := domain.DeviceInfo{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/"+deviceID, nil, &device).Code)
+		assert.Equal(t, "Dev1", device.Label)
+		assert.Equal(t, []string{testClientID}, device.Clients)
+
+		assert.Equal(t, http.StatusNotFound, serveJSON(t, handler, "GET", baseURL+"/"+uuid.New().String(), nil, nil).Code)
+	})
+	t.Run("public key", func(t *testing.T) {
+		publicKey := PublicKeyResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/"+deviceID+"/public-key", nil, &publicKey).Code)
+		assert.Equal(t, crypto.SignatureRSA, publicKey.Algorithm)
+		_, algorithm, err := crypto.NewVerifier([]byte(publicKey.PublicKey))
+		require.Nil(t, err)
+		assert.Equal(t, crypto.SignatureRSA, algorithm)
+	})
+	t.Run("update", func(t *testing.T) {
+		label := "renamed"
+		device := domain.DeviceInfo{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "PATCH", baseURL+"/"+deviceID, UpdateSignatureDeviceRequest{Label: &label}, &device).Code)
+		assert.Equal(t, label, device.Label)
+	})
+	t.Run("update while listing", func(t *testing.T) {
+		// run with -race: the label is read by the v0 list and the export while it changes
+		updates := []*http.Request{}
+		for _, label := range []string{"first", "second", "third"} {
+			updates = append(updates, newJSONRequest(t, "PATCH", baseURL+"/"+deviceID, UpdateSignatureDeviceRequest{Label: &label}))
+		}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, update := range updates {
+				handler.ServeHTTP(httptest.NewRecorder(), update)
+			}
+		}()
+		for range updates {
+			devices := []domain.DeviceInfo{}
+			assert.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", "http://localhost:8080/api/v0/devices", nil, &devices).Code)
+			assert.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/"+deviceID+"/export", nil, nil).Code)
+		}
+		wg.Wait()
+	})
+	t.Run("decommission", func(t *testing.T) {
+		device := domain.DeviceInfo{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "DELETE", baseURL+"/"+deviceID, nil, &device).Code)
+		assert.Equal(t, domain.DeviceDecommissioned, device.Status)
+		assert.Equal(t, http.StatusConflict, serveJSON(t, handler, "DELETE", baseURL+"/"+deviceID, nil, nil).Code)
+
+		code := serveJSON(t, handler, "POST", "http://localhost:8080/api/v0/devices/sign", SignatureRequest{ID: deviceID, ClientID: testClientID, Data: "data"}, nil).Code
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/"+deviceID+"/public-key", nil, nil).Code)
+	})
+}
+
+func getDeviceMap(t testing.TB) map[string]*domain.SignatureDevice {
 	uuid1, err := uuid.Parse("38da2fb6-c293-4a63-a349-835330f0aca7")
 	require.Nil(t, err, "uuid1 parse")
 	dev1, err := domain.NewSignatureDevice(uuid1, "Dev1", crypto.SignatureRSA)
@@ -85,13 +372,67 @@ func getDeviceMap(t *testing.T) map[string]*domain.SignatureDevice {
 		uuid3.String(): dev3,
 		uuid4.String(): dev4,
 	}
+	for _, dev := range deviceMap {
+		require.Nil(t, dev.RegisterClient(testClientID), "register client")
+	}
 	return deviceMap
 }
 
-func getStorerWithData(t *testing.T) persistence.InMemoryStorer {
-	deviceMap := getDeviceMap(t)
+func getStorerWithData(t testing.TB) *persistence.InMemoryStorer {
+	s := persistence.NewInMemoryStorer()
+	s.Devices = getDeviceMap(t)
+	return s
+}
 
-	return persistence.InMemoryStorer{
-		Devices: deviceMap,
+func FuzzPostSignature(f *testing.F) {
+	s := NewServer(":8080")
+	s.Storer = getStorerWithData(f)
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	sd, err := s.Storer.ReadSignatureDevice(context.Background(), deviceID)
+	require.Nil(f, err)
+	publicKey, err := sd.PublicKey()
+	require.Nil(f, err)
+	verifier, _, err := crypto.NewVerifier(publicKey)
+	require.Nil(f, err)
+
+	for _, payload := range []SignatureRequest{
+		{ID: deviceID, ClientID: testClientID, Data: "data"},
+		{ID: deviceID, ClientID: testClientID, Data: "with_separator\n"},
+		{ID: deviceID, ClientID: "unregistered", Data: "data"},
+		{ID: uuid.NewString(), ClientID: testClientID, Data: "data"},
+		{ID: "invalid", ClientID: testClientID},
+	} {
+		raw, err := json.Marshal(payload)
+		require.Nil(f, err)
+		f.Add(raw, false)
+		raw, err = cbor.Marshal(payload)
+		require.Nil(f, err)
+		f.Add(raw, true)
 	}
+	f.Add([]byte(`{"id": 1}`), false)
+	f.Add([]byte{0xff}, true)
+
+	f.Fuzz(func(t *testing.T, body []byte, isCBOR bool) {
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v0/devices/sign", bytes.NewReader(body))
+		if isCBOR {
+			r.Header.Set("Content-Type", ContentTypeCBOR)
+		}
+		w := httptest.NewRecorder()
+		s.PostSignature(w, r)
+
+		resp := w.Result()
+		// malformed requests are the client's fault, never the server's
+		require.Contains(t, []int{http.StatusOK, http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+			resp.StatusCode, w.Body.String())
+		if resp.StatusCode != http.StatusOK {
+			return
+		}
+
+		signature := SignatureResponse{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&Response{Data: &signature}))
+		assert.Equal(t, testClientID, signature.ClientID)
+		raw, err := base64.StdEncoding.DecodeString(signature.Signature)
+		require.Nil(t, err)
+		assert.True(t, verifier.Verify([]byte(signature.SignedData), raw))
+	})
 }
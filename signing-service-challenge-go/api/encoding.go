@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Supported media types for request and response bodies.
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeCBOR = "application/cbor"
+	ContentTypeCOSE = "application/cose"
+)
+
+// contentTypeCOSESign1 is the full media type of a COSE_Sign1 response body.
+const contentTypeCOSESign1 = ContentTypeCOSE + `; cose-type="cose-sign1"`
+
+// cborEncMode encodes API responses deterministically.
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// isCBORRequest reports whether the request body is CBOR encoded.
+func isCBORRequest(request *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return err == nil && mediaType == ContentTypeCBOR
+}
+
+// accepts reports whether the client listed the media type in its Accept header.
+func accepts(request *http.Request, mediaType string) bool {
+	for _, accepted := range strings.Split(request.Header.Get("Accept"), ",") {
+		acceptedType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && acceptedType == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsCBOR reports whether the response should be CBOR encoded.
+func acceptsCBOR(request *http.Request) bool {
+	return accepts(request, ContentTypeCBOR)
+}
+
+// DecodeRequest decodes the request body into v depending on its Content-Type. JSON is the default.
+func DecodeRequest(request *http.Request, v interface{}) error {
+	if isCBORRequest(request) {
+		if err := cbor.NewDecoder(request.Body).Decode(v); err != nil {
+			return fmt.Errorf("DecodeRequest cbor | %w", err)
+		}
+		return nil
+	}
+	if err := json.NewDecoder(request.Body).Decode(v); err != nil {
+		return fmt.Errorf("DecodeRequest json | %w", err)
+	}
+	return nil
+}
+
+// WriteCBORResponse takes an HTTP status code and a generic data struct
+// and writes those as a CBOR encoded HTTP response in a structured format.
+func WriteCBORResponse(w http.ResponseWriter, code int, data interface{}) {
+	bytes, err := cborEncMode.Marshal(Response{Data: data})
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeCBOR)
+	w.WriteHeader(code)
+	w.Write(bytes)
+}
+
+// WriteCBORErrorResponse is the CBOR counterpart of WriteErrorResponse.
+func WriteCBORErrorResponse(w http.ResponseWriter, code int, errors []string) {
+	bytes, err := cborEncMode.Marshal(ErrorResponse{Errors: errors, RequestID: w.Header().Get(RequestIDHeader)})
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeCBOR)
+	w.WriteHeader(code)
+	w.Write(bytes)
+}
+
+// writeResponse writes data in the encoding requested by the client.
+func writeResponse(w http.ResponseWriter, request *http.Request, code int, data interface{}) {
+	if acceptsCBOR(request) {
+		WriteCBORResponse(w, code, data)
+		return
+	}
+	WriteAPIResponse(w, code, data)
+}
+
+// writeError writes errors in the encoding requested by the client.
+func writeError(w http.ResponseWriter, request *http.Request, code int, errors []string) {
+	if acceptsCBOR(request) {
+		WriteCBORErrorResponse(w, code, errors)
+		return
+	}
+	WriteErrorResponse(w, code, errors)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+)
+
+// ContentTypeTAR is the media type of export archives.
+const ContentTypeTAR = "application/x-tar"
+
+// GetExport streams a TAR archive with the public key and the signature log of a device for auditors. The
+// export can be restricted with the inclusive query parameters from/to (RFC 3339) and counter_from/counter_to.
+func (s *Server) GetExport(response http.ResponseWriter, request *http.Request) {
+	filter, err := parseExportFilter(request.URL.Query())
+	if err != nil {
+		writeError(response, request, http.StatusBadRequest, []string{err.Error()})
+		return
+	}
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	publicKey, err := sd.PublicKey()
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "GetExport public key", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+	signatures, err := s.store().ReadSignatures(request.Context(), sd.ID.String())
+	if err != nil {
+		s.Logger.ErrorContext(request.Context(), "GetExport read signatures", "err", err)
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+		return
+	}
+
+	info := sd.Info()
+	device := export.Device{
+		ID:        sd.ID,
+		Label:     info.Label,
+		Algorithm: sd.Algorithm,
+	}
+	response.Header().Set("Content-Type", ContentTypeTAR)
+	response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sd.ID.String()+".tar"))
+	response.WriteHeader(http.StatusOK)
+	if err := export.Write(response, device, publicKey, signatures, filter, s.Clock.Now()); err != nil {
+		// the status has already been sent, the client detects the truncated archive
+		s.Logger.ErrorContext(request.Context(), "GetExport write archive", "err", err)
+	}
+}
+
+// parseExportFilter reads the optional export bounds from the query.
+func parseExportFilter(query url.Values) (export.Filter, error) {
+	filter := export.Filter{}
+	for name, bound := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return export.Filter{}, fmt.Errorf("%s has to be an RFC 3339 timestamp", name)
+		}
+		*bound = &t
+	}
+	for name, bound := range map[string]**int{"counter_from": &filter.CounterFrom, "counter_to": &filter.CounterTo} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		counter, err := strconv.Atoi(value)
+		if err != nil || counter < 0 {
+			return export.Filter{}, fmt.Errorf("%s has to be a non-negative integer", name)
+		}
+		*bound = &counter
+	}
+	return filter, nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetExport(t *testing.T) {
+	deviceID := "1727d3e0-e1ae-410c-97d2-70da0ae0abc4"
+	s := NewServer(":8080")
+	s.Storer = getStorerWithData(t)
+	handler := s.Handler()
+
+	raw, err := json.Marshal(BatchSignatureRequest{ClientID: testClientID, Data: []string{"a", "b", "c", "d"}})
+	require.Nil(t, err)
+	r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:batch", bytes.NewBuffer(raw))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	t.Run("default", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://localhost:8080/api/v1/devices/"+deviceID+"/export?counter_from=1", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, ContentTypeTAR, resp.Header.Get("Content-Type"))
+
+		manifest := export.Manifest{}
+		names := []string{}
+		tr := tar.NewReader(resp.Body)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(t, err)
+			names = append(names, header.Name)
+			if header.Name == export.ManifestFile {
+				require.Nil(t, json.NewDecoder(tr).Decode(&manifest))
+			}
+		}
+		assert.Contains(t, names, export.PublicKeyFile)
+		assert.Contains(t, names, export.ManifestCSVFile)
+		assert.Equal(t, deviceID, manifest.Device.ID.String())
+		require.Equal(t, 3, len(manifest.Entries))
+		assert.Equal(t, 1, manifest.Entries[0].Counter)
+	})
+	t.Run("invalid filter", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://localhost:8080/api/v1/devices/"+deviceID+"/export?from=yesterday", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
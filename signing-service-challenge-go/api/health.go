@@ -1,6 +1,19 @@
 package api
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/buildinfo"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/google/uuid"
+)
+
+// certificateExpiryWarning is the remaining validity of the TSA certificate below which readiness warns.
+const certificateExpiryWarning = 30 * 24 * time.Hour
 
 type HealthResponse struct {
 	Status  string `json:"status"`
@@ -17,9 +30,69 @@ func (s *Server) Health(response http.ResponseWriter, request *http.Request) {
 	}
 
 	health := HealthResponse{
-		Status:  "pass",
+		Status:  string(s.Readiness.Run(request.Context()).Status),
 		Version: "v0",
 	}
 
-	WriteAPIResponse(response, http.StatusOK, health)
+	code := http.StatusOK
+	if health.Status == "fail" {
+		code = http.StatusServiceUnavailable
+	}
+	WriteAPIResponse(response, code, health)
+}
+
+// newHealthCheckers creates the liveness and readiness checkers of the server with the built-in checks. The
+// checks use the fields of the server when they run, so replacing e.g. the Storer after NewServer is covered.
+//
+// There are no checks for an unlocked key backend or a warm key pre-generation pool: the service has neither,
+// device keys are generated in memory when a device is created. Such checks belong here once the components
+// exist; free disk space of the ledger is registered by main, since the path comes from the configuration.
+func (s *Server) newHealthCheckers() (liveness *health.Checker, readiness *health.Checker) {
+	liveness = health.NewChecker("signing service liveness")
+	readiness = health.NewChecker("signing service readiness")
+	for _, c := range []*health.Checker{liveness, readiness} {
+		c.Version = buildinfo.Version
+		c.ReleaseID = buildinfo.Revision()
+		c.Clock = s.Clock
+	}
+
+	liveness.Register("uptime", "system", health.Uptime(s.Clock, s.Clock.Now()))
+	readiness.Register("server:draining", "system", s.checkDraining)
+	readiness.Register("store:reachable", "datastore", s.checkStore)
+	readiness.Register("tsa:certificate", "component", s.checkTSACertificate)
+	return liveness, readiness
+}
+
+// checkStore reads a device that never exists: the store is reachable if it answers with ErrNotFound.
+func (s *Server) checkStore(ctx context.Context) health.Result {
+	_, err := s.store().ReadSignatureDevice(ctx, uuid.Nil.String())
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return health.Result{Status: health.StatusFail, Output: err.Error()}
+	}
+	return health.Result{Status: health.StatusPass}
+}
+
+// checkTSACertificate reports the remaining validity of the built-in TSA certificate in seconds. Timestamps
+// fail once it expired.
+func (s *Server) checkTSACertificate(ctx context.Context) health.Result {
+	if s.TSA == nil {
+		return health.Result{Status: health.StatusPass, Output: "built-in TSA disabled"}
+	}
+	certificate := s.TSA.Certificate()
+	remaining := certificate.NotAfter.Sub(s.Clock.Now())
+	result := health.Result{
+		ComponentID:   certificate.SerialNumber.String(),
+		Status:        health.StatusPass,
+		ObservedValue: remaining.Seconds(),
+		ObservedUnit:  "s",
+	}
+	switch {
+	case remaining <= 0 || s.Clock.Now().Before(certificate.NotBefore):
+		result.Status = health.StatusFail
+		result.Output = "certificate not valid"
+	case remaining < certificateExpiryWarning:
+		result.Status = health.StatusWarn
+		result.Output = "certificate expires soon"
+	}
+	return result
 }
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/buildinfo"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableStorer fails every device read like a store that lost its connection.
+type unreachableStorer struct {
+	persistence.Storer
+}
+
+func (unreachableStorer) ReadSignatureDevice(ctx context.Context, id string) (*domain.SignatureDevice, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHealth(t *testing.T) {
+	get := func(s *Server, target string) (*httptest.ResponseRecorder, health.Response) {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		response := health.Response{}
+		if w.Header().Get("Content-Type") == health.ContentType {
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w, response
+	}
+
+	t.Run("livez", func(t *testing.T) {
+		w, response := get(NewServer(""), "/livez")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, health.StatusPass, response.Status)
+		assert.Equal(t, buildinfo.Version, response.Version)
+		assert.Equal(t, buildinfo.Revision(), response.ReleaseID)
+		assert.Contains(t, response.Checks, "uptime")
+	})
+	t.Run("readyz", func(t *testing.T) {
+		w, response := get(NewServer(""), "/readyz")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, health.StatusPass, response.Status)
+		require.Len(t, response.Checks["store:reachable"], 1)
+		assert.Equal(t, "datastore", response.Checks["store:reachable"][0].ComponentType)
+		require.Len(t, response.Checks["tsa:certificate"], 1)
+		assert.Equal(t, health.StatusPass, response.Checks["tsa:certificate"][0].Status)
+	})
+	t.Run("store unreachable", func(t *testing.T) {
+		s := NewServer("")
+		s.Storer = unreachableStorer{Storer: s.Storer}
+
+		w, response := get(s, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, health.StatusFail, response.Status)
+		assert.Equal(t, "connection refused", response.Checks["store:reachable"][0].Output)
+
+		w, _ = get(s, "/livez")
+		assert.Equal(t, http.StatusOK, w.Code, "the process is alive although it is not ready")
+
+		w, _ = get(s, "/api/v0/health")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		body := struct{ Data HealthResponse }{}
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, HealthResponse{Status: "fail", Version: "v0"}, body.Data)
+	})
+	t.Run("registered check", func(t *testing.T) {
+		s := NewServer("")
+		s.Readiness.Register("disk:free", "system", func(ctx context.Context) health.Result {
+			return health.Result{Status: health.StatusWarn}
+		})
+		w, response := get(s, "/readyz")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, health.StatusWarn, response.Status)
+	})
+}
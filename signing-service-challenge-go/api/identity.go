@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errDeviceForbidden is returned for devices owned by another identity.
+const errDeviceForbidden = "device belongs to another identity"
+
+// IdentityFunc maps a verified client certificate to the identity of the caller. An empty identity leaves the
+// caller anonymous.
+type IdentityFunc func(certificate *x509.Certificate) string
+
+// IdentityCommonName identifies callers by the common name of their certificate subject, e.g. "pos-1".
+func IdentityCommonName(certificate *x509.Certificate) string {
+	return certificate.Subject.CommonName
+}
+
+// IdentitySubject identifies callers by the distinguished name of their certificate subject in RFC 2253
+// notation, e.g. "CN=pos-1,O=Shop".
+func IdentitySubject(certificate *x509.Certificate) string {
+	return certificate.Subject.String()
+}
+
+type identityKey struct{}
+
+// identityFrom returns the authenticated caller of the request, empty for anonymous callers.
+func identityFrom(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
+
+// withIdentity authenticates callers by the client certificate of the TLS connection. Only certificates verified
+// against TLSConfig.ClientCAs are considered, callers without one stay anonymous.
+func (s *Server) withIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 || s.ClientIdentity == nil {
+			next.ServeHTTP(response, request)
+			return
+		}
+		identity := s.ClientIdentity(request.TLS.VerifiedChains[0][0])
+		if identity == "" {
+			next.ServeHTTP(response, request)
+			return
+		}
+		annotateIdentity(request.Context(), identity)
+		trace.SpanFromContext(request.Context()).SetAttributes(semconv.EnduserID(identity))
+		next.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), identityKey{}, identity)))
+	})
+}
+
+// deviceAccessible checks that the caller of the request may use the device, see deviceAccess. Otherwise 403 is
+// written and false is returned.
+func deviceAccessible(response http.ResponseWriter, request *http.Request, sd *domain.SignatureDevice) bool {
+	if reason := deviceAccess(request.Context(), sd); reason != "" {
+		writeError(response, request, http.StatusForbidden, []string{
+			reason,
+		})
+		return false
+	}
+	return true
+}
+
+// accessibleDevices filters the devices the caller of the request may use.
+func accessibleDevices(request *http.Request, devices []*domain.SignatureDevice) []*domain.SignatureDevice {
+	accessible := make([]*domain.SignatureDevice, 0, len(devices))
+	for _, sd := range devices {
+		if deviceAccess(request.Context(), sd) == "" {
+			accessible = append(accessible, sd)
+		}
+	}
+	return accessible
+}
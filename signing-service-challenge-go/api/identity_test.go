@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig/tlsconfigtest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIdentity(t *testing.T) {
+	ca := tlsconfigtest.NewCA(t)
+	certFile, keyFile := ca.Issue(t, "localhost").WriteFiles(t, t.TempDir())
+	reloader, err := tlsconfig.NewReloader(certFile, keyFile)
+	require.Nil(t, err)
+
+	var logs logBuffer
+	s := NewServer("")
+	s.Storer = getStorerWithData(t)
+	s.Logger, err = NewLogger(&logs, LogFormatJSON, slog.LevelInfo)
+	require.Nil(t, err)
+	s.TLSConfig = tlsconfig.ServerConfig(reloader, ca.Pool(), tls.VerifyClientCertIfGiven)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, listener)
+	}()
+	defer func() {
+		cancel()
+		require.Nil(t, <-served)
+	}()
+	baseURL := "https://" + listener.Addr().String() + "/api/v1"
+
+	// client returns a client presenting the certificates.
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      ca.Pool(),
+			Certificates: certificates,
+			ServerName:   "localhost",
+		}}}
+	}
+	pos1 := client(ca.Issue(t, "pos-1", "Shop").TLS())
+	pos2 := client(ca.Issue(t, "pos-2", "Shop").TLS())
+	anonymous := client()
+
+	ids := func(devices []domain.DeviceInfo) []string {
+		ids := []string{}
+		for _, device := range devices {
+			ids = append(ids, device.ID.String())
+		}
+		return ids
+	}
+
+	owned := uuid.New().String()
+	device := domain.DeviceInfo{}
+	require.Equal(t, http.StatusCreated, sendJSON(t, pos1, "POST", baseURL+"/devices", SignatureDeviceRequest{ID: owned, Algorithm: "ECDSA"}, &device))
+	require.Equal(t, http.StatusOK, sendJSON(t, pos1, "PUT", baseURL+"/devices/"+owned+"/clients/"+testClientID, nil, nil))
+
+	t.Run("owner", func(t *testing.T) {
+		assert.Equal(t, "pos-1", device.Owner)
+		assert.Equal(t, http.StatusOK, sendJSON(t, pos1, "GET", baseURL+"/devices/"+owned, nil, nil))
+		assert.Equal(t, http.StatusOK, sendJSON(t, pos1, "POST", baseURL+"/devices/"+owned+"/signatures:batch", BatchSignatureRequest{ClientID: testClientID, Data: []string{"a"}}, nil))
+
+		devices := []domain.DeviceInfo{}
+		require.Equal(t, http.StatusOK, sendJSON(t, pos1, "GET", baseURL+"/devices", nil, &devices))
+		assert.Contains(t, ids(devices), owned)
+	})
+	t.Run("other identity", func(t *testing.T) {
+		for _, c := range []*http.Client{pos2, anonymous} {
+			assert.Equal(t, http.StatusForbidden, sendJSON(t, c, "GET", baseURL+"/devices/"+owned, nil, nil))
+			assert.Equal(t, http.StatusForbidden, sendJSON(t, c, "POST", baseURL+"/devices/"+owned+"/signatures:batch", BatchSignatureRequest{ClientID: testClientID, Data: []string{"a"}}, nil))
+			assert.Equal(t, http.StatusForbidden, sendJSON(t, c, "DELETE", baseURL+"/devices/"+owned, nil, nil))
+
+			devices := []domain.DeviceInfo{}
+			require.Equal(t, http.StatusOK, sendJSON(t, c, "GET", baseURL+"/devices", nil, &devices))
+			assert.NotContains(t, ids(devices), owned)
+			assert.Len(t, devices, 4, "devices without owner are listed")
+		}
+	})
+	t.Run("bulk", func(t *testing.T) {
+		result := BulkSignatureResponse{}
+		payload := BulkSignatureRequest{Items: []BulkSignatureItem{{DeviceID: owned, ClientID: testClientID, Data: "a"}}}
+		require.Equal(t, http.StatusOK, sendJSON(t, pos2, "POST", baseURL+"/signatures:bulk", payload, &result))
+		assert.Equal(t, errDeviceForbidden, result.Results[0].Error)
+	})
+	t.Run("v0", func(t *testing.T) {
+		payload := SignatureRequest{ID: owned, ClientID: testClientID, Data: "a"}
+		url := strings.Replace(baseURL, "v1", "v0", 1) + "/devices/sign"
+		assert.Equal(t, http.StatusForbidden, sendJSON(t, pos2, "POST", url, payload, nil))
+		assert.Equal(t, http.StatusOK, sendJSON(t, pos1, "POST", url, payload, nil))
+	})
+	t.Run("unowned", func(t *testing.T) {
+		id := uuid.New().String()
+		device := domain.DeviceInfo{}
+		require.Equal(t, http.StatusCreated, sendJSON(t, anonymous, "POST", baseURL+"/devices", SignatureDeviceRequest{ID: id, Algorithm: "ECDSA"}, &device))
+		assert.Empty(t, device.Owner)
+		assert.Equal(t, http.StatusOK, sendJSON(t, pos2, "GET", baseURL+"/devices/"+id, nil, nil))
+	})
+	t.Run("untrusted certificate", func(t *testing.T) {
+		untrusted := client(tlsconfigtest.NewCA(t).Issue(t, "pos-1").TLS())
+		_, err := untrusted.Get(baseURL + "/devices/" + owned)
+		assert.NotNil(t, err)
+	})
+	t.Run("log", func(t *testing.T) {
+		logs.Reset()
+		require.Equal(t, http.StatusOK, sendJSON(t, pos1, "GET", baseURL+"/devices/"+owned, nil, nil))
+		records := logRecords(t, &logs)
+		require.NotEmpty(t, records)
+		assert.Equal(t, "pos-1", records[len(records)-1][logKeyIdentity])
+	})
+}
+
+func TestIdentityFunc(t *testing.T) {
+	certificate := &x509.Certificate{Subject: pkix.Name{CommonName: "pos-1", Organization: []string{"Shop"}}}
+	assert.Equal(t, "pos-1", IdentityCommonName(certificate))
+	assert.Equal(t, "CN=pos-1,O=Shop", IdentitySubject(certificate))
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// Defaults of the HTTP server limits.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = time.Minute
+	DefaultWriteTimeout      = 2 * time.Minute
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultMaxBodyBytes      = 4 << 20
+)
+
+// streamRoute is exempt from Server.MaxBodyBytes and ReadTimeout: the content is hashed while it is read and never
+// held in memory, see streamBody.
+const streamRoute = "POST /api/v1/devices/{id}/signatures:stream"
+
+// Run listens on the address of the server and serves the API until ctx is done, then shuts down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.listenAddress)
+	if err != nil {
+		return fmt.Errorf("Run | %w", err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves the API on the listener until ctx is done, with TLS if TLSConfig is set. Then the server stops accepting
+// connections, reports not ready, waits up to ShutdownTimeout for running requests and closes the remaining
+// connections. Signatures in progress are always completed and committed. Finally the store is flushed if it is a
+// persistence.Flusher.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(s.Logger.Handler(), slog.LevelWarn),
+	}
+
+	if s.TLSConfig != nil {
+		listener = tls.NewListener(listener, s.TLSConfig)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	select {
+	case err := <-served:
+		return fmt.Errorf("Serve | %w", err)
+	case <-ctx.Done():
+	}
+	return s.shutdown(server)
+}
+
+// shutdown drains the server and flushes the store.
+func (s *Server) shutdown(server *http.Server) error {
+	s.draining.Store(true)
+	s.Logger.Info("shutting down", "timeout", s.ShutdownTimeout, "signatures_in_progress", s.signing.count())
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		// closing the connections cancels their requests, signing calls still waiting for a device give up
+		s.Logger.Warn("shutdown timed out, closing connections", "err", err)
+		server.Close()
+	}
+	s.signing.wait()
+
+	if flusher, ok := s.Storer.(persistence.Flusher); ok {
+		if err := flusher.Flush(context.Background()); err != nil {
+			return fmt.Errorf("Serve flush | %w", err)
+		}
+	}
+	s.Logger.Info("shutdown complete")
+	return nil
+}
+
+// checkDraining fails once the server shuts down, so load balancers stop sending requests.
+func (s *Server) checkDraining(ctx context.Context) health.Result {
+	if s.draining.Load() {
+		return health.Result{Status: health.StatusFail, Output: "shutting down"}
+	}
+	return health.Result{Status: health.StatusPass}
+}
+
+// withBodyLimit rejects request bodies larger than MaxBodyBytes with 413, or fails their decoding if the size is
+// not declared.
+func (s *Server) withBodyLimit(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if _, route := mux.Handler(request); s.MaxBodyBytes > 0 && route != streamRoute {
+			if request.ContentLength > s.MaxBodyBytes {
+				writeError(response, request, http.StatusRequestEntityTooLarge, []string{
+					http.StatusText(http.StatusRequestEntityTooLarge),
+				})
+				return
+			}
+			request.Body = http.MaxBytesReader(response, request.Body, s.MaxBodyBytes)
+		}
+		next.ServeHTTP(response, request)
+	})
+}
+
+// streamBody returns the body of a request on streamRoute. Instead of the whole request, every read of the body is
+// limited by ReadTimeout: content of any size is read as long as it keeps arriving, stalled uploads still fail.
+func (s *Server) streamBody(response http.ResponseWriter, request *http.Request) io.Reader {
+	return deadlineReader{
+		reader:     request.Body,
+		controller: http.NewResponseController(response),
+		timeout:    s.ReadTimeout,
+	}
+}
+
+// extendWriteDeadline gives the response WriteTimeout from now. The write deadline of a request starts when it is
+// read, so the time the body of a stream took would count against it.
+func (s *Server) extendWriteDeadline(response http.ResponseWriter) error {
+	err := http.NewResponseController(response).SetWriteDeadline(deadline(s.WriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("extendWriteDeadline | %w", err)
+	}
+	return nil
+}
+
+// deadlineReader sets the read deadline of the connection to timeout from now before every read.
+type deadlineReader struct {
+	reader     io.Reader
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (r deadlineReader) Read(p []byte) (int, error) {
+	err := r.controller.SetReadDeadline(deadline(r.timeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, fmt.Errorf("deadlineReader | %w", err)
+	}
+	return r.reader.Read(p)
+}
+
+// deadline returns the deadline timeout from now; a timeout of zero or less has no deadline.
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// trackSigning marks the requests of the handler as signature operations that shutdown waits for.
+func (s *Server) trackSigning(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		s.signing.start()
+		defer s.signing.finish()
+		handler(response, request)
+	})
+}
+
+// inflight counts running operations.
+type inflight struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed when n drops to zero and replaced when the next operation starts.
+	idle chan struct{}
+}
+
+func (f *inflight) start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.n == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.n++
+}
+
+func (f *inflight) finish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n--
+	if f.n == 0 {
+		close(f.idle)
+	}
+}
+
+func (f *inflight) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// wait blocks until no operation is running.
+func (f *inflight) wait() {
+	for {
+		f.mu.Lock()
+		n, idle := f.n, f.idle
+		f.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		<-idle
+	}
+}
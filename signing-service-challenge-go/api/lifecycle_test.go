@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// blockingStorer holds every signature write until it is released and records the order of writes and flushes.
+type blockingStorer struct {
+	*persistence.InMemoryStorer
+	entered chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func newBlockingStorer(t *testing.T) *blockingStorer {
+	return &blockingStorer{
+		InMemoryStorer: getStorerWithData(t),
+		entered:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+}
+
+func (b *blockingStorer) CreateSignatures(ctx context.Context, deviceID string, signatures []domain.Signature) error {
+	b.entered <- struct{}{}
+	<-b.release
+	b.record("CreateSignatures")
+	return b.InMemoryStorer.CreateSignatures(ctx, deviceID, signatures)
+}
+
+func (b *blockingStorer) Flush(ctx context.Context) error {
+	b.record("Flush")
+	return nil
+}
+
+func (b *blockingStorer) record(call string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, call)
+}
+
+func (b *blockingStorer) recorded() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.calls...)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+
+	// serve starts the server and a signature request that blocks in the store.
+	serve := func(t *testing.T, s *Server) (cancel context.CancelFunc, served chan error, signed chan *http.Response) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		served = make(chan error, 1)
+		go func() {
+			served <- s.Serve(ctx, listener)
+		}()
+
+		signed = make(chan *http.Response, 1)
+		go func() {
+			body := `{"client_id":"` + testClientID + `","data":["a"]}`
+			response, err := http.Post("http://"+listener.Addr().String()+"/api/v1/devices/"+deviceID+"/signatures:batch", "application/json", strings.NewReader(body))
+			if err != nil {
+				response = nil
+			}
+			signed <- response
+		}()
+		return cancel, served, signed
+	}
+
+	t.Run("drain", func(t *testing.T) {
+		s := NewServer("")
+		storer := newBlockingStorer(t)
+		s.Storer = storer
+		cancel, served, signed := serve(t, s)
+
+		<-storer.entered
+		cancel()
+		select {
+		case <-served:
+			t.Fatal("Serve returned while a signature was in progress")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.Equal(t, health.StatusFail, s.Readiness.Run(context.Background()).Status, "not ready while draining")
+
+		close(storer.release)
+		response := <-signed
+		require.NotNil(t, response)
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		require.Nil(t, <-served)
+		assert.Equal(t, []string{"CreateSignatures", "Flush"}, storer.recorded())
+	})
+	t.Run("timeout", func(t *testing.T) {
+		s := NewServer("")
+		s.ShutdownTimeout = 10 * time.Millisecond
+		storer := newBlockingStorer(t)
+		s.Storer = storer
+		cancel, served, signed := serve(t, s)
+
+		<-storer.entered
+		cancel()
+		// the connection is closed after the timeout, the signature still completes
+		assert.Nil(t, <-signed)
+		select {
+		case <-served:
+			t.Fatal("Serve returned while a signature was in progress")
+		default:
+		}
+
+		close(storer.release)
+		require.Nil(t, <-served)
+		assert.Equal(t, []string{"CreateSignatures", "Flush"}, storer.recorded())
+		ledger, err := storer.ReadSignatures(context.Background(), deviceID)
+		require.Nil(t, err)
+		assert.Len(t, ledger, 1)
+	})
+	t.Run("listen error", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		defer listener.Close()
+		s := NewServer(listener.Addr().String())
+		assert.NotNil(t, s.Run(context.Background()))
+	})
+}
+
+func TestBodyLimit(t *testing.T) {
+	s := NewServer("")
+	s.Storer = getStorerWithData(t)
+	s.MaxBodyBytes = 64
+	handler := s.Handler()
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	large := `{"client_id":"` + testClientID + `","data":["` + strings.Repeat("a", 64) + `"]}`
+
+	t.Run("declared size", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/devices/"+deviceID+"/signatures:batch", strings.NewReader(large)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+	t.Run("undeclared size", func(t *testing.T) {
+		request := httptest.NewRequest("POST", "/api/v1/devices/"+deviceID+"/signatures:batch", strings.NewReader(large))
+		request.ContentLength = -1
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("stream", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		content := bytes.Repeat([]byte("a"), 1024)
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/devices/"+deviceID+"/signatures:stream?client_id="+testClientID, bytes.NewReader(content)))
+		assert.Equal(t, http.StatusOK, w.Code, "streamed content is not limited")
+	})
+}
+
+func TestStreamTimeouts(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	s := NewServer("")
+	s.Storer = getStorerWithData(t)
+	s.TracerProvider = sdktrace.NewTracerProvider()
+	s.ReadTimeout = 200 * time.Millisecond
+	s.WriteTimeout = 200 * time.Millisecond
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, listener)
+	}()
+	defer func() {
+		cancel()
+		require.Nil(t, <-served)
+	}()
+	url := "http://" + listener.Addr().String() + "/api/v1/devices/" + deviceID + "/signatures:stream?client_id=" + testClientID
+
+	// upload sends the chunks of content with a pause before each of them.
+	upload := func(chunks int, pause time.Duration) (*http.Response, error) {
+		body, writer := io.Pipe()
+		go func() {
+			for i := 0; i < chunks; i++ {
+				time.Sleep(pause)
+				if _, err := writer.Write(bytes.Repeat([]byte("a"), 1024)); err != nil {
+					return
+				}
+			}
+			writer.Close()
+		}()
+		return http.Post(url, "application/octet-stream", body)
+	}
+
+	t.Run("slow body", func(t *testing.T) {
+		response, err := upload(10, 50*time.Millisecond)
+		require.Nil(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode, "the upload takes longer than ReadTimeout and WriteTimeout")
+		body := StreamSignatureResponse{}
+		decodeData(t, response.StatusCode, response.Body, &body)
+		assert.Equal(t, int64(10*1024), body.ContentLength)
+	})
+	t.Run("stalled body", func(t *testing.T) {
+		response, err := upload(2, 400*time.Millisecond)
+		if err == nil {
+			defer response.Body.Close()
+			assert.NotEqual(t, http.StatusOK, response.StatusCode)
+		}
+	})
+}
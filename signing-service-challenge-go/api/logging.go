@@ -0,0 +1,280 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the correlation ID of a request. IDs sent by clients are kept, otherwise the server
+// generates one. The ID is echoed in the response, in error bodies and in all log records of the request.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits request IDs accepted from clients; longer IDs are replaced.
+const maxRequestIDLength = 128
+
+// Log formats supported by NewLogger.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Keys of the request attributes added to log records.
+const (
+	logKeyRequestID = "request_id"
+	logKeyDeviceID  = "device_id"
+	logKeyAlgorithm = "algorithm"
+	logKeyCounter   = "counter"
+	logKeyTraceID   = "trace_id"
+	logKeyIdentity  = "identity"
+	logKeyAPIKey    = "api_key_id"
+)
+
+// NewLogger creates a logger writing records of at least the level in the format, "text" or "json". Records
+// logged with the context of a request get its request attributes.
+func NewLogger(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case LogFormatText:
+		return slog.New(NewLogHandler(slog.NewTextHandler(w, options))), nil
+	case LogFormatJSON:
+		return slog.New(NewLogHandler(slog.NewJSONHandler(w, options))), nil
+	}
+	return nil, fmt.Errorf("NewLogger | unsupported log format %q", format)
+}
+
+// NewLogHandler wraps a handler to add the request ID, the trace ID and the device, algorithm and counter a request
+// works on to the records logged with the context of the request.
+func NewLogHandler(handler slog.Handler) slog.Handler {
+	return logHandler{Handler: handler}
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info := requestInfoFrom(ctx); info != nil {
+		record.AddAttrs(info.attrs()...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String(logKeyTraceID, spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// requestInfo collects the attributes of a request while it is handled.
+type requestInfo struct {
+	id string
+
+	mu        sync.Mutex
+	identity  string
+	apiKeyID  string
+	deviceID  string
+	algorithm crypto.SignatureAlgorithm
+	counter   *int
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+func (info *requestInfo) attrs() []slog.Attr {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	attrs := []slog.Attr{slog.String(logKeyRequestID, info.id)}
+	if info.identity != "" {
+		attrs = append(attrs, slog.String(logKeyIdentity, info.identity))
+	}
+	if info.apiKeyID != "" {
+		attrs = append(attrs, slog.String(logKeyAPIKey, info.apiKeyID))
+	}
+	if info.deviceID != "" {
+		attrs = append(attrs, slog.String(logKeyDeviceID, info.deviceID))
+	}
+	if info.algorithm != "" {
+		attrs = append(attrs, slog.String(logKeyAlgorithm, string(info.algorithm)))
+	}
+	if info.counter != nil {
+		attrs = append(attrs, slog.Int(logKeyCounter, *info.counter))
+	}
+	return attrs
+}
+
+// annotateDevice adds the device to the log records of the request.
+func annotateDevice(ctx context.Context, deviceID string, algorithm crypto.SignatureAlgorithm) {
+	info := requestInfoFrom(ctx)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.deviceID = deviceID
+	info.algorithm = algorithm
+}
+
+// annotateIdentity adds the authenticated caller to the log records of the request.
+func annotateIdentity(ctx context.Context, identity string) {
+	info := requestInfoFrom(ctx)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.identity = identity
+}
+
+// annotateAPIKey adds the ID of the API key that authenticated the request to its log records.
+func annotateAPIKey(ctx context.Context, id string) {
+	info := requestInfoFrom(ctx)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.apiKeyID = id
+}
+
+// annotateCounter adds the counter of the last signature created by the request to its log records.
+func annotateCounter(ctx context.Context, counter int) {
+	info := requestInfoFrom(ctx)
+	if info == nil {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.counter = &counter
+}
+
+// requestID returns the valid request ID sent by the client or a new one.
+func requestID(request *http.Request) string {
+	id := request.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for i := 0; i < len(id); i++ {
+		// printable ASCII only, the ID ends up in headers and logs
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
+
+// statusRecorder remembers the status and size of a response for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// withRequestLog assigns the request ID and logs every request after it has been handled. Bodies are never
+// logged.
+func (s *Server) withRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		info := &requestInfo{id: requestID(request)}
+		response.Header().Set(RequestIDHeader, info.id)
+		ctx := context.WithValue(request.Context(), requestInfoKey{}, info)
+
+		recorder := &statusRecorder{ResponseWriter: response}
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		s.Logger.LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", request.Method),
+			slog.String("path", request.URL.Path),
+			slog.Int("status", recorder.status),
+			slog.Int64("size", recorder.size),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// logSignatures logs created signatures at debug level and adds the last counter to the log records of the
+// request. The signed data is only logged with Server.LogPayloads.
+func (s *Server) logSignatures(ctx context.Context, signatures ...domain.Signature) {
+	if len(signatures) == 0 {
+		return
+	}
+	annotateCounter(ctx, signatures[len(signatures)-1].Counter)
+	if !s.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	for _, signature := range signatures {
+		attrs := []slog.Attr{
+			slog.Int("signature_counter", signature.Counter),
+			slog.String("client_id", signature.ClientID),
+		}
+		if s.LogPayloads {
+			attrs = append(attrs, slog.String("signed_data", signature.SignedData))
+		}
+		s.Logger.LogAttrs(ctx, slog.LevelDebug, "signature created", attrs...)
+	}
+}
+
+// domainErrorLevel logs errors caused by the request, including requests given up by the client, as warnings and
+// all others as errors.
+func domainErrorLevel(err error) slog.Level {
+	for _, clientErr := range []error{
+		context.Canceled,
+		domain.ErrInvalidClientID,
+		domain.ErrClientNotRegistered,
+		domain.ErrTransactionNotFound,
+		domain.ErrDeviceDecommissioned,
+		domain.ErrTransactionFinished,
+		domain.ErrTransactionClient,
+		domain.ErrClientsUnsupported,
+		domain.ErrModeUnsupported,
+	} {
+		if errors.Is(err, clientErr) {
+			return slog.LevelWarn
+		}
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer collects log output. It is safe for concurrent use, http.Server logs from connection goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// logRecords parses the JSON log records written to logs.
+func logRecords(t *testing.T, logs *logBuffer) []map[string]interface{} {
+	t.Helper()
+	records := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestLog(t *testing.T) {
+	var logs logBuffer
+	s := NewServer("")
+	s.Storer = getStorerWithData(t)
+	logger, err := NewLogger(&logs, LogFormatJSON, slog.LevelDebug)
+	require.Nil(t, err)
+	s.Logger = logger
+	handler := s.Handler()
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+
+	sign := func(requestID string, clientID string) *httptest.ResponseRecorder {
+		body := `{"client_id":"` + clientID + `","data":["secret-payload"]}`
+		r := httptest.NewRequest("POST", "/api/v1/devices/"+deviceID+"/signatures:batch", strings.NewReader(body))
+		if requestID != "" {
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("request attributes", func(t *testing.T) {
+		logs.Reset()
+		w := sign("req-1", testClientID)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+
+		records := logRecords(t, &logs)
+		require.Len(t, records, 2)
+		assert.Equal(t, "signature created", records[0]["msg"])
+		assert.Equal(t, "request", records[1]["msg"])
+		for _, record := range records {
+			assert.Equal(t, "req-1", record[logKeyRequestID])
+			assert.Equal(t, deviceID, record[logKeyDeviceID])
+			assert.Equal(t, "RSA", record[logKeyAlgorithm])
+			assert.Equal(t, float64(0), record[logKeyCounter])
+		}
+		assert.Equal(t, float64(http.StatusOK), records[1]["status"])
+		assert.NotContains(t, logs.String(), "secret-payload", "payloads are not logged by default")
+	})
+	t.Run("payloads", func(t *testing.T) {
+		logs.Reset()
+		s.LogPayloads = true
+		defer func() { s.LogPayloads = false }()
+		require.Equal(t, http.StatusOK, sign("", testClientID).Code)
+		assert.Contains(t, logs.String(), "secret-payload")
+	})
+	t.Run("generated id", func(t *testing.T) {
+		for _, requestID := range []string{"", "with space", strings.Repeat("x", maxRequestIDLength+1)} {
+			w := sign(requestID, testClientID)
+			generated := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, generated)
+			assert.NotEqual(t, requestID, generated)
+		}
+	})
+	t.Run("error body", func(t *testing.T) {
+		logs.Reset()
+		w := sign("req-2", "unknown-client")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		resp := ErrorResponse{}
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "req-2", resp.RequestID)
+
+		records := logRecords(t, &logs)
+		require.Len(t, records, 2)
+		assert.Equal(t, "WARN", records[0]["level"])
+		assert.Equal(t, "req-2", records[0][logKeyRequestID])
+		assert.Equal(t, deviceID, records[0][logKeyDeviceID])
+		assert.NotContains(t, records[0], logKeyCounter)
+	})
+	t.Run("level", func(t *testing.T) {
+		logs.Reset()
+		logger, err := NewLogger(&logs, LogFormatText, slog.LevelWarn)
+		require.Nil(t, err)
+		s.Logger = logger
+		require.Equal(t, http.StatusOK, sign("req-3", testClientID).Code)
+		assert.Empty(t, logs.String())
+
+		require.Equal(t, http.StatusForbidden, sign("req-4", "unknown-client").Code)
+		assert.Contains(t, logs.String(), "request_id=req-4")
+	})
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := NewLogger(&logs, "xml", slog.LevelInfo)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// keyGenerationBuckets cover the key generation latency, RSA key pairs take up to seconds.
+var keyGenerationBuckets = []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// unmatchedRoute labels requests that did not match any route.
+const unmatchedRoute = "unmatched"
+
+// serverMetrics are the metrics served on /metrics. They also receive the measurements of the devices created by
+// the server.
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	signatures      *metrics.CounterVec
+	keyGeneration   *metrics.HistogramVec
+	lockWait        *metrics.HistogramVec
+	storeDuration   *metrics.HistogramVec
+}
+
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+	return &serverMetrics{
+		registry: r,
+		requests: r.Counter("signing_http_requests_total",
+			"Handled HTTP requests by route and status.", "route", "status"),
+		requestDuration: r.Histogram("signing_http_request_duration_seconds",
+			"Latency of HTTP requests by route and status.", metrics.DefaultBuckets, "route", "status"),
+		signatures: r.Counter("signing_signatures_total",
+			"Signatures committed to the ledger by algorithm.", "algorithm"),
+		keyGeneration: r.Histogram("signing_key_generation_duration_seconds",
+			"Key pair generation latency of new devices by algorithm.", keyGenerationBuckets, "algorithm"),
+		lockWait: r.Histogram("signing_device_lock_wait_seconds",
+			"Time signing calls waited for their device by algorithm.", metrics.DefaultBuckets, "algorithm"),
+		storeDuration: r.Histogram("signing_store_operation_duration_seconds",
+			"Latency of store operations by operation.", metrics.DefaultBuckets, "operation"),
+	}
+}
+
+func (m *serverMetrics) ObserveKeyGeneration(algorithm crypto.SignatureAlgorithm, d time.Duration) {
+	m.keyGeneration.With(string(algorithm)).Observe(d.Seconds())
+}
+
+func (m *serverMetrics) ObserveLockWait(algorithm crypto.SignatureAlgorithm, d time.Duration) {
+	m.lockWait.With(string(algorithm)).Observe(d.Seconds())
+}
+
+func (m *serverMetrics) AddSignatures(algorithm crypto.SignatureAlgorithm, n int) {
+	m.signatures.With(string(algorithm)).Add(float64(n))
+}
+
+// withMetrics counts requests and their latency by the route pattern of the mux, so path parameters do not
+// create new series.
+func (s *Server) withMetrics(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: response}
+		next.ServeHTTP(recorder, request)
+
+		_, route := mux.Handler(request)
+		if route == "" {
+			route = unmatchedRoute
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		status := strconv.Itoa(recorder.status)
+		s.metrics.requests.With(route, status).Inc()
+		s.metrics.requestDuration.With(route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// store returns the Storer of the server measuring the latency of every operation and tracing it within the
+// context of the operation.
+func (s *Server) store() persistence.Storer {
+	return observedStorer{Storer: s.Storer, duration: s.metrics.storeDuration}
+}
+
+// observedStorer measures and traces the operations of a Storer.
+type observedStorer struct {
+	persistence.Storer
+	duration *metrics.HistogramVec
+}
+
+// observe starts the span of the operation and returns the function to call when it is done.
+func (o observedStorer) observe(ctx context.Context, operation string) func(err error) {
+	start := time.Now()
+	_, span := startSpan(ctx, "Storer."+operation)
+	return func(err error) {
+		o.duration.With(operation).Observe(time.Since(start).Seconds())
+		if errors.Is(err, persistence.ErrNotFound) {
+			// an expected outcome, e.g. of the existence check before creating a device
+			span.SetAttributes(attribute.Bool("not_found", true))
+		} else {
+			recordError(span, err)
+		}
+		span.End()
+	}
+}
+
+func (o observedStorer) CreateSignatureDevice(ctx context.Context, device *domain.SignatureDevice) (*domain.SignatureDevice, error) {
+	done := o.observe(ctx, "CreateSignatureDevice")
+	created, err := o.Storer.CreateSignatureDevice(ctx, device)
+	done(err)
+	return created, err
+}
+
+func (o observedStorer) ReadSignatureDevices(ctx context.Context) ([]*domain.SignatureDevice, error) {
+	done := o.observe(ctx, "ReadSignatureDevices")
+	devices, err := o.Storer.ReadSignatureDevices(ctx)
+	done(err)
+	return devices, err
+}
+
+func (o observedStorer) ReadSignatureDevice(ctx context.Context, id string) (*domain.SignatureDevice, error) {
+	done := o.observe(ctx, "ReadSignatureDevice")
+	device, err := o.Storer.ReadSignatureDevice(ctx, id)
+	done(err)
+	return device, err
+}
+
+func (o observedStorer) CreateSignatures(ctx context.Context, deviceID string, signatures []domain.Signature) error {
+	done := o.observe(ctx, "CreateSignatures")
+	err := o.Storer.CreateSignatures(ctx, deviceID, signatures)
+	done(err)
+	return err
+}
+
+func (o observedStorer) ReadSignatures(ctx context.Context, deviceID string) ([]domain.Signature, error) {
+	done := o.observe(ctx, "ReadSignatures")
+	signatures, err := o.Storer.ReadSignatures(ctx, deviceID)
+	done(err)
+	return signatures, err
+}
+
+func (o observedStorer) CreateAPIKey(ctx context.Context, key domain.APIKey) error {
+	done := o.observe(ctx, "CreateAPIKey")
+	err := o.Storer.CreateAPIKey(ctx, key)
+	done(err)
+	return err
+}
+
+func (o observedStorer) ReadAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	done := o.observe(ctx, "ReadAPIKeys")
+	keys, err := o.Storer.ReadAPIKeys(ctx)
+	done(err)
+	return keys, err
+}
+
+func (o observedStorer) ReadAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+	done := o.observe(ctx, "ReadAPIKey")
+	key, err := o.Storer.ReadAPIKey(ctx, id)
+	done(err)
+	return key, err
+}
+
+func (o observedStorer) ReadAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	done := o.observe(ctx, "ReadAPIKeyByHash")
+	key, err := o.Storer.ReadAPIKeyByHash(ctx, hash)
+	done(err)
+	return key, err
+}
+
+func (o observedStorer) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (domain.APIKey, error) {
+	done := o.observe(ctx, "RevokeAPIKey")
+	key, err := o.Storer.RevokeAPIKey(ctx, id, revokedAt)
+	done(err)
+	return key, err
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	s := NewServer("")
+	handler := s.Handler()
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+
+	do := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	require.Equal(t, http.StatusCreated, do("POST", "/api/v1/devices", `{"id":"`+deviceID+`","algorithm":"ECDSA"}`).Code)
+	require.Equal(t, http.StatusOK, do("PUT", "/api/v1/devices/"+deviceID+"/clients/"+testClientID, "").Code)
+	require.Equal(t, http.StatusOK, do("POST", "/api/v1/devices/"+deviceID+"/signatures:batch", `{"client_id":"`+testClientID+`","data":["a","b"]}`).Code)
+	require.Equal(t, http.StatusForbidden, do("POST", "/api/v1/devices/"+deviceID+"/signatures:batch", `{"client_id":"unknown","data":["a"]}`).Code)
+	require.Equal(t, http.StatusNotFound, do("GET", "/unknown", "").Code)
+
+	w := do("GET", "/metrics", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	for _, line := range []string{
+		`signing_http_requests_total{route="POST /api/v1/devices",status="201"} 1`,
+		`signing_http_requests_total{route="POST /api/v1/devices/{id}/signatures:batch",status="200"} 1`,
+		`signing_http_requests_total{route="POST /api/v1/devices/{id}/signatures:batch",status="403"} 1`,
+		`signing_http_requests_total{route="unmatched",status="404"} 1`,
+		`signing_http_request_duration_seconds_count{route="POST /api/v1/devices",status="201"} 1`,
+		`signing_signatures_total{algorithm="ECDSA"} 2`,
+		`signing_key_generation_duration_seconds_count{algorithm="ECDSA"} 1`,
+		`signing_device_lock_wait_seconds_count{algorithm="ECDSA"} 2`,
+		`signing_store_operation_duration_seconds_count{operation="CreateSignatureDevice"} 1`,
+		`signing_store_operation_duration_seconds_count{operation="CreateSignatures"} 1`,
+		`signing_store_operation_duration_seconds_count{operation="ReadSignatureDevice"} 4`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.NotContains(t, body, deviceID, "path parameters are not used as labels")
+}
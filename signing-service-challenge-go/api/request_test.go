@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// jsonBody returns the JSON encoding of payload as request body. A nil payload has an empty body.
+func jsonBody(t testing.TB, payload interface{}) io.Reader {
+	t.Helper()
+	var body bytes.Buffer
+	if payload != nil {
+		require.Nil(t, json.NewEncoder(&body).Encode(payload))
+	}
+	return &body
+}
+
+// decodeData decodes the data of a successful response into v (if not nil).
+func decodeData(t testing.TB, status int, body io.Reader, v interface{}) {
+	t.Helper()
+	if v != nil && status < 300 {
+		require.Nil(t, json.NewDecoder(body).Decode(&Response{Data: v}))
+	}
+}
+
+// newJSONRequest returns an incoming request with the JSON encoded payload as body.
+func newJSONRequest(t testing.TB, method string, url string, payload interface{}) *http.Request {
+	t.Helper()
+	return httptest.NewRequest(method, url, jsonBody(t, payload))
+}
+
+// serve sends the request to handler and decodes the data of a successful response into v (if not nil).
+func serve(t testing.TB, handler http.Handler, request *http.Request, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	decodeData(t, w.Code, w.Body, v)
+	return w
+}
+
+// serveJSON sends the JSON encoded payload to handler and decodes the data of a successful response into v (if
+// not nil).
+func serveJSON(t testing.TB, handler http.Handler, method string, url string, payload interface{}, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return serve(t, handler, newJSONRequest(t, method, url, payload), v)
+}
+
+// sendJSON sends the JSON encoded payload with client and decodes the data of a successful response into v (if not
+// nil). It returns the status code of the response.
+func sendJSON(t testing.TB, client *http.Client, method string, url string, payload interface{}, v interface{}) int {
+	t.Helper()
+	request, err := http.NewRequest(method, url, jsonBody(t, payload))
+	require.Nil(t, err)
+	response, err := client.Do(request)
+	require.Nil(t, err)
+	defer response.Body.Close()
+	decodeData(t, response.StatusCode, response.Body, v)
+	return response.StatusCode
+}
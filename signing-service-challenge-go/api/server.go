@@ -1,11 +1,24 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Response is the generic API response container.
@@ -13,51 +26,401 @@ type Response struct {
 	Data interface{} `json:"data"`
 }
 
-// SignatureRequest struct is the request payload for signature handlers
+// SignatureDeviceRequest is the request body for creating a signature device
+type SignatureDeviceRequest struct {
+	ID        string `json:"id"`
+	Label     string `json:"label"`
+	Algorithm string `json:"algorithm"`
+	// Timestamps enables RFC 3161 timestamps on all signatures of the device.
+	Timestamps bool `json:"timestamps"`
+	// Deterministic derives ECDSA nonces from key and data (RFC 6979), so signatures are reproducible.
+	Deterministic bool `json:"deterministic"`
+	// SecuredDataFormat is the encoding of the signed data, "v0" or "v1". It defaults to "v1", only devices
+	// created by the v0 endpoint default to the "v0" format of the README.
+	SecuredDataFormat string `json:"secured_data_format"`
+}
+
+// UpdateSignatureDeviceRequest is the request body for changing a signature device. Only set fields are changed.
+type UpdateSignatureDeviceRequest struct {
+	Label *string `json:"label"`
+}
+
+// PublicKeyResponse is the response struct for the public key handler
+type PublicKeyResponse struct {
+	Algorithm crypto.SignatureAlgorithm `json:"signature_algorithm"`
+	PublicKey string                    `json:"public_key"`
+}
+
+// SignatureRequest struct is the request payload for the v0 signature handler. ClientID may be omitted for devices
+// without registered clients; the v1 signing endpoints require it.
 type SignatureRequest struct {
-	ID   string `json:"id"`
-	Data string `json:"data"`
+	ID       string `json:"id"`
+	ClientID string `json:"client_id"`
+	Data     string `json:"data"`
+}
+
+// BatchSignatureRequest is the request payload for the batch signature handler
+type BatchSignatureRequest struct {
+	ClientID string   `json:"client_id"`
+	Data     []string `json:"data"`
+}
+
+// BatchSignatureResponse is the response struct for the batch signature handler
+type BatchSignatureResponse struct {
+	Signatures []SignatureResponse `json:"signatures"`
+}
+
+// BulkSignatureRequest is the request payload for the bulk signature handler
+type BulkSignatureRequest struct {
+	Items []BulkSignatureItem `json:"items"`
+}
+
+// BulkSignatureItem is a single payload to be signed by the given device
+type BulkSignatureItem struct {
+	DeviceID string `json:"device_id"`
+	ClientID string `json:"client_id"`
+	Data     string `json:"data"`
+}
+
+// BulkSignatureResponse is the response struct for the bulk signature handler. Results are in request order.
+type BulkSignatureResponse struct {
+	Results []BulkSignatureResult `json:"results"`
+}
+
+// BulkSignatureResult holds either the signature or the error of a single bulk item
+type BulkSignatureResult struct {
+	DeviceID  string             `json:"device_id"`
+	Signature *SignatureResponse `json:"signature,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// DigestSignatureRequest is the request payload for signing a digest created by the client
+type DigestSignatureRequest struct {
+	ClientID      string               `json:"client_id"`
+	HashAlgorithm crypto.HashAlgorithm `json:"hash_algorithm"`
+	Digest        []byte               `json:"digest"`
+}
+
+// APIKeyRequest is the request payload for issuing an API key. DeviceIDs optionally restricts the key to the
+// devices.
+type APIKeyRequest struct {
+	Name      string         `json:"name"`
+	Scopes    []domain.Scope `json:"scopes"`
+	DeviceIDs []string       `json:"device_ids"`
+}
+
+// APIKeyResponse is the response struct for issued API keys. Key is not returned again.
+type APIKeyResponse struct {
+	domain.APIKey
+	Key string `json:"key,omitempty"`
+}
+
+// TransactionRequest is the request payload for starting and changing transactions. State is only evaluated on
+// changes; FINISHED closes the transaction.
+type TransactionRequest struct {
+	ClientID string                  `json:"client_id"`
+	State    domain.TransactionState `json:"state"`
+	Data     string                  `json:"data"`
+}
+
+// TransactionResponse is the response struct for the transaction handlers. Signature is only set for the
+// state change performed by the request.
+type TransactionResponse struct {
+	domain.Transaction
+	TimedOut  bool               `json:"timed_out"`
+	Signature *SignatureResponse `json:"signature,omitempty"`
+}
+
+// StreamSignatureResponse is the response struct for the streaming signature handler. It documents how the
+// signed data was derived from the streamed content.
+type StreamSignatureResponse struct {
+	SignatureResponse
+	DigestAlgorithm crypto.HashAlgorithm `json:"digest_algorithm"`
+	Digest          string               `json:"digest"`
+	ContentLength   int64                `json:"content_length"`
 }
 
 // SignatureResponse is the response struct for the signature handler
 type SignatureResponse struct {
-	SignedData string `json:"signed_data"`
-	Signature  string `json:"signature"`
+	Counter    int                      `json:"counter"`
+	ClientID   string                   `json:"client_id"`
+	SignedData string                   `json:"signed_data"`
+	Format     domain.SecuredDataFormat `json:"format"`
+	Mode       domain.SigningMode       `json:"mode"`
+	Signature  string                   `json:"signature"`
+	COSESign1  string                   `json:"cose_sign1,omitempty"`
+	Timestamp  string                   `json:"timestamp,omitempty"`
+}
+
+// CBORSignatureResponse is the CBOR representation of SignatureResponse. Binary values are not base64 encoded.
+type CBORSignatureResponse struct {
+	Counter    int                      `cbor:"counter"`
+	ClientID   string                   `cbor:"client_id"`
+	SignedData string                   `cbor:"signed_data"`
+	Format     domain.SecuredDataFormat `cbor:"format"`
+	Mode       domain.SigningMode       `cbor:"mode"`
+	Signature  []byte                   `cbor:"signature"`
+	COSESign1  []byte                   `cbor:"cose_sign1,omitempty"`
+	Timestamp  []byte                   `cbor:"timestamp,omitempty"`
 }
 
 // ErrorResponse is the generic error API response container.
 type ErrorResponse struct {
 	Errors []string `json:"errors"`
+	// RequestID correlates the error with the log records of the request.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // Server manages HTTP requests and dispatches them to the appropriate services.
 type Server struct {
 	listenAddress string
 	Storer        persistence.Storer
+
+	// BulkConcurrency limits the number of devices signing in parallel for a single bulk request.
+	BulkConcurrency int
+	// TransactionTimeout is the duration after which active transactions without changes are flagged.
+	TransactionTimeout time.Duration
+	// TSA is the built-in Time-Stamp Authority served by the API. Nil disables the endpoint.
+	TSA *tsa.Authority
+	// Clock provides the time of signatures, transaction timeouts and exports.
+	Clock clock.Clock
+	// Entropy provides the randomness for the keys and signatures of new devices.
+	Entropy crypto.Entropy
+	// Algorithms are the signature algorithms new devices may use. Nil allows all supported algorithms.
+	Algorithms []crypto.SignatureAlgorithm
+	// KeySizes are the key sizes in bits of new devices by algorithm. Algorithms without size use their default.
+	KeySizes map[crypto.SignatureAlgorithm]int
+	// Timestamper timestamps signatures of devices with timestamps enabled. It defaults to the built-in TSA and
+	// can be replaced by a client of an external TSA.
+	Timestamper domain.Timestamper
+	// Logger receives the log records of the server. Loggers created with NewLogger add the request attributes.
+	Logger *slog.Logger
+	// LogPayloads adds the signed data to the debug records of created signatures.
+	LogPayloads bool
+	// TracerProvider records the spans of requests, from the handler down to signer and store. Nil disables
+	// tracing.
+	TracerProvider trace.TracerProvider
+	// Liveness and Readiness are served on /livez and /readyz. Further checks can be registered before the
+	// server runs.
+	Liveness  *health.Checker
+	Readiness *health.Checker
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout configure the HTTP server of Run and Serve.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// MaxBodyBytes limits request bodies, except streamed content. Zero disables the limit.
+	MaxBodyBytes int64
+	// TLSConfig enables HTTPS for Run and Serve. Nil serves plain HTTP. Client certificates verified against its
+	// ClientCAs authenticate the caller, see ClientIdentity.
+	TLSConfig *tls.Config
+	// ClientIdentity maps verified client certificates to the identity of the caller. Devices created by an
+	// identity are owned by it and cannot be used by other callers. Nil ignores client certificates.
+	ClientIdentity IdentityFunc
+	// RequireAPIKey requires an API key with the scope of the route on every request, sent as bearer token.
+	// Health checks, metrics and the TSA certificate stay public.
+	RequireAPIKey bool
+	// AdminKey is accepted as API key with the admin scope in addition to the stored keys, e.g. to issue the
+	// first keys. Empty disables it.
+	AdminKey string
+	// ShutdownTimeout limits the time running requests get to finish once the server shuts down.
+	ShutdownTimeout time.Duration
+
+	metrics  *serverMetrics
+	signing  inflight
+	draining atomic.Bool
 }
 
 // NewServer is a factory to instantiate a new Server.
 func NewServer(listenAddress string) *Server {
-	return &Server{
+	s := &Server{
 		listenAddress: listenAddress,
-		Storer: persistence.InMemoryStorer{
-			Devices: map[string]*domain.SignatureDevice{},
-		},
+		Storer:        persistence.NewInMemoryStorer(),
+
+		BulkConcurrency:    DefaultBulkConcurrency,
+		TransactionTimeout: DefaultTransactionTimeout,
+		Clock:              clock.System,
+		Entropy:            crypto.SystemEntropy(),
+		ReadHeaderTimeout:  DefaultReadHeaderTimeout,
+		ReadTimeout:        DefaultReadTimeout,
+		WriteTimeout:       DefaultWriteTimeout,
+		IdleTimeout:        DefaultIdleTimeout,
+		MaxBodyBytes:       DefaultMaxBodyBytes,
+		ShutdownTimeout:    DefaultShutdownTimeout,
+		ClientIdentity:     IdentityCommonName,
+		Logger:             slog.New(NewLogHandler(slog.NewTextHandler(os.Stderr, nil))),
+		metrics:            newServerMetrics(),
 		// TODO: add services / further dependencies here ...
 	}
+	s.Liveness, s.Readiness = s.newHealthCheckers()
+
+	authority, err := tsa.NewAuthority()
+	if err != nil {
+		s.Logger.Error("NewServer tsa", "err", err)
+		return s
+	}
+	s.TSA = authority
+	s.Timestamper = authority
+	return s
 }
 
-// Run registers all HandlerFuncs for the existing HTTP routes and starts the Server.
-func (s *Server) Run() error {
+// Handler registers all HandlerFuncs for the existing HTTP routes together with the scope API keys need to call
+// them.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
+	r := routes{mux: mux, scopes: map[string]domain.Scope{}}
+	read, create, write, sign, admin := domain.ScopeDevicesRead, domain.ScopeDevicesCreate, domain.ScopeDevicesWrite, domain.ScopeSign, domain.ScopeAdmin
+
+	r.handle("/api/v0/health", scopePublic, http.HandlerFunc(s.Health))
+	r.handle("GET /metrics", scopePublic, s.metrics.registry)
+	r.handle("GET /livez", scopePublic, s.Liveness)
+	r.handle("GET /readyz", scopePublic, s.Readiness)
+
+	r.handle("/api/v0/devices", read, http.HandlerFunc(s.GetSignatureDevices))
+	r.handle("/api/v0/devices/create", create, http.HandlerFunc(s.PostSignatureDevice))
+	r.handle("/api/v0/devices/sign", sign, s.trackSigning(s.PostSignature))
+
+	r.handle("GET /api/v1/devices", read, http.HandlerFunc(s.GetDevices))
+	r.handle("POST /api/v1/devices", create, http.HandlerFunc(s.PostDevice))
+	r.handle("GET /api/v1/devices/{id}", read, http.HandlerFunc(s.GetDevice))
+	r.handle("PATCH /api/v1/devices/{id}", write, http.HandlerFunc(s.PatchDevice))
+	r.handle("DELETE /api/v1/devices/{id}", write, http.HandlerFunc(s.DeleteDevice))
+	r.handle("GET /api/v1/devices/{id}/public-key", read, http.HandlerFunc(s.GetPublicKey))
+
+	r.handle("POST /api/v1/devices/{id}/signatures:batch", sign, s.trackSigning(s.PostSignatureBatch))
+	r.handle(streamRoute, sign, s.trackSigning(s.PostSignatureStream))
+	r.handle("POST /api/v1/devices/{id}/signatures:prehashed", sign, s.trackSigning(s.PostSignatureDigest))
+	r.handle("POST /api/v1/signatures:bulk", sign, s.trackSigning(s.PostSignatureBulk))
+
+	r.handle("GET /api/v1/devices/{id}/clients", read, http.HandlerFunc(s.GetClients))
+	r.handle("PUT /api/v1/devices/{id}/clients/{client_id}", write, http.HandlerFunc(s.PutClient))
+	r.handle("DELETE /api/v1/devices/{id}/clients/{client_id}", write, http.HandlerFunc(s.DeleteClient))
+
+	r.handle("GET /api/v1/devices/{id}/export", read, http.HandlerFunc(s.GetExport))
+
+	r.handle("POST /api/v1/tsa", sign, http.HandlerFunc(s.PostTimestampQuery))
+	r.handle("GET /api/v1/tsa/certificate", scopePublic, http.HandlerFunc(s.GetTSACertificate))
+
+	r.handle("GET /api/v1/devices/{id}/transactions", read, http.HandlerFunc(s.GetTransactions))
+	r.handle("POST /api/v1/devices/{id}/transactions", sign, s.trackSigning(s.PostTransaction))
+	r.handle("GET /api/v1/devices/{id}/transactions/{number}", read, http.HandlerFunc(s.GetTransaction))
+	r.handle("PUT /api/v1/devices/{id}/transactions/{number}", sign, s.trackSigning(s.PutTransaction))
+
+	r.handle("GET /api/v1/keys", admin, http.HandlerFunc(s.GetAPIKeys))
+	r.handle("POST /api/v1/keys", admin, http.HandlerFunc(s.PostAPIKey))
+	r.handle("GET /api/v1/keys/{id}", admin, http.HandlerFunc(s.GetAPIKey))
+	r.handle("DELETE /api/v1/keys/{id}", admin, http.HandlerFunc(s.DeleteAPIKey))
+
+	return s.withTracing(mux, s.withRequestLog(s.withIdentity(s.withMetrics(mux, s.withAuth(mux, r.scopes, s.withBodyLimit(mux, mux))))))
+}
+
+// deviceFromPath reads the device referenced by the {id} path parameter. If the device cannot be provided, an
+// error response is written and false is returned.
+func (s *Server) deviceFromPath(response http.ResponseWriter, request *http.Request) (*domain.SignatureDevice, bool) {
+	annotateDevice(request.Context(), request.PathValue("id"), "")
+	sd, err := s.store().ReadSignatureDevice(request.Context(), request.PathValue("id"))
+	if errors.Is(err, persistence.ErrNotFound) {
+		writeError(response, request, http.StatusNotFound, []string{
+			http.StatusText(http.StatusNotFound),
+		})
+		return nil, false
+	}
+	if err != nil {
+		s.Logger.WarnContext(request.Context(), "read device", "path", request.URL.Path, "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return nil, false
+	}
+	annotateDevice(request.Context(), sd.ID.String(), sd.Algorithm)
+	if !deviceAccessible(response, request, sd) {
+		return nil, false
+	}
+	return sd, true
+}
+
+// writeDomainError maps errors of the domain to HTTP errors. Unknown errors are internal errors.
+func writeDomainError(response http.ResponseWriter, request *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidClientID):
+		writeError(response, request, http.StatusBadRequest, []string{
+			domain.ErrInvalidClientID.Error(),
+		})
+	case errors.Is(err, domain.ErrClientNotRegistered):
+		writeError(response, request, http.StatusForbidden, []string{
+			domain.ErrClientNotRegistered.Error(),
+		})
+	case errors.Is(err, domain.ErrTransactionClient):
+		writeError(response, request, http.StatusForbidden, []string{
+			domain.ErrTransactionClient.Error(),
+		})
+	case errors.Is(err, domain.ErrTransactionNotFound):
+		writeError(response, request, http.StatusNotFound, []string{
+			domain.ErrTransactionNotFound.Error(),
+		})
+	case errors.Is(err, domain.ErrDeviceDecommissioned):
+		writeError(response, request, http.StatusConflict, []string{
+			domain.ErrDeviceDecommissioned.Error(),
+		})
+	case errors.Is(err, domain.ErrTransactionFinished):
+		writeError(response, request, http.StatusConflict, []string{
+			domain.ErrTransactionFinished.Error(),
+		})
+	case errors.Is(err, domain.ErrClientsUnsupported):
+		writeError(response, request, http.StatusConflict, []string{
+			domain.ErrClientsUnsupported.Error(),
+		})
+	case errors.Is(err, domain.ErrModeUnsupported):
+		writeError(response, request, http.StatusConflict, []string{
+			domain.ErrModeUnsupported.Error(),
+		})
+	default:
+		writeError(response, request, http.StatusInternalServerError, []string{
+			http.StatusText(http.StatusInternalServerError),
+		})
+	}
+}
 
-	mux.Handle("/api/v0/health", http.HandlerFunc(s.Health))
+// algorithmEnabled reports whether new devices may use the algorithm.
+func (s *Server) algorithmEnabled(algorithm string) bool {
+	if !crypto.IsSupportedAlgorithm(algorithm) {
+		return false
+	}
+	if s.Algorithms == nil {
+		return true
+	}
+	for _, enabled := range s.Algorithms {
+		if string(enabled) == algorithm {
+			return true
+		}
+	}
+	return false
+}
 
-	mux.Handle("/api/v0/devices", http.HandlerFunc(s.GetSignatureDevices))
-	mux.Handle("/api/v0/devices/create", http.HandlerFunc(s.PostSignatureDevice))
-	mux.Handle("/api/v0/devices/sign", http.HandlerFunc(s.PostSignature))
+// deviceOptions returns the options for a new device of the server. Devices are owned by the caller.
+func (s *Server) deviceOptions(ctx context.Context, payload SignatureDeviceRequest) []domain.Option {
+	options := []domain.Option{domain.WithClock(s.Clock), domain.WithEntropy(s.Entropy), domain.WithMetrics(s.metrics)}
+	if identity := identityFrom(ctx); identity != "" {
+		options = append(options, domain.WithOwner(identity))
+	}
+	if bits := s.KeySizes[crypto.SignatureAlgorithm(payload.Algorithm)]; bits != 0 {
+		options = append(options, domain.WithKeySize(bits))
+	}
+	if payload.SecuredDataFormat != "" {
+		options = append(options, domain.WithSecuredDataFormat(domain.SecuredDataFormat(payload.SecuredDataFormat)))
+	}
+	if payload.Deterministic {
+		options = append(options, domain.WithDeterministicSignatures())
+	}
+	return options
+}
 
-	return http.ListenAndServe(s.listenAddress, mux)
+// commitSignatures returns a domain.CommitFunc that appends signatures to the ledger of the device.
+func (s *Server) commitSignatures(deviceID string) domain.CommitFunc {
+	return func(ctx context.Context, signatures []domain.Signature) error {
+		return s.store().CreateSignatures(ctx, deviceID, signatures)
+	}
 }
 
 // WriteInternalError writes a default internal error message as an HTTP response.
@@ -72,7 +435,8 @@ func WriteErrorResponse(w http.ResponseWriter, code int, errors []string) {
 	w.WriteHeader(code)
 
 	errorResponse := ErrorResponse{
-		Errors: errors,
+		Errors:    errors,
+		RequestID: w.Header().Get(RequestIDHeader),
 	}
 
 	bytes, err := json.Marshal(errorResponse)
@@ -99,3 +463,16 @@ func WriteAPIResponse(w http.ResponseWriter, code int, data interface{}) {
 
 	w.Write(bytes)
 }
+
+// newSignatureResponse maps a ledger entry to its API representation.
+func newSignatureResponse(signature domain.Signature) SignatureResponse {
+	return SignatureResponse{
+		Counter:    signature.Counter,
+		ClientID:   signature.ClientID,
+		SignedData: signature.SignedData,
+		Format:     signature.Format,
+		Mode:       signature.Mode,
+		Signature:  signature.Signature,
+		Timestamp:  base64.StdEncoding.EncodeToString(signature.Timestamp),
+	}
+}
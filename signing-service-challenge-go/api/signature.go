@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxBatchSize limits the number of payloads signed by a single batch request.
+	MaxBatchSize = 1000
+	// MaxBulkSize limits the number of items signed by a single bulk request.
+	MaxBulkSize = 1000
+	// DefaultBulkConcurrency is the default number of items of a bulk request signed in parallel.
+	DefaultBulkConcurrency = 16
+)
+
+// StreamDigestAlgorithm is the hash algorithm used for streamed content.
+const StreamDigestAlgorithm = crypto.HashSHA256
+
+// Error messages of failed bulk items.
+const (
+	bulkErrorInvalidDevice  = "invalid device id"
+	bulkErrorUnknownDevice  = "device not found"
+	bulkErrorClient         = "client not registered"
+	bulkErrorClientMissing  = errClientIDMissing
+	bulkErrorDecommissioned = "device decommissioned"
+	bulkErrorSign           = "signature creation failed"
+	bulkErrorInternal       = "internal error"
+)
+
+// PostSignatureBatch signs an ordered list of payloads with a single device. The signatures get consecutive
+// counters and are chained to each other. Either all signatures are persisted or none.
+func (s *Server) PostSignatureBatch(response http.ResponseWriter, request *http.Request) {
+	payload := BatchSignatureRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostSignatureBatch decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if len(payload.Data) == 0 || len(payload.Data) > MaxBatchSize {
+		writeError(response, request, http.StatusBadRequest, []string{
+			fmt.Sprintf("batch has to contain between 1 and %d entries", MaxBatchSize),
+		})
+		return
+	}
+	if !requireClientID(response, request, payload.ClientID) {
+		return
+	}
+
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	signatures, err := sd.SignBatch(request.Context(), payload.ClientID, payload.Data, s.commitSignatures(sd.ID.String()))
+	if err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PostSignatureBatch sign", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+	s.logSignatures(request.Context(), signatures...)
+
+	resp := BatchSignatureResponse{
+		Signatures: make([]SignatureResponse, len(signatures)),
+	}
+	for i, signature := range signatures {
+		resp.Signatures[i] = newSignatureResponse(signature)
+	}
+
+	writeResponse(response, request, http.StatusOK, resp)
+}
+
+// PostSignatureBulk signs one payload per item, each with its own device. Items are processed concurrently with
+// at most Server.BulkConcurrency in parallel; items for the same device get counters in no particular order.
+// A failed item does not fail the request, its error is reported in the item's result instead.
+func (s *Server) PostSignatureBulk(response http.ResponseWriter, request *http.Request) {
+	payload := BulkSignatureRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostSignatureBulk decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if len(payload.Items) == 0 || len(payload.Items) > MaxBulkSize {
+		writeError(response, request, http.StatusBadRequest, []string{
+			fmt.Sprintf("bulk has to contain between 1 and %d items", MaxBulkSize),
+		})
+		return
+	}
+
+	concurrency := s.BulkConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+	results := make([]BulkSignatureResult, len(payload.Items))
+	var wg sync.WaitGroup
+	for i, item := range payload.Items {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, item BulkSignatureItem) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			results[i] = s.signBulkItem(request.Context(), item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	writeResponse(response, request, http.StatusOK, BulkSignatureResponse{Results: results})
+}
+
+// signBulkItem signs a single bulk item and maps failures to the item result.
+func (s *Server) signBulkItem(ctx context.Context, item BulkSignatureItem) BulkSignatureResult {
+	result := BulkSignatureResult{DeviceID: item.DeviceID}
+	if item.ClientID == "" {
+		result.Error = bulkErrorClientMissing
+		return result
+	}
+
+	if _, err := uuid.Parse(item.DeviceID); err != nil {
+		result.Error = bulkErrorInvalidDevice
+		return result
+	}
+
+	sd, err := s.store().ReadSignatureDevice(ctx, item.DeviceID)
+	if errors.Is(err, persistence.ErrNotFound) {
+		result.Error = bulkErrorUnknownDevice
+		return result
+	}
+	if err != nil {
+		// store failures and canceled requests are worth a retry, unlike an unknown device
+		s.Logger.ErrorContext(ctx, "PostSignatureBulk read device", "device_id", item.DeviceID, "err", err)
+		result.Error = bulkErrorInternal
+		return result
+	}
+	if reason := deviceAccess(ctx, sd); reason != "" {
+		result.Error = reason
+		return result
+	}
+
+	signature, err := sd.Sign(ctx, item.ClientID, item.Data, s.commitSignatures(item.DeviceID))
+	if errors.Is(err, domain.ErrClientNotRegistered) {
+		result.Error = bulkErrorClient
+		return result
+	}
+	if errors.Is(err, domain.ErrDeviceDecommissioned) {
+		result.Error = bulkErrorDecommissioned
+		return result
+	}
+	if err != nil {
+		s.Logger.Log(ctx, domainErrorLevel(err), "PostSignatureBulk sign", "device_id", item.DeviceID, "err", err)
+		result.Error = bulkErrorSign
+		return result
+	}
+	s.Logger.DebugContext(ctx, "PostSignatureBulk signed", "device_id", item.DeviceID, "signature_counter", signature.Counter)
+	resp := newSignatureResponse(signature)
+	result.Signature = &resp
+	return result
+}
+
+// PostSignatureStream signs content of arbitrary size sent as raw request body on behalf of the client given by
+// the client_id query parameter. The content is hashed while it is read and the signed data contains its digest
+// SHA-256:<digest_base64_encoded> instead of the content, signed in mode DIGEST. Devices in format v0 cannot
+// record the mode and reject streams.
+func (s *Server) PostSignatureStream(response http.ResponseWriter, request *http.Request) {
+	clientID := request.URL.Query().Get("client_id")
+	if !requireClientID(response, request, clientID) {
+		return
+	}
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+	if err := sd.SupportsMode(domain.SigningModeDigest); err != nil {
+		// reject before the content is transferred
+		writeDomainError(response, request, err)
+		return
+	}
+
+	// hash before signing so the device is not locked while the content is transferred
+	hash := sha256.New()
+	contentLength, err := io.Copy(hash, s.streamBody(response, request))
+	if err != nil {
+		s.Logger.WarnContext(request.Context(), "PostSignatureStream read body", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	digest := hash.Sum(nil)
+	if err := s.extendWriteDeadline(response); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostSignatureStream write deadline", "err", err)
+	}
+
+	signature, err := sd.SignDigest(request.Context(), clientID, StreamDigestAlgorithm, digest, s.commitSignatures(sd.ID.String()))
+	if err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PostSignatureStream sign", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+	s.logSignatures(request.Context(), signature)
+
+	writeResponse(response, request, http.StatusOK, StreamSignatureResponse{
+		SignatureResponse: newSignatureResponse(signature),
+		DigestAlgorithm:   StreamDigestAlgorithm,
+		Digest:            base64.StdEncoding.EncodeToString(digest),
+		ContentLength:     contentLength,
+	})
+}
+
+// PostSignatureDigest signs a digest the client created itself, so the content never has to be transferred.
+// The signed data contains <hash_algorithm>:<digest_base64_encoded> instead of the content, signed in mode DIGEST.
+// Devices in format v0 cannot record the mode and reject digests.
+func (s *Server) PostSignatureDigest(response http.ResponseWriter, request *http.Request) {
+	payload := DigestSignatureRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostSignatureDigest decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if err := crypto.ValidateDigest(payload.HashAlgorithm, payload.Digest); err != nil {
+		writeError(response, request, http.StatusBadRequest, []string{
+			fmt.Sprintf("invalid digest for hash algorithm %q", payload.HashAlgorithm),
+		})
+		return
+	}
+	if !requireClientID(response, request, payload.ClientID) {
+		return
+	}
+
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	signature, err := sd.SignDigest(request.Context(), payload.ClientID, payload.HashAlgorithm, payload.Digest, s.commitSignatures(sd.ID.String()))
+	if err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PostSignatureDigest sign", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+	s.logSignatures(request.Context(), signature)
+
+	writeResponse(response, request, http.StatusOK, newSignatureResponse(signature))
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostSignatureBatch(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	t.Run("default", func(t *testing.T) {
+		s := NewServer(":8080")
+		storer := getStorerWithData(t)
+		s.Storer = storer
+		raw, err := json.Marshal(BatchSignatureRequest{ClientID: testClientID, Data: []string{"a", "b", "c"}})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:batch", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data BatchSignatureResponse `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Equal(t, 3, len(body.Data.Signatures))
+		for i, signature := range body.Data.Signatures {
+			assert.Equal(t, i, signature.Counter)
+		}
+
+		ledger, err := storer.ReadSignatures(context.Background(), deviceID)
+		require.Nil(t, err)
+		assert.Equal(t, 3, len(ledger), "batch has to be persisted")
+	})
+	t.Run("empty", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+		raw, err := json.Marshal(BatchSignatureRequest{})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:batch", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+	t.Run("unknown device", func(t *testing.T) {
+		s := NewServer(":8080")
+		raw, err := json.Marshal(BatchSignatureRequest{ClientID: testClientID, Data: []string{"a"}})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+uuid.NewString()+"/signatures:batch", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+	t.Run("method not allowed", func(t *testing.T) {
+		s := NewServer(":8080")
+
+		r := httptest.NewRequest("GET", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:batch", nil)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Result().StatusCode)
+	})
+}
+
+func TestPostSignatureBulk(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.BulkConcurrency = 2
+		storer := getStorerWithData(t)
+		s.Storer = storer
+
+		payload := BulkSignatureRequest{}
+		for id := range storer.Devices {
+			payload.Items = append(payload.Items,
+				BulkSignatureItem{DeviceID: id, ClientID: testClientID, Data: "a"},
+				BulkSignatureItem{DeviceID: id, ClientID: testClientID, Data: "b"},
+			)
+		}
+		raw, err := json.Marshal(payload)
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/signatures:bulk", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data BulkSignatureResponse `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Equal(t, len(payload.Items), len(body.Data.Results))
+		for i, result := range body.Data.Results {
+			assert.Equal(t, payload.Items[i].DeviceID, result.DeviceID, "results keep the request order")
+			assert.Empty(t, result.Error)
+			assert.NotNil(t, result.Signature)
+		}
+		for id := range storer.Devices {
+			ledger, err := storer.ReadSignatures(context.Background(), id)
+			require.Nil(t, err)
+			assert.Equal(t, 2, len(ledger))
+		}
+	})
+	t.Run("partial failure", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+		payload := BulkSignatureRequest{Items: []BulkSignatureItem{
+			{DeviceID: "38da2fb6-c293-4a63-a349-835330f0aca7", ClientID: testClientID, Data: "a"},
+			{DeviceID: uuid.NewString(), ClientID: testClientID, Data: "b"},
+			{DeviceID: "not-a-uuid", ClientID: testClientID, Data: "c"},
+		}}
+		raw, err := json.Marshal(payload)
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/signatures:bulk", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data BulkSignatureResponse `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Equal(t, 3, len(body.Data.Results))
+		assert.NotNil(t, body.Data.Results[0].Signature)
+		assert.Equal(t, bulkErrorUnknownDevice, body.Data.Results[1].Error)
+		assert.Nil(t, body.Data.Results[1].Signature)
+		assert.Equal(t, bulkErrorInvalidDevice, body.Data.Results[2].Error)
+	})
+	t.Run("store failure", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = unreachableStorer{Storer: getStorerWithData(t)}
+		payload := BulkSignatureRequest{Items: []BulkSignatureItem{
+			{DeviceID: "38da2fb6-c293-4a63-a349-835330f0aca7", ClientID: testClientID, Data: "a"},
+		}}
+
+		result := BulkSignatureResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, s.Handler(), "POST", "http://localhost:8080/api/v1/signatures:bulk", payload, &result).Code)
+		require.Equal(t, 1, len(result.Results))
+		assert.Equal(t, bulkErrorInternal, result.Results[0].Error, "not reported as invalid device id")
+		assert.Nil(t, result.Results[0].Signature)
+	})
+	t.Run("empty", func(t *testing.T) {
+		s := NewServer(":8080")
+		raw, err := json.Marshal(BulkSignatureRequest{})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/signatures:bulk", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestPostSignatureStream(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	t.Run("default", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+		content := bytes.Repeat([]byte("large_document\n"), 100000)
+		digest := sha256.Sum256(content)
+		encodedDigest := base64.StdEncoding.EncodeToString(digest[:])
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:stream?client_id="+testClientID, bytes.NewReader(content))
+		r.Header.Set("Content-Type", "application/octet-stream")
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data StreamSignatureResponse `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, StreamDigestAlgorithm, body.Data.DigestAlgorithm)
+		assert.Equal(t, encodedDigest, body.Data.Digest)
+		assert.Equal(t, int64(len(content)), body.Data.ContentLength)
+		assert.Equal(t, 0, body.Data.Counter)
+
+		base64ID := base64.StdEncoding.EncodeToString([]byte(deviceID))
+		expected := fmt.Sprintf("v1:1:0,10:%s,6:DIGEST,52:SHA-256:%s,48:%s,", testClientID, encodedDigest, base64ID)
+		assert.Equal(t, expected, body.Data.SignedData)
+		assert.Equal(t, domain.SigningModeDigest, body.Data.Mode)
+	})
+	t.Run("unknown device", func(t *testing.T) {
+		s := NewServer(":8080")
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+uuid.NewString()+"/signatures:stream?client_id="+testClientID, bytes.NewBufferString("content"))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+	t.Run("without client id", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:stream", bytes.NewBufferString("content"))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Contains(t, w.Body.String(), errClientIDMissing)
+	})
+}
+
+func TestPostSignatureDigest(t *testing.T) {
+	deviceID := "1727d3e0-e1ae-410c-97d2-70da0ae0abc4"
+	digest := sha512.Sum512([]byte("receipt"))
+	t.Run("default", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+		raw, err := json.Marshal(DigestSignatureRequest{ClientID: testClientID, HashAlgorithm: crypto.HashSHA512, Digest: digest[:]})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:prehashed", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body := struct {
+			Data SignatureResponse `json:"data"`
+		}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+		base64ID := base64.StdEncoding.EncodeToString([]byte(deviceID))
+		expected := fmt.Sprintf("v1:1:0,10:%s,6:DIGEST,96:SHA-512:%s,48:%s,", testClientID, base64.StdEncoding.EncodeToString(digest[:]), base64ID)
+		assert.Equal(t, expected, body.Data.SignedData)
+		assert.Equal(t, domain.SigningModeDigest, body.Data.Mode)
+	})
+	t.Run("format v0", func(t *testing.T) {
+		s := NewServer(":8080")
+		handler := s.Handler()
+		id := uuid.NewString()
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "POST", "http://localhost:8080/api/v0/devices/create?algorithm=ECDSA&id="+id, nil, nil).Code)
+
+		payload := DigestSignatureRequest{ClientID: testClientID, HashAlgorithm: crypto.HashSHA512, Digest: digest[:]}
+		w := serveJSON(t, handler, "POST", "http://localhost:8080/api/v1/devices/"+id+"/signatures:prehashed", payload, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), domain.ErrModeUnsupported.Error())
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+id+"/signatures:stream?client_id="+testClientID, bytes.NewBufferString("content"))
+		w = serve(t, handler, r, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("length mismatch", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+		raw, err := json.Marshal(DigestSignatureRequest{ClientID: testClientID, HashAlgorithm: crypto.HashSHA256, Digest: digest[:]})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:prehashed", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+	t.Run("unknown hash algorithm", func(t *testing.T) {
+		s := NewServer(":8080")
+		s.Storer = getStorerWithData(t)
+		raw, err := json.Marshal(DigestSignatureRequest{ClientID: testClientID, HashAlgorithm: "MD5", Digest: digest[:16]})
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/devices/"+deviceID+"/signatures:prehashed", bytes.NewBuffer(raw))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestV1SigningWithoutClientID(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	baseURL := "http://localhost:8080/api/v1/devices/" + deviceID
+	s := NewServer(":8080")
+	storer := getStorerWithData(t)
+	s.Storer = storer
+	handler := s.Handler()
+
+	requests := map[string]interface{}{
+		"/signatures:batch":     BatchSignatureRequest{Data: []string{"a"}},
+		"/signatures:prehashed": DigestSignatureRequest{HashAlgorithm: crypto.HashSHA256, Digest: make([]byte, sha256.Size)},
+		"/transactions":         TransactionRequest{Data: "basket"},
+	}
+	for path, payload := range requests {
+		w := serveJSON(t, handler, "POST", baseURL+path, payload, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), errClientIDMissing, path)
+	}
+	payload := TransactionRequest{State: domain.TransactionFinished}
+	assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "PUT", baseURL+"/transactions/1", payload, nil).Code)
+
+	result := BulkSignatureResponse{}
+	bulk := BulkSignatureRequest{Items: []BulkSignatureItem{{DeviceID: deviceID, Data: "a"}}}
+	require.Equal(t, http.StatusOK, serveJSON(t, handler, "POST", "http://localhost:8080/api/v1/signatures:bulk", bulk, &result).Code)
+	require.Len(t, result.Results, 1)
+	assert.Equal(t, bulkErrorClientMissing, result.Results[0].Error)
+
+	ledger, err := storer.ReadSignatures(context.Background(), deviceID)
+	require.Nil(t, err)
+	assert.Empty(t, ledger)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans of the api.
+const tracerName = "github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+
+// withTracing starts the server span of every request with otelhttp. It continues the trace of the W3C
+// traceparent header and is named after the route pattern of the mux. Without Server.TracerProvider no spans are
+// recorded.
+func (s *Server) withTracing(mux *http.ServeMux, next http.Handler) http.Handler {
+	if s.TracerProvider == nil {
+		return next
+	}
+	route := func(request *http.Request) string {
+		_, route := mux.Handler(request)
+		if route == "" {
+			return unmatchedRoute
+		}
+		return route
+	}
+	annotated := http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		span := trace.SpanFromContext(request.Context())
+		span.SetAttributes(semconv.HTTPRoute(route(request)))
+		next.ServeHTTP(response, request)
+		span.SetAttributes(attribute.String(logKeyRequestID, response.Header().Get(RequestIDHeader)))
+	})
+	return otelhttp.NewHandler(annotated, "",
+		otelhttp.WithTracerProvider(s.TracerProvider),
+		otelhttp.WithPropagators(propagation.TraceContext{}),
+		otelhttp.WithSpanNameFormatter(func(_ string, request *http.Request) string {
+			return "HTTP " + route(request)
+		}),
+	)
+}
+
+// startSpan starts a child of the span in ctx with the tracer provider of that span. Without a span in ctx
+// nothing is recorded.
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// recordError marks the span as failed with err. A nil error is ignored.
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanAttributes returns the attributes of the span by key.
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	s := NewServer("")
+	s.Storer = getStorerWithData(t)
+	s.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	handler := s.Handler()
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	sign := func(handler http.Handler, clientID string) *httptest.ResponseRecorder {
+		body := `{"client_id":"` + clientID + `","data":["a","b"]}`
+		r := httptest.NewRequest("POST", "/api/v1/devices/"+deviceID+"/signatures:batch", strings.NewReader(body))
+		r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	// byName indexes the recorded spans; names that occur more than once keep the last span.
+	byName := func() map[string]tracetest.SpanStub {
+		spans := map[string]tracetest.SpanStub{}
+		for _, span := range exporter.GetSpans() {
+			assert.Equal(t, traceID, span.SpanContext.TraceID().String(), span.Name)
+			spans[span.Name] = span
+		}
+		return spans
+	}
+
+	t.Run("span structure", func(t *testing.T) {
+		exporter.Reset()
+		require.Equal(t, http.StatusOK, sign(handler, testClientID).Code)
+
+		spans := byName()
+		root := spans["HTTP POST /api/v1/devices/{id}/signatures:batch"]
+		require.True(t, root.SpanContext.IsValid())
+		assert.Equal(t, "00f067aa0ba902b7", root.Parent.SpanID().String(), "the trace of the caller is continued")
+		assert.True(t, root.Parent.IsRemote())
+		attributes := spanAttributes(root)
+		assert.Equal(t, int64(http.StatusOK), attributes["http.status_code"].AsInt64())
+		assert.Equal(t, "POST /api/v1/devices/{id}/signatures:batch", attributes["http.route"].AsString())
+		assert.NotEmpty(t, attributes[logKeyRequestID].AsString())
+
+		parents := map[string]string{
+			"Storer.ReadSignatureDevice": root.Name,
+			"SignatureDevice.SignBatch":  root.Name,
+			"SignatureDevice.lock":       "SignatureDevice.SignBatch",
+			"Signer.Sign":                "SignatureDevice.SignBatch",
+			"SignatureDevice.commit":     "SignatureDevice.SignBatch",
+			"Storer.CreateSignatures":    "SignatureDevice.commit",
+		}
+		for name, parent := range parents {
+			require.Contains(t, spans, name)
+			assert.Equal(t, spans[parent].SpanContext.SpanID(), spans[name].Parent.SpanID(), name)
+		}
+		assert.Len(t, exporter.GetSpans(), len(parents)+2, "one Signer.Sign span per payload")
+		assert.Equal(t, deviceID, spanAttributes(spans["SignatureDevice.SignBatch"])["device_id"].AsString())
+		assert.Equal(t, "RSA", spanAttributes(spans["Signer.Sign"])["algorithm"].AsString())
+	})
+	t.Run("error", func(t *testing.T) {
+		exporter.Reset()
+		require.Equal(t, http.StatusForbidden, sign(handler, "unknown").Code)
+
+		spans := byName()
+		assert.Equal(t, codes.Error, spans["SignatureDevice.SignBatch"].Status.Code)
+		assert.Contains(t, spans["SignatureDevice.SignBatch"].Status.Description, "not registered")
+		assert.NotContains(t, spans, "Signer.Sign")
+	})
+	t.Run("log", func(t *testing.T) {
+		var logs logBuffer
+		logger, err := NewLogger(&logs, LogFormatJSON, slog.LevelInfo)
+		require.Nil(t, err)
+		s.Logger = logger
+
+		require.Equal(t, http.StatusOK, sign(s.Handler(), testClientID).Code)
+		records := logRecords(t, &logs)
+		require.NotEmpty(t, records)
+		assert.Equal(t, traceID, records[len(records)-1][logKeyTraceID])
+	})
+	t.Run("disabled", func(t *testing.T) {
+		exporter.Reset()
+		untraced := NewServer("")
+		untraced.Storer = getStorerWithData(t)
+		require.Equal(t, http.StatusOK, sign(untraced.Handler(), testClientID).Code)
+		assert.Empty(t, exporter.GetSpans())
+	})
+}
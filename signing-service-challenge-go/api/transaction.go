@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// DefaultTransactionTimeout is the default duration after which unchanged active transactions are flagged.
+const DefaultTransactionTimeout = 30 * time.Minute
+
+// GetTransactions lists the transactions of a device. With state=ACTIVE only open transactions are listed.
+func (s *Server) GetTransactions(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	activeOnly := domain.TransactionState(request.URL.Query().Get("state")) == domain.TransactionActive
+	transactions := sd.Transactions(activeOnly)
+
+	now := s.Clock.Now()
+	resp := make([]TransactionResponse, len(transactions))
+	for i, tx := range transactions {
+		resp[i] = s.newTransactionResponse(tx, now)
+	}
+
+	writeResponse(response, request, http.StatusOK, resp)
+}
+
+// GetTransaction retrieves a single transaction of a device.
+func (s *Server) GetTransaction(response http.ResponseWriter, request *http.Request) {
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+	number, ok := transactionNumberFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	tx, err := sd.Transaction(number)
+	if err != nil {
+		writeDomainError(response, request, err)
+		return
+	}
+
+	writeResponse(response, request, http.StatusOK, s.newTransactionResponse(tx, s.Clock.Now()))
+}
+
+// PostTransaction starts a new transaction on a device and signs the start.
+func (s *Server) PostTransaction(response http.ResponseWriter, request *http.Request) {
+	payload := TransactionRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PostTransaction decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if !requireClientID(response, request, payload.ClientID) {
+		return
+	}
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	tx, signature, err := sd.StartTransaction(request.Context(), payload.ClientID, payload.Data, s.commitSignatures(sd.ID.String()))
+	if err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PostTransaction start", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+	s.logSignatures(request.Context(), signature)
+
+	resp := s.newTransactionResponse(tx, s.Clock.Now())
+	sig := newSignatureResponse(signature)
+	resp.Signature = &sig
+	writeResponse(response, request, http.StatusOK, resp)
+}
+
+// PutTransaction signs an update of an active transaction. A state of FINISHED finishes the transaction.
+func (s *Server) PutTransaction(response http.ResponseWriter, request *http.Request) {
+	payload := TransactionRequest{}
+	if err := DecodeRequest(request, &payload); err != nil {
+		s.Logger.WarnContext(request.Context(), "PutTransaction decode", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+	if payload.State != domain.TransactionActive && payload.State != domain.TransactionFinished {
+		writeError(response, request, http.StatusBadRequest, []string{
+			"state has to be ACTIVE or FINISHED",
+		})
+		return
+	}
+	if !requireClientID(response, request, payload.ClientID) {
+		return
+	}
+	sd, ok := s.deviceFromPath(response, request)
+	if !ok {
+		return
+	}
+	number, ok := transactionNumberFromPath(response, request)
+	if !ok {
+		return
+	}
+
+	finish := payload.State == domain.TransactionFinished
+	tx, signature, err := sd.UpdateTransaction(request.Context(), payload.ClientID, number, payload.Data, finish, s.commitSignatures(sd.ID.String()))
+	if err != nil {
+		s.Logger.Log(request.Context(), domainErrorLevel(err), "PutTransaction update", "err", err)
+		writeDomainError(response, request, err)
+		return
+	}
+	s.logSignatures(request.Context(), signature)
+
+	resp := s.newTransactionResponse(tx, s.Clock.Now())
+	sig := newSignatureResponse(signature)
+	resp.Signature = &sig
+	writeResponse(response, request, http.StatusOK, resp)
+}
+
+// newTransactionResponse maps a transaction to its API representation and flags timed out transactions.
+func (s *Server) newTransactionResponse(tx domain.Transaction, now time.Time) TransactionResponse {
+	return TransactionResponse{
+		Transaction: tx,
+		TimedOut:    tx.IsTimedOut(now, s.TransactionTimeout),
+	}
+}
+
+// transactionNumberFromPath parses the {number} path parameter and writes an error response if it is invalid.
+func transactionNumberFromPath(response http.ResponseWriter, request *http.Request) (int, bool) {
+	number, err := strconv.Atoi(request.PathValue("number"))
+	if err != nil || number < 1 {
+		writeError(response, request, http.StatusBadRequest, []string{
+			"invalid transaction number",
+		})
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionEndpoints(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	baseURL := "http://localhost:8080/api/v1/devices/" + deviceID + "/transactions"
+	s := NewServer(":8080")
+	storer := getStorerWithData(t)
+	s.Storer = storer
+	handler := s.Handler()
+
+	t.Run("start", func(t *testing.T) {
+		tx := TransactionResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "POST", baseURL, TransactionRequest{ClientID: testClientID, Data: "basket"}, &tx).Code)
+		assert.Equal(t, 1, tx.Number)
+		assert.Equal(t, domain.TransactionActive, tx.State)
+		require.NotNil(t, tx.Signature)
+		assert.Equal(t, 0, tx.Signature.Counter)
+	})
+	t.Run("update", func(t *testing.T) {
+		tx := TransactionResponse{}
+		payload := TransactionRequest{ClientID: testClientID, State: domain.TransactionActive, Data: "item"}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "PUT", baseURL+"/1", payload, &tx).Code)
+		assert.Equal(t, domain.TransactionActive, tx.State)
+		require.NotNil(t, tx.Signature)
+		assert.Equal(t, 1, tx.Signature.Counter)
+	})
+	t.Run("other client", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "PUT", "http://localhost:8080/api/v1/devices/"+deviceID+"/clients/register-2", nil, nil).Code)
+
+		payload := TransactionRequest{ClientID: "register-2", State: domain.TransactionFinished, Data: "paid"}
+		w := serveJSON(t, handler, "PUT", baseURL+"/1", payload, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), domain.ErrTransactionClient.Error())
+	})
+	t.Run("list active", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "POST", baseURL, TransactionRequest{ClientID: testClientID, Data: "second"}, nil).Code)
+
+		transactions := []TransactionResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"?state=ACTIVE", nil, &transactions).Code)
+		assert.Equal(t, 2, len(transactions))
+	})
+	t.Run("finish", func(t *testing.T) {
+		tx := TransactionResponse{}
+		payload := TransactionRequest{ClientID: testClientID, State: domain.TransactionFinished, Data: "paid"}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "PUT", baseURL+"/1", payload, &tx).Code)
+		assert.Equal(t, domain.TransactionFinished, tx.State)
+		assert.Equal(t, []int{0, 1, 3}, tx.SignatureCounters)
+	})
+	t.Run("finished", func(t *testing.T) {
+		payload := TransactionRequest{ClientID: testClientID, State: domain.TransactionActive}
+		assert.Equal(t, http.StatusConflict, serveJSON(t, handler, "PUT", baseURL+"/1", payload, nil).Code)
+	})
+	t.Run("not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serveJSON(t, handler, "GET", baseURL+"/42", nil, nil).Code)
+	})
+	t.Run("invalid number", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "GET", baseURL+"/first", nil, nil).Code)
+	})
+	t.Run("invalid state", func(t *testing.T) {
+		payload := TransactionRequest{ClientID: testClientID, State: "CANCELLED"}
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "PUT", baseURL+"/2", payload, nil).Code)
+	})
+	t.Run("timed out", func(t *testing.T) {
+		s.TransactionTimeout = -time.Second
+
+		tx := TransactionResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/2", nil, &tx).Code)
+		assert.True(t, tx.TimedOut)
+
+		tx = TransactionResponse{}
+		require.Equal(t, http.StatusOK, serveJSON(t, handler, "GET", baseURL+"/1", nil, &tx).Code)
+		assert.False(t, tx.TimedOut, "finished transactions are never flagged")
+	})
+	t.Run("ledger", func(t *testing.T) {
+		ledger, err := storer.ReadSignatures(context.Background(), deviceID)
+		require.Nil(t, err)
+		assert.Equal(t, 4, len(ledger))
+	})
+}
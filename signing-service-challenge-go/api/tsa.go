@@ -0,0 +1,57 @@
+package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+// maxTimestampQuerySize limits the size of RFC 3161 requests; a request only carries a hash and a few fields.
+const maxTimestampQuerySize = 16 << 10
+
+// PostTimestampQuery answers an RFC 3161 timestamp request (application/timestamp-query) of the built-in TSA.
+// Rejections are valid responses and answered with status 200 as RFC 3161 Section 3.4 requires.
+func (s *Server) PostTimestampQuery(response http.ResponseWriter, request *http.Request) {
+	if s.TSA == nil {
+		writeError(response, request, http.StatusNotFound, []string{
+			http.StatusText(http.StatusNotFound),
+		})
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || mediaType != tsa.ContentTypeQuery {
+		writeError(response, request, http.StatusUnsupportedMediaType, []string{
+			"content type has to be " + tsa.ContentTypeQuery,
+		})
+		return
+	}
+
+	query, err := io.ReadAll(io.LimitReader(request.Body, maxTimestampQuerySize+1))
+	if err != nil || len(query) > maxTimestampQuerySize {
+		s.Logger.WarnContext(request.Context(), "PostTimestampQuery read body", "err", err)
+		writeError(response, request, http.StatusBadRequest, []string{
+			http.StatusText(http.StatusBadRequest),
+		})
+		return
+	}
+
+	response.Header().Set("Content-Type", tsa.ContentTypeReply)
+	response.WriteHeader(http.StatusOK)
+	response.Write(s.TSA.Respond(query))
+}
+
+// GetTSACertificate returns the PEM encoded certificate of the built-in TSA to verify its tokens.
+func (s *Server) GetTSACertificate(response http.ResponseWriter, request *http.Request) {
+	if s.TSA == nil {
+		writeError(response, request, http.StatusNotFound, []string{
+			http.StatusText(http.StatusNotFound),
+		})
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/pem-certificate-chain")
+	response.WriteHeader(http.StatusOK)
+	response.Write(s.TSA.CertificatePEM())
+}
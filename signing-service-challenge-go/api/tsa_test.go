@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostTimestampQuery(t *testing.T) {
+	s := NewServer(":8080")
+	require.NotNil(t, s.TSA)
+	handler := s.Handler()
+
+	t.Run("query", func(t *testing.T) {
+		query, err := tsa.NewRequest([]byte("data"))
+		require.Nil(t, err)
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/tsa", bytes.NewReader(query))
+		r.Header.Set("Content-Type", tsa.ContentTypeQuery)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, tsa.ContentTypeReply, resp.Header.Get("Content-Type"))
+		reply, err := io.ReadAll(resp.Body)
+		require.Nil(t, err)
+		token, err := tsa.ParseResponse(query, reply)
+		require.Nil(t, err)
+		info, err := tsa.Verify(token, []byte("data"))
+		require.Nil(t, err)
+		assert.Equal(t, s.TSA.Certificate().Raw, info.Certificate.Raw)
+	})
+	t.Run("unsupported content type", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/tsa", bytes.NewReader([]byte("{}")))
+		r.Header.Set("Content-Type", ContentTypeJSON)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Result().StatusCode)
+	})
+	t.Run("certificate", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://localhost:8080/api/v1/tsa/certificate", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		block, _ := pem.Decode(w.Body.Bytes())
+		require.NotNil(t, block)
+		assert.Equal(t, s.TSA.Certificate().Raw, block.Bytes)
+	})
+}
+
+func TestTimestampedSignatures(t *testing.T) {
+	deviceID := uuid.New().String()
+	s := NewServer(":8080")
+	handler := s.Handler()
+	payload := SignatureDeviceRequest{ID: deviceID, Algorithm: "ECDSA", Timestamps: true}
+	require.Equal(t, http.StatusCreated, serveJSON(t, handler, "POST", "http://localhost:8080/api/v1/devices", payload, nil).Code)
+	require.Equal(t, http.StatusOK, serveJSON(t, handler, "PUT", "http://localhost:8080/api/v1/devices/"+deviceID+"/clients/"+testClientID, nil, nil).Code)
+
+	signature := SignatureResponse{}
+	sign := SignatureRequest{ID: deviceID, ClientID: testClientID, Data: "data"}
+	require.Equal(t, http.StatusOK, serveJSON(t, handler, "POST", "http://localhost:8080/api/v0/devices/sign", sign, &signature).Code)
+
+	rawSig, err := base64.StdEncoding.DecodeString(signature.Signature)
+	require.Nil(t, err)
+	token, err := base64.StdEncoding.DecodeString(signature.Timestamp)
+	require.Nil(t, err)
+	_, err = tsa.Verify(token, rawSig)
+	assert.Nil(t, err)
+
+	t.Run("without timestamper", func(t *testing.T) {
+		s.Timestamper = nil
+		payload := SignatureDeviceRequest{ID: uuid.New().String(), Algorithm: "ECDSA", Timestamps: true}
+		assert.Equal(t, http.StatusBadRequest, serveJSON(t, handler, "POST", "http://localhost:8080/api/v1/devices", payload, nil).Code)
+	})
+}
@@ -0,0 +1,30 @@
+// Package buildinfo holds the version of the binary. Release builds set it with the linker:
+//
+//	go build -ldflags "-X github.com/fiskaly/coding-challenges/signing-service-challenge/buildinfo.Version=v1.2.0 \
+//		-X github.com/fiskaly/coding-challenges/signing-service-challenge/buildinfo.Commit=$(git rev-parse HEAD)"
+package buildinfo
+
+import "runtime/debug"
+
+var (
+	// Version is the release version of the binary.
+	Version = "dev"
+	// Commit is the VCS revision the binary was built from. If it is not set at build time, Revision falls back
+	// to the revision recorded by the Go toolchain.
+	Commit = ""
+)
+
+// Revision returns the commit the binary was built from or "unknown".
+func Revision() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,20 @@
+package buildinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevision(t *testing.T) {
+	t.Run("set at build time", func(t *testing.T) {
+		defer func(commit string) { Commit = commit }(Commit)
+		Commit = "0123abc"
+		assert.Equal(t, "0123abc", Revision())
+	})
+	t.Run("fallback", func(t *testing.T) {
+		defer func(commit string) { Commit = commit }(Commit)
+		Commit = ""
+		assert.NotEmpty(t, Revision())
+	})
+}
@@ -0,0 +1,50 @@
+// Package clock abstracts the current time so that time dependent code can be tested deterministically.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the Clock of the operating system.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a deterministic Clock for tests. Every call of Now returns the current fake time and then advances it
+// by step, so consecutive events get distinct, predictable times.
+type Fake struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewFake creates a Fake starting at start.
+func NewFake(start time.Time, step time.Duration) *Fake {
+	return &Fake{now: start, step: step}
+}
+
+// Now returns the fake time and advances it by the step.
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Advance moves the fake time forward by d.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,24 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	assert.False(t, now.Before(before))
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	c := NewFake(start, time.Second)
+
+	assert.Equal(t, start, c.Now())
+	assert.Equal(t, start.Add(time.Second), c.Now())
+	c.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour+2*time.Second), c.Now())
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig"
+)
+
+// apiError is returned for requests the server answered with an error status.
+type apiError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e apiError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+// client calls the signing service API.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func newClient(cfg config) (*client, error) {
+	c := &client{
+		server: strings.TrimSuffix(cfg.Server, "/"),
+		token:  cfg.Token,
+		http:   http.DefaultClient,
+	}
+	if cfg.CAFile == "" && cfg.CertFile == "" {
+		return c, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pool, err := tlsconfig.LoadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("newClient | %w", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("newClient | %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Transport: transport}
+	return c, nil
+}
+
+// doJSON sends payload as JSON body (if not nil) and decodes the data of the response into v (if not nil).
+func (c *client) doJSON(method string, path string, payload interface{}, v interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("client %s %s | %w", method, path, err)
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	resp, err := c.do(method, path, api.ContentTypeJSON, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&api.Response{Data: v}); err != nil {
+		return fmt.Errorf("client %s %s decode | %w", method, path, err)
+	}
+	return nil
+}
+
+// do sends the request and returns the response if it has a success status. The caller has to close the body.
+func (c *client) do(method string, path string, contentType string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("client %s %s | %w", method, path, err)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("client %s %s | %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		errResp := api.ErrorResponse{}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return nil, apiError{StatusCode: resp.StatusCode, Errors: errResp.Errors}
+	}
+	return resp, nil
+}
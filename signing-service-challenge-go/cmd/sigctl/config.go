@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of sigctl.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// Environment variables overriding the config file.
+const (
+	envConfig = "SIGCTL_CONFIG"
+	envServer = "SIGCTL_SERVER"
+	envToken  = "SIGCTL_TOKEN"
+	envOutput = "SIGCTL_OUTPUT"
+)
+
+// config holds the connection settings of sigctl. Values are taken from the config file, then from the
+// environment and finally from command-line flags, each overriding the previous one.
+type config struct {
+	Server string `yaml:"server"`
+	// Token is sent as bearer token with every request.
+	Token    string `yaml:"token"`
+	ClientID string `yaml:"client_id"`
+	Output   string `yaml:"output"`
+	// CAFile verifies the server certificate instead of the system roots.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are presented as client certificate to servers authenticating clients by TLS.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// defaultConfig returns the settings used when nothing is configured.
+func defaultConfig() config {
+	return config{
+		Server: "http://localhost:8080",
+		Output: outputTable,
+	}
+}
+
+// defaultConfigPath returns $SIGCTL_CONFIG or sigctl/config.yaml in the user's config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv(envConfig); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sigctl", "config.yaml")
+}
+
+// loadConfig reads the config file at path on top of the defaults and applies the environment. A missing
+// config file is only an error if the path was given explicitly.
+func loadConfig(path string, explicit bool) (config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		content, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return config{}, fmt.Errorf("loadConfig | %w", err)
+		default:
+			if err := yaml.Unmarshal(content, &cfg); err != nil {
+				return config{}, fmt.Errorf("loadConfig %s | %w", path, err)
+			}
+		}
+	}
+
+	for env, value := range map[string]*string{envServer: &cfg.Server, envToken: &cfg.Token, envOutput: &cfg.Output} {
+		if v := os.Getenv(env); v != "" {
+			*value = v
+		}
+	}
+	return cfg, nil
+}
+
+// validate checks the settings after all sources have been applied.
+func (c config) validate() error {
+	if c.Server == "" {
+		return fmt.Errorf("server address missing")
+	}
+	if c.Output != outputTable && c.Output != outputJSON {
+		return fmt.Errorf("output has to be %s or %s", outputTable, outputJSON)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("cert file and key file have to be set together")
+	}
+	return nil
+}
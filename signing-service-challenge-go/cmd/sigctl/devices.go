@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+)
+
+// devicePath returns the API path of a device.
+func devicePath(deviceID string) string {
+	return "/api/v1/devices/" + url.PathEscape(deviceID)
+}
+
+// runDevices dispatches the device subcommands.
+func runDevices(e *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("devices: missing subcommand: %w", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		return devicesList(e, args[1:])
+	case "create":
+		return devicesCreate(e, args[1:])
+	case "get":
+		return devicesGet(e, args[1:])
+	case "update":
+		return devicesUpdate(e, args[1:])
+	case "decommission":
+		return devicesDecommission(e, args[1:])
+	}
+	return fmt.Errorf("devices: unknown subcommand %q: %w", args[0], errUsage)
+}
+
+func devicesList(e *env, args []string) error {
+	flags := e.newFlagSet("devices list")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	devices := []domain.DeviceInfo{}
+	if err := e.client.doJSON("GET", "/api/v1/devices", nil, &devices); err != nil {
+		return err
+	}
+	return e.out.print(devices, func(tw *tabwriter.Writer) {
+		row(tw, "ID", "LABEL", "ALGORITHM", "STATUS", "SIGNATURES")
+		for _, device := range devices {
+			row(tw, device.ID, device.Label, device.Algorithm, device.Status, device.SignatureCounter)
+		}
+	})
+}
+
+func devicesCreate(e *env, args []string) error {
+	flags := e.newFlagSet("devices create")
+	id := flags.String("id", "", "device ID (default: random UUID)")
+	label := flags.String("label", "", "device label")
+	algorithm := flags.String("algorithm", "", "signature algorithm: RSA or ECDSA")
+	timestamps := flags.Bool("timestamps", false, "add RFC 3161 timestamps to all signatures")
+	deterministic := flags.Bool("deterministic", false, "derive ECDSA nonces from key and data (RFC 6979)")
+	format := flags.String("format", "", "secured data format: v0 or v1 (default)")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *algorithm == "" {
+		return fmt.Errorf("devices create: -algorithm missing: %w", errUsage)
+	}
+	if *id == "" {
+		*id = uuid.NewString()
+	}
+
+	device := domain.DeviceInfo{}
+	payload := api.SignatureDeviceRequest{
+		ID:                *id,
+		Label:             *label,
+		Algorithm:         *algorithm,
+		Timestamps:        *timestamps,
+		Deterministic:     *deterministic,
+		SecuredDataFormat: *format,
+	}
+	if err := e.client.doJSON("POST", "/api/v1/devices", payload, &device); err != nil {
+		return err
+	}
+	return e.printDevice(device)
+}
+
+func devicesGet(e *env, args []string) error {
+	flags := e.newFlagSet("devices get")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	device := domain.DeviceInfo{}
+	if err := e.client.doJSON("GET", devicePath(flags.Arg(0)), nil, &device); err != nil {
+		return err
+	}
+	return e.printDevice(device)
+}
+
+func devicesUpdate(e *env, args []string) error {
+	flags := e.newFlagSet("devices update")
+	label := flags.String("label", "", "new device label")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	payload := api.UpdateSignatureDeviceRequest{}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "label" {
+			payload.Label = label
+		}
+	})
+	device := domain.DeviceInfo{}
+	if err := e.client.doJSON("PATCH", devicePath(flags.Arg(0)), payload, &device); err != nil {
+		return err
+	}
+	return e.printDevice(device)
+}
+
+func devicesDecommission(e *env, args []string) error {
+	flags := e.newFlagSet("devices decommission")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	device := domain.DeviceInfo{}
+	if err := e.client.doJSON("DELETE", devicePath(flags.Arg(0)), nil, &device); err != nil {
+		return err
+	}
+	return e.printDevice(device)
+}
+
+// printDevice prints the state of a single device.
+func (e *env) printDevice(device domain.DeviceInfo) error {
+	return e.out.print(device, func(tw *tabwriter.Writer) {
+		row(tw, "ID:", device.ID)
+		row(tw, "Label:", device.Label)
+		row(tw, "Algorithm:", device.Algorithm)
+		row(tw, "Status:", device.Status)
+		row(tw, "Signatures:", device.SignatureCounter)
+		row(tw, "Transactions:", device.TransactionCounter)
+		row(tw, "Clients:", strings.Join(device.Clients, ", "))
+		row(tw, "Timestamps:", device.Timestamps)
+		row(tw, "Deterministic:", device.Deterministic)
+		row(tw, "Format:", device.SecuredDataFormat)
+	})
+}
+
+// runClients dispatches the client subcommands.
+func runClients(e *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("clients: missing subcommand: %w", errUsage)
+	}
+	method := ""
+	switch args[0] {
+	case "list":
+		method = "GET"
+	case "register":
+		method = "PUT"
+	case "deregister":
+		method = "DELETE"
+	default:
+		return fmt.Errorf("clients: unknown subcommand %q: %w", args[0], errUsage)
+	}
+
+	flags := e.newFlagSet("clients " + args[0])
+	positional := 2
+	if method == "GET" {
+		positional = 1
+	}
+	if err := parseArgs(flags, args[1:], positional, positional); err != nil {
+		return err
+	}
+	path := devicePath(flags.Arg(0)) + "/clients"
+	if method != "GET" {
+		path += "/" + url.PathEscape(flags.Arg(1))
+	}
+
+	clients := []string{}
+	if err := e.client.doJSON(method, path, nil, &clients); err != nil {
+		return err
+	}
+	return e.out.print(clients, func(tw *tabwriter.Writer) {
+		row(tw, "CLIENT")
+		for _, clientID := range clients {
+			row(tw, clientID)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// keyPath returns the API path of an API key.
+func keyPath(keyID string) string {
+	return "/api/v1/keys/" + url.PathEscape(keyID)
+}
+
+// runKeys dispatches the API key subcommands.
+func runKeys(e *env, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("keys: missing subcommand: %w", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		return keysList(e, args[1:])
+	case "issue":
+		return keysIssue(e, args[1:])
+	case "revoke":
+		return keysRevoke(e, args[1:])
+	}
+	return fmt.Errorf("keys: unknown subcommand %q: %w", args[0], errUsage)
+}
+
+func keysList(e *env, args []string) error {
+	flags := e.newFlagSet("keys list")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	keys := []domain.APIKey{}
+	if err := e.client.doJSON("GET", "/api/v1/keys", nil, &keys); err != nil {
+		return err
+	}
+	return e.out.print(keys, func(tw *tabwriter.Writer) {
+		row(tw, "ID", "NAME", "SCOPES", "DEVICES", "REVOKED")
+		for _, key := range keys {
+			row(tw, key.ID, key.Name, joinScopes(key.Scopes), len(key.DeviceIDs), key.Revoked())
+		}
+	})
+}
+
+func keysIssue(e *env, args []string) error {
+	flags := e.newFlagSet("keys issue")
+	name := flags.String("name", "", "name of the key, e.g. the client using it")
+	scopes := flags.String("scopes", "", "comma-separated scopes: devices:create, devices:read, devices:write, sign, admin")
+	devices := flags.String("devices", "", "comma-separated device IDs the key is restricted to (default: all)")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *name == "" || *scopes == "" {
+		return fmt.Errorf("keys issue: -name or -scopes missing: %w", errUsage)
+	}
+
+	payload := api.APIKeyRequest{Name: *name}
+	for _, scope := range strings.Split(*scopes, ",") {
+		payload.Scopes = append(payload.Scopes, domain.Scope(strings.TrimSpace(scope)))
+	}
+	if *devices != "" {
+		for _, id := range strings.Split(*devices, ",") {
+			payload.DeviceIDs = append(payload.DeviceIDs, strings.TrimSpace(id))
+		}
+	}
+	issued := api.APIKeyResponse{}
+	if err := e.client.doJSON("POST", "/api/v1/keys", payload, &issued); err != nil {
+		return err
+	}
+	return e.out.print(issued, func(tw *tabwriter.Writer) {
+		printKeyRows(tw, issued.APIKey)
+		row(tw, "Key:", issued.Key)
+	})
+}
+
+func keysRevoke(e *env, args []string) error {
+	flags := e.newFlagSet("keys revoke")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	key := domain.APIKey{}
+	if err := e.client.doJSON("DELETE", keyPath(flags.Arg(0)), nil, &key); err != nil {
+		return err
+	}
+	return e.out.print(key, func(tw *tabwriter.Writer) {
+		printKeyRows(tw, key)
+	})
+}
+
+// printKeyRows prints the state of a single API key.
+func printKeyRows(tw *tabwriter.Writer, key domain.APIKey) {
+	devices := make([]string, len(key.DeviceIDs))
+	for i, id := range key.DeviceIDs {
+		devices[i] = id.String()
+	}
+	row(tw, "ID:", key.ID)
+	row(tw, "Name:", key.Name)
+	row(tw, "Scopes:", joinScopes(key.Scopes))
+	row(tw, "Devices:", strings.Join(devices, ", "))
+	row(tw, "Revoked:", key.Revoked())
+}
+
+// joinScopes formats scopes as comma-separated list.
+func joinScopes(scopes []domain.Scope) string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ",")
+}
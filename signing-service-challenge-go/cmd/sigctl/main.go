@@ -0,0 +1,174 @@
+// Command sigctl is the command-line client of the signing service.
+//
+// Usage:
+//
+//	sigctl [-config file] [-server url] [-token token] [-output table|json] [-ca-file file]
+//	       [-cert-file file -key-file file] <command> [flags] [args]
+//
+// Commands:
+//
+//	devices list
+//	devices create [-id uuid] [-label label] [-timestamps] [-deterministic] [-format v0|v1] -algorithm RSA|ECDSA
+//	devices get <device_id>
+//	devices update -label label <device_id>
+//	devices decommission <device_id>
+//	clients list <device_id>
+//	clients register <device_id> <client_id>
+//	clients deregister <device_id> <client_id>
+//	sign [-client client_id] [-stream] <device_id> [file ...]
+//	verify [-public-key file] <device_id> [file ...]
+//	public-key <device_id>
+//	export [-o file] [-from time] [-to time] [-counter-from n] [-counter-to n] <device_id>
+//	keys list
+//	keys issue -name name -scopes scope,... [-devices device_id,...]
+//	keys revoke <key_id>
+//
+// Data to be signed and signatures to be verified are read from stdin if no files are given. Server address,
+// token, default client ID, output format and TLS files are read from the YAML config file ($SIGCTL_CONFIG or
+// sigctl/config.yaml in the user config directory) and can be overridden by SIGCTL_SERVER, SIGCTL_TOKEN,
+// SIGCTL_OUTPUT and the global flags. The client certificate of -cert-file and -key-file authenticates sigctl to
+// servers requesting client certificates. Servers requiring API keys take them as token; the keys commands need
+// a key with the admin scope.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of sigctl.
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitInvalid = 2
+)
+
+// errUsage marks invalid command lines.
+var errUsage = errors.New("invalid usage")
+
+// errVerification is returned if signatures could not be verified.
+var errVerification = errors.New("verification failed")
+
+// env bundles everything a command needs.
+type env struct {
+	cfg    config
+	client *client
+	out    printer
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command executes a subcommand with its remaining arguments.
+type command func(e *env, args []string) error
+
+var commands = map[string]command{
+	"devices":    runDevices,
+	"clients":    runClients,
+	"sign":       runSign,
+	"verify":     runVerify,
+	"public-key": runPublicKey,
+	"export":     runExport,
+	"keys":       runKeys,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes sigctl with the given arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sigctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", "", "config file (default $SIGCTL_CONFIG or sigctl/config.yaml in the user config directory)")
+	server := flags.String("server", "", "server address, e.g. http://localhost:8080")
+	token := flags.String("token", "", "bearer token sent with every request")
+	output := flags.String("output", "", "output format: table or json")
+	caFile := flags.String("ca-file", "", "PEM bundle of the CAs verifying the server certificate (default system roots)")
+	certFile := flags.String("cert-file", "", "PEM client certificate presented to the server")
+	keyFile := flags.String("key-file", "", "PEM private key of the client certificate")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	cfg, err := loadConfig(path, explicit)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigctl: %s\n", err)
+		return exitInvalid
+	}
+	for value, flagValue := range map[*string]string{
+		&cfg.Server: *server, &cfg.Token: *token, &cfg.Output: *output,
+		&cfg.CAFile: *caFile, &cfg.CertFile: *certFile, &cfg.KeyFile: *keyFile,
+	} {
+		if flagValue != "" {
+			*value = flagValue
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(stderr, "sigctl: %s\n", err)
+		return exitInvalid
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitInvalid
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "sigctl: unknown command %q\n", flags.Arg(0))
+		return exitInvalid
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigctl: %s\n", err)
+		return exitInvalid
+	}
+	e := &env{
+		cfg:    cfg,
+		client: client,
+		out:    printer{w: stdout, format: cfg.Output},
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	err = cmd(e, flags.Args()[1:])
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "sigctl: %s\n", err)
+		}
+		return exitInvalid
+	default:
+		fmt.Fprintf(stderr, "sigctl: %s\n", err)
+		return exitFailed
+	}
+}
+
+// newFlagSet creates the flag set of a subcommand writing its usage to stderr.
+func (e *env) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("sigctl "+name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	return flags
+}
+
+// parseArgs parses the flags of a subcommand and checks the number of positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, min int, max int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < min || (max >= 0 && flags.NArg() > max) {
+		flags.Usage()
+		return fmt.Errorf("%s: %w", flags.Name(), errUsage)
+	}
+	return nil
+}
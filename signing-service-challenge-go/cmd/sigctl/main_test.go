@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig/tlsconfigtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sigctl runs the command against the server and returns exit code, stdout and stderr.
+func sigctl(t *testing.T, server string, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-server", server}, args...)
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSigctl(t *testing.T) {
+	t.Setenv(envConfig, filepath.Join(t.TempDir(), "missing.yaml"))
+	ts := httptest.NewServer(api.NewServer("").Handler())
+	defer ts.Close()
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+
+	t.Run("create", func(t *testing.T) {
+		code, stdout, stderr := sigctl(t, ts.URL, "", "-output", "json", "devices", "create", "-id", deviceID, "-label", "myDev", "-algorithm", "ECDSA")
+		require.Equal(t, exitOK, code, stderr)
+		device := domain.DeviceInfo{}
+		require.Nil(t, json.Unmarshal([]byte(stdout), &device))
+		assert.Equal(t, deviceID, device.ID.String())
+		assert.Equal(t, domain.DeviceActive, device.Status)
+
+		code, _, stderr = sigctl(t, ts.URL, "", "devices", "create", "-id", deviceID, "-algorithm", "ECDSA")
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stderr, "409")
+	})
+	t.Run("list", func(t *testing.T) {
+		code, stdout, _ := sigctl(t, ts.URL, "", "devices", "list")
+		require.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, "ID")
+		assert.Contains(t, stdout, deviceID)
+		assert.Contains(t, stdout, "myDev")
+	})
+	t.Run("register client", func(t *testing.T) {
+		code, stdout, stderr := sigctl(t, ts.URL, "", "clients", "register", deviceID, "register-1")
+		require.Equal(t, exitOK, code, stderr)
+		assert.Contains(t, stdout, "register-1")
+	})
+
+	signatures := filepath.Join(t.TempDir(), "signatures.json")
+	t.Run("sign and verify", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "receipt.txt")
+		require.Nil(t, os.WriteFile(file, []byte("receipt"), 0o600))
+
+		code, stdout, stderr := sigctl(t, ts.URL, "data", "-output", "json", "sign", "-client", "register-1", deviceID, "-", file)
+		require.Equal(t, exitOK, code, stderr)
+		results := []signResult{}
+		require.Nil(t, json.Unmarshal([]byte(stdout), &results))
+		require.Len(t, results, 2)
+		assert.Equal(t, "-", results[0].Source)
+		assert.True(t, strings.HasPrefix(results[0].SignedData, "v1:1:0,10:register-1,4:DATA,4:data,"))
+		assert.True(t, strings.HasPrefix(results[1].SignedData, "v1:1:1,10:register-1,4:DATA,7:receipt,"))
+
+		code, stdout, stderr = sigctl(t, ts.URL, "", "-output", "json", "sign", "-client", "register-1", "-stream", deviceID, file)
+		require.Equal(t, exitOK, code, stderr)
+		streamed := []signResult{}
+		require.Nil(t, json.Unmarshal([]byte(stdout), &streamed))
+		require.Len(t, streamed, 1)
+		assert.NotEmpty(t, streamed[0].Digest)
+		assert.True(t, strings.HasPrefix(streamed[0].SignedData, "v1:1:2,10:register-1,6:DIGEST,52:SHA-256:"))
+
+		code, _, _ = sigctl(t, ts.URL, "", "sign", "-stream", deviceID, file)
+		assert.Equal(t, exitInvalid, code, "streams are signed on behalf of a client")
+
+		raw, err := json.Marshal(append(results, streamed...))
+		require.Nil(t, err)
+		require.Nil(t, os.WriteFile(signatures, raw, 0o600))
+
+		code, stdout, stderr = sigctl(t, ts.URL, "", "verify", deviceID, signatures)
+		require.Equal(t, exitOK, code, stderr)
+		assert.Contains(t, stdout, "OK")
+	})
+	t.Run("verify tampered", func(t *testing.T) {
+		content, err := os.ReadFile(signatures)
+		require.Nil(t, err)
+		tampered := strings.Replace(string(content), "4:data,", "4:atad,", 1)
+
+		code, stdout, _ := sigctl(t, ts.URL, tampered, "verify", deviceID)
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stdout, "invalid signature")
+	})
+	t.Run("secured data v0", func(t *testing.T) {
+		v0Device := "1727d3e0-e1ae-410c-97d2-70da0ae0abc4"
+		code, stdout, stderr := sigctl(t, ts.URL, "", "devices", "create", "-id", v0Device, "-algorithm", "RSA", "-format", "v0")
+		require.Equal(t, exitOK, code, stderr)
+		assert.Contains(t, stdout, "v0")
+		code, _, stderr = sigctl(t, ts.URL, "", "clients", "register", v0Device, "register-1")
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stderr, "409", "format v0 does not record clients")
+
+		code, stdout, stderr = sigctl(t, ts.URL, "a_b", "-output", "json", "sign", v0Device, "-")
+		require.Equal(t, exitOK, code, stderr)
+		results := []signResult{}
+		require.Nil(t, json.Unmarshal([]byte(stdout), &results))
+		require.Len(t, results, 1)
+		assert.True(t, strings.HasPrefix(results[0].SignedData, "0_a_b_"))
+
+		code, stdout, stderr = sigctl(t, ts.URL, stdout, "verify", v0Device)
+		require.Equal(t, exitOK, code, stderr)
+		assert.Contains(t, stdout, "OK")
+
+		code, _, stderr = sigctl(t, ts.URL, "", "devices", "create", "-algorithm", "RSA", "-format", "v9")
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stderr, "400")
+	})
+	t.Run("public key", func(t *testing.T) {
+		code, stdout, _ := sigctl(t, ts.URL, "", "public-key", deviceID)
+		require.Equal(t, exitOK, code)
+		assert.True(t, strings.HasPrefix(stdout, "-----BEGIN PUBLIC_KEY-----"))
+	})
+	t.Run("export", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "export.tar")
+		code, _, stderr := sigctl(t, ts.URL, "", "export", "-o", file, "-counter-from", "1", deviceID)
+		require.Equal(t, exitOK, code, stderr)
+
+		f, err := os.Open(file)
+		require.Nil(t, err)
+		defer f.Close()
+		archive, err := export.Read(f)
+		require.Nil(t, err)
+		require.Len(t, archive.Signatures, 2)
+		assert.Equal(t, 1, archive.Signatures[0].Counter)
+	})
+	t.Run("update and decommission", func(t *testing.T) {
+		code, stdout, _ := sigctl(t, ts.URL, "", "devices", "update", "-label", "renamed", deviceID)
+		require.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, "renamed")
+
+		code, stdout, _ = sigctl(t, ts.URL, "", "devices", "decommission", deviceID)
+		require.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, string(domain.DeviceDecommissioned))
+
+		code, _, stderr := sigctl(t, ts.URL, "data", "sign", "-client", "register-1", deviceID)
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stderr, "device decommissioned")
+	})
+	t.Run("api keys", func(t *testing.T) {
+		const adminKey = "admin-key-for-tests"
+		server := api.NewServer("")
+		server.RequireAPIKey = true
+		server.AdminKey = adminKey
+		ts := httptest.NewServer(server.Handler())
+		defer ts.Close()
+
+		code, stdout, stderr := sigctl(t, ts.URL, "", "-token", adminKey, "-output", "json", "keys", "issue", "-name", "pos", "-scopes", "devices:create, sign")
+		require.Equal(t, exitOK, code, stderr)
+		issued := api.APIKeyResponse{}
+		require.Nil(t, json.Unmarshal([]byte(stdout), &issued))
+		assert.Equal(t, []domain.Scope{domain.ScopeDevicesCreate, domain.ScopeSign}, issued.Scopes)
+
+		code, _, stderr = sigctl(t, ts.URL, "", "-token", issued.Key, "devices", "create", "-algorithm", "ECDSA")
+		require.Equal(t, exitOK, code, stderr)
+		code, _, stderr = sigctl(t, ts.URL, "", "-token", issued.Key, "devices", "list")
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stderr, "403")
+
+		code, stdout, _ = sigctl(t, ts.URL, "", "-token", adminKey, "keys", "list")
+		require.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, issued.ID)
+		assert.NotContains(t, stdout, issued.Key)
+
+		code, stdout, stderr = sigctl(t, ts.URL, "", "-token", adminKey, "keys", "revoke", issued.ID)
+		require.Equal(t, exitOK, code, stderr)
+		assert.Contains(t, stdout, "true")
+		code, _, stderr = sigctl(t, ts.URL, "", "-token", issued.Key, "devices", "create", "-algorithm", "ECDSA")
+		assert.Equal(t, exitFailed, code)
+		assert.Contains(t, stderr, "401")
+
+		code, _, _ = sigctl(t, ts.URL, "", "-token", adminKey, "keys", "issue", "-name", "pos")
+		assert.Equal(t, exitInvalid, code)
+	})
+	t.Run("usage", func(t *testing.T) {
+		code, _, _ := sigctl(t, ts.URL, "")
+		assert.Equal(t, exitInvalid, code)
+		code, _, _ = sigctl(t, ts.URL, "", "unknown")
+		assert.Equal(t, exitInvalid, code)
+		code, _, _ = sigctl(t, ts.URL, "", "devices", "get")
+		assert.Equal(t, exitInvalid, code)
+		code, _, _ = sigctl(t, ts.URL, "", "-output", "xml", "devices", "list")
+		assert.Equal(t, exitInvalid, code)
+	})
+}
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("server: http://signing:8080\ntoken: secret\nclient_id: register-1\n"), 0o600))
+
+	t.Run("file", func(t *testing.T) {
+		cfg, err := loadConfig(path, true)
+		require.Nil(t, err)
+		assert.Equal(t, config{Server: "http://signing:8080", Token: "secret", ClientID: "register-1", Output: outputTable}, cfg)
+	})
+	t.Run("environment overrides file", func(t *testing.T) {
+		t.Setenv(envServer, "http://other:8080")
+		cfg, err := loadConfig(path, true)
+		require.Nil(t, err)
+		assert.Equal(t, "http://other:8080", cfg.Server)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing.yaml")
+		cfg, err := loadConfig(missing, false)
+		require.Nil(t, err)
+		assert.Equal(t, defaultConfig(), cfg)
+		_, err = loadConfig(missing, true)
+		assert.NotNil(t, err)
+	})
+	t.Run("token is sent", func(t *testing.T) {
+		var authorization string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			api.WriteAPIResponse(w, http.StatusOK, []domain.DeviceInfo{})
+		}))
+		defer ts.Close()
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-config", path, "-server", ts.URL, "devices", "list"}, nil, &stdout, &stderr)
+		require.Equal(t, exitOK, code, stderr.String())
+		assert.Equal(t, "Bearer secret", authorization)
+	})
+	t.Run("client certificate", func(t *testing.T) {
+		ca := tlsconfigtest.NewCA(t)
+		var identity string
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity = r.TLS.VerifiedChains[0][0].Subject.CommonName
+			api.WriteAPIResponse(w, http.StatusOK, []domain.DeviceInfo{})
+		}))
+		ts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{ca.Issue(t, "localhost").TLS()},
+			ClientCAs:    ca.Pool(),
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		}
+		ts.StartTLS()
+		defer ts.Close()
+
+		dir := t.TempDir()
+		caFile := filepath.Join(dir, "ca.pem")
+		require.Nil(t, os.WriteFile(caFile, ca.PEM(), 0o600))
+		certFile, keyFile := ca.Issue(t, "operator").WriteFiles(t, dir)
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-server", ts.URL, "-ca-file", caFile, "-cert-file", certFile, "-key-file", keyFile, "devices", "list"}, nil, &stdout, &stderr)
+		require.Equal(t, exitOK, code, stderr.String())
+		assert.Equal(t, "operator", identity)
+
+		code = run([]string{"-server", ts.URL, "-ca-file", caFile, "devices", "list"}, nil, &stdout, &stderr)
+		assert.Equal(t, exitFailed, code, "the server requires a client certificate")
+		code = run([]string{"-server", ts.URL, "-cert-file", certFile, "devices", "list"}, nil, &stdout, &stderr)
+		assert.Equal(t, exitInvalid, code, "key file missing")
+	})
+}
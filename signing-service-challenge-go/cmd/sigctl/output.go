@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes command results either as JSON or as table.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+// print writes v as indented JSON or calls table to render it.
+func (p printer) print(v interface{}, table func(*tabwriter.Writer)) error {
+	if p.format == outputJSON {
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// row writes the tab separated columns of a table row.
+func row(tw *tabwriter.Writer, columns ...interface{}) {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = fmt.Sprint(column)
+	}
+	fmt.Fprintln(tw, strings.Join(values, "\t"))
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+)
+
+// stdinName is the source name of data read from stdin.
+const stdinName = "-"
+
+// signResult is the output of the sign command for a single input.
+type signResult struct {
+	Source string `json:"source"`
+	api.SignatureResponse
+	Digest string `json:"digest,omitempty"`
+}
+
+// verifyResult is the output of the verify command.
+type verifyResult struct {
+	DeviceID   string                  `json:"device_id"`
+	Valid      bool                    `json:"valid"`
+	Signatures int                     `json:"signatures"`
+	Violations []domain.ChainViolation `json:"violations"`
+}
+
+// forEachInput opens every file (or stdin if there are none) and passes its content to fn. A file named "-" is
+// stdin as well.
+func (e *env) forEachInput(files []string, fn func(name string, r io.Reader) error) error {
+	if len(files) == 0 {
+		return fn(stdinName, e.stdin)
+	}
+	for _, name := range files {
+		if name == stdinName {
+			if err := fn(stdinName, e.stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = fn(name, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runSign signs the content of every file or stdin. By default the content is signed as data; with -stream the
+// content is streamed to the service which signs its digest. Like the service, only data of devices without
+// registered clients is signed without -client.
+func runSign(e *env, args []string) error {
+	flags := e.newFlagSet("sign")
+	clientID := flags.String("client", e.cfg.ClientID, "client ID registered to the device, required with -stream")
+	stream := flags.Bool("stream", false, "stream the content and sign its digest")
+	if err := parseArgs(flags, args, 1, -1); err != nil {
+		return err
+	}
+	if *stream && *clientID == "" {
+		return fmt.Errorf("sign: -client missing: %w", errUsage)
+	}
+	deviceID := flags.Arg(0)
+
+	results := []signResult{}
+	err := e.forEachInput(flags.Args()[1:], func(name string, r io.Reader) error {
+		result := signResult{Source: name}
+		if *stream {
+			path := devicePath(deviceID) + "/signatures:stream?client_id=" + url.QueryEscape(*clientID)
+			resp, err := e.client.do("POST", path, "application/octet-stream", r)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			streamResp := api.StreamSignatureResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(&api.Response{Data: &streamResp}); err != nil {
+				return fmt.Errorf("sign %s decode | %w", name, err)
+			}
+			result.SignatureResponse = streamResp.SignatureResponse
+			result.Digest = streamResp.Digest
+		} else {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			payload := api.SignatureRequest{ID: deviceID, ClientID: *clientID, Data: string(data)}
+			if err := e.client.doJSON("POST", "/api/v0/devices/sign", payload, &result.SignatureResponse); err != nil {
+				return err
+			}
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.out.print(results, func(tw *tabwriter.Writer) {
+		row(tw, "SOURCE", "COUNTER", "CLIENT", "SIGNATURE")
+		for _, result := range results {
+			row(tw, result.Source, result.Counter, result.ClientID, result.Signature)
+		}
+	})
+}
+
+// runVerify verifies signatures as printed by "sigctl -output json sign" or returned by the API, either a single
+// signature or a list. The signatures are checked against the public key and the signature chain.
+func runVerify(e *env, args []string) error {
+	flags := e.newFlagSet("verify")
+	publicKeyFile := flags.String("public-key", "", "PEM encoded public key (default: fetched from the service)")
+	if err := parseArgs(flags, args, 1, -1); err != nil {
+		return err
+	}
+	deviceID, err := uuid.Parse(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("verify: invalid device id: %w", errUsage)
+	}
+
+	var publicKey []byte
+	if *publicKeyFile != "" {
+		publicKey, err = os.ReadFile(*publicKeyFile)
+	} else {
+		publicKey, err = e.fetchPublicKey(deviceID.String())
+	}
+	if err != nil {
+		return err
+	}
+	verifier, _, err := crypto.NewVerifier(publicKey)
+	if err != nil {
+		return err
+	}
+
+	signatures := []domain.Signature{}
+	err = e.forEachInput(flags.Args()[1:], func(name string, r io.Reader) error {
+		responses, err := decodeSignatures(r)
+		if err != nil {
+			return fmt.Errorf("verify %s | %w", name, err)
+		}
+		for _, resp := range responses {
+			signatures = append(signatures, domain.Signature{
+				DeviceID:   deviceID,
+				ClientID:   resp.ClientID,
+				Counter:    resp.Counter,
+				SignedData: resp.SignedData,
+				Format:     resp.Format,
+				Mode:       resp.Mode,
+				Signature:  resp.Signature,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(signatures, func(i, j int) bool { return signatures[i].Counter < signatures[j].Counter })
+
+	violations := domain.VerifyChain(deviceID, verifier, signatures)
+	result := verifyResult{
+		DeviceID:   deviceID.String(),
+		Valid:      len(violations) == 0,
+		Signatures: len(signatures),
+		Violations: violations,
+	}
+	err = e.out.print(result, func(tw *tabwriter.Writer) {
+		reasons := map[int][]string{}
+		for _, violation := range violations {
+			reasons[violation.Counter] = append(reasons[violation.Counter], violation.Reason)
+		}
+		row(tw, "COUNTER", "CLIENT", "RESULT")
+		for _, signature := range signatures {
+			status := "OK"
+			if len(reasons[signature.Counter]) > 0 {
+				status = strings.Join(reasons[signature.Counter], "; ")
+			}
+			row(tw, signature.Counter, signature.ClientID, status)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Valid {
+		return errVerification
+	}
+	return nil
+}
+
+// decodeSignatures reads a single signature or a list of signatures.
+func decodeSignatures(r io.Reader) ([]api.SignatureResponse, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("[")) {
+		signatures := []api.SignatureResponse{}
+		err := json.Unmarshal(content, &signatures)
+		return signatures, err
+	}
+	signature := api.SignatureResponse{}
+	if err := json.Unmarshal(content, &signature); err != nil {
+		return nil, err
+	}
+	return []api.SignatureResponse{signature}, nil
+}
+
+// runPublicKey prints the public key of a device. Table output is the plain PEM block.
+func runPublicKey(e *env, args []string) error {
+	flags := e.newFlagSet("public-key")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	publicKey := api.PublicKeyResponse{}
+	if err := e.client.doJSON("GET", devicePath(flags.Arg(0))+"/public-key", nil, &publicKey); err != nil {
+		return err
+	}
+	if e.cfg.Output == outputJSON {
+		return e.out.print(publicKey, nil)
+	}
+	_, err := io.WriteString(e.stdout, publicKey.PublicKey)
+	return err
+}
+
+// fetchPublicKey reads the PEM encoded public key of a device from the service.
+func (e *env) fetchPublicKey(deviceID string) ([]byte, error) {
+	publicKey := api.PublicKeyResponse{}
+	if err := e.client.doJSON("GET", devicePath(deviceID)+"/public-key", nil, &publicKey); err != nil {
+		return nil, err
+	}
+	return []byte(publicKey.PublicKey), nil
+}
+
+// runExport downloads the TAR audit export of a device to a file or stdout.
+func runExport(e *env, args []string) error {
+	flags := e.newFlagSet("export")
+	output := flags.String("o", "", "output file (default: stdout)")
+	query := url.Values{}
+	bounds := map[string]*string{}
+	for _, name := range []string{"from", "to", "counter_from", "counter_to"} {
+		flagName := strings.ReplaceAll(name, "_", "-")
+		bounds[name] = flags.String(flagName, "", "inclusive export bound "+name)
+	}
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+	for name, value := range bounds {
+		if *value != "" {
+			query.Set(name, *value)
+		}
+	}
+
+	path := devicePath(flags.Arg(0)) + "/export"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := e.client.do("GET", path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	w := e.stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,129 @@
+// Command sigverify verifies exported signatures offline. It checks every signature against the device's public
+// key and the signature chain built from counters and last signatures.
+//
+// Usage:
+//
+//	sigverify -archive export.tar
+//	sigverify -device <device_id> -signatures signatures.json -public-key public_key.pem
+//
+// The exit code is 0 if all signatures are valid, 1 if the chain is broken and 2 on invalid input.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+	"github.com/google/uuid"
+)
+
+// Exit codes of sigverify.
+const (
+	exitOK      = 0
+	exitBroken  = 1
+	exitInvalid = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes sigverify with the given arguments and returns its exit code.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sigverify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	archivePath := flags.String("archive", "", "TAR export archive of a device")
+	signaturesPath := flags.String("signatures", "", "JSON list of signatures, requires -public-key and -device")
+	publicKeyPath := flags.String("public-key", "", "PEM encoded public key of the device")
+	deviceID := flags.String("device", "", "ID of the device that created the signatures")
+	if err := flags.Parse(args); err != nil {
+		return exitInvalid
+	}
+
+	var (
+		id         uuid.UUID
+		publicKey  []byte
+		signatures []domain.Signature
+		err        error
+	)
+	switch {
+	case *archivePath != "" && *signaturesPath == "":
+		id, publicKey, signatures, err = readArchive(*archivePath)
+	case *archivePath == "" && *signaturesPath != "" && *publicKeyPath != "" && *deviceID != "":
+		id, publicKey, signatures, err = readSignatures(*deviceID, *signaturesPath, *publicKeyPath)
+	default:
+		flags.Usage()
+		return exitInvalid
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "sigverify: %s\n", err)
+		return exitInvalid
+	}
+
+	verifier, algorithm, err := crypto.NewVerifier(publicKey)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigverify: %s\n", err)
+		return exitInvalid
+	}
+
+	sort.SliceStable(signatures, func(i, j int) bool { return signatures[i].Counter < signatures[j].Counter })
+	violations := domain.VerifyChain(id, verifier, signatures)
+
+	fmt.Fprintf(stdout, "device:     %s\n", id)
+	fmt.Fprintf(stdout, "algorithm:  %s\n", algorithm)
+	fmt.Fprintf(stdout, "signatures: %d\n", len(signatures))
+	if len(signatures) > 0 {
+		fmt.Fprintf(stdout, "counters:   %d-%d\n", signatures[0].Counter, signatures[len(signatures)-1].Counter)
+	}
+	for _, violation := range violations {
+		fmt.Fprintf(stdout, "FAIL %s\n", violation)
+	}
+	if len(violations) > 0 {
+		fmt.Fprintf(stdout, "result:     BROKEN (%d violations)\n", len(violations))
+		return exitBroken
+	}
+	fmt.Fprintln(stdout, "result:     OK")
+	return exitOK
+}
+
+// readArchive reads device ID, public key and signatures from an export archive.
+func readArchive(path string) (uuid.UUID, []byte, []domain.Signature, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return uuid.Nil, nil, nil, fmt.Errorf("readArchive | %w", err)
+	}
+	defer f.Close()
+
+	archive, err := export.Read(f)
+	if err != nil {
+		return uuid.Nil, nil, nil, fmt.Errorf("readArchive | %w", err)
+	}
+	return archive.Manifest.Device.ID, archive.PublicKey, archive.Signatures, nil
+}
+
+// readSignatures reads a JSON list of signatures and a PEM public key.
+func readSignatures(deviceID string, signaturesPath string, publicKeyPath string) (uuid.UUID, []byte, []domain.Signature, error) {
+	id, err := uuid.Parse(deviceID)
+	if err != nil {
+		return uuid.Nil, nil, nil, fmt.Errorf("readSignatures | %w", err)
+	}
+	content, err := os.ReadFile(signaturesPath)
+	if err != nil {
+		return uuid.Nil, nil, nil, fmt.Errorf("readSignatures | %w", err)
+	}
+	signatures := []domain.Signature{}
+	if err := json.Unmarshal(content, &signatures); err != nil {
+		return uuid.Nil, nil, nil, fmt.Errorf("readSignatures %s | %w", signaturesPath, err)
+	}
+	publicKey, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return uuid.Nil, nil, nil, fmt.Errorf("readSignatures | %w", err)
+	}
+	return id, publicKey, signatures, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/export"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFixtures signs a chain of n signatures and writes it as archive, signature list and public key into dir.
+func writeFixtures(t *testing.T, dir string, n int, tamper func([]domain.Signature)) (*domain.SignatureDevice, string) {
+	sd, err := domain.NewSignatureDevice(uuid.New(), "myDev", crypto.SignatureRSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient("register-1"))
+	data := make([]string, n)
+	for i := range data {
+		data[i] = "data"
+	}
+	signatures, err := sd.SignBatch(context.Background(), "register-1", data, nil)
+	require.Nil(t, err)
+	if tamper != nil {
+		tamper(signatures)
+	}
+	publicKey, err := sd.PublicKey()
+	require.Nil(t, err)
+
+	var archive bytes.Buffer
+	device := export.Device{ID: sd.ID, Label: sd.Label, Algorithm: sd.Algorithm}
+	require.Nil(t, export.Write(&archive, device, publicKey, signatures, export.Filter{}, time.Now()))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "export.tar"), archive.Bytes(), 0o600))
+
+	list, err := json.Marshal(signatures)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "signatures.json"), list, 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "public_key.pem"), publicKey, 0o600))
+	return sd, dir
+}
+
+func TestRun(t *testing.T) {
+	t.Run("archive", func(t *testing.T) {
+		_, dir := writeFixtures(t, t.TempDir(), 3, nil)
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"-archive", filepath.Join(dir, "export.tar")}, &stdout, &stderr)
+		assert.Equal(t, exitOK, code, stderr.String())
+		assert.Contains(t, stdout.String(), "signatures: 3")
+		assert.Contains(t, stdout.String(), "result:     OK")
+	})
+	t.Run("signature list", func(t *testing.T) {
+		sd, dir := writeFixtures(t, t.TempDir(), 2, nil)
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{
+			"-device", sd.ID.String(),
+			"-signatures", filepath.Join(dir, "signatures.json"),
+			"-public-key", filepath.Join(dir, "public_key.pem"),
+		}, &stdout, &stderr)
+		assert.Equal(t, exitOK, code, stderr.String())
+		assert.Contains(t, stdout.String(), string(crypto.SignatureRSA))
+	})
+	t.Run("broken chain", func(t *testing.T) {
+		_, dir := writeFixtures(t, t.TempDir(), 3, func(signatures []domain.Signature) {
+			signatures[1].SignedData = "1_register-1_forged_" + signatures[0].Signature
+		})
+		var stdout, stderr bytes.Buffer
+
+		code := run([]string{"-archive", filepath.Join(dir, "export.tar")}, &stdout, &stderr)
+		assert.Equal(t, exitBroken, code)
+		assert.Contains(t, stdout.String(), "FAIL counter 1: invalid signature")
+		assert.Contains(t, stdout.String(), "BROKEN")
+	})
+	t.Run("missing input", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitInvalid, run([]string{"-signatures", "signatures.json"}, &stdout, &stderr))
+		assert.Equal(t, exitInvalid, run([]string{"-archive", filepath.Join(t.TempDir(), "missing.tar")}, &stdout, &stderr))
+	})
+}
@@ -0,0 +1,470 @@
+// Package config loads the configuration of the signing service. Every setting has a default and can be
+// overridden, in increasing precedence, by a YAML file, an environment variable and a command line flag:
+//
+//	signing-service -config config.yaml -log-level debug
+//
+// The environment variable of a setting is SIGNING_ followed by its YAML path in upper case with dots replaced by
+// underscores, e.g. SIGNING_SERVER_LISTEN_ADDRESS for server.listen_address. List settings take comma separated
+// values in environment variables and flags. config.example.yaml documents all settings with their defaults.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of all environment variables.
+const EnvPrefix = "SIGNING_"
+
+// EnvConfigFile names the configuration file if the -config flag is not set.
+const EnvConfigFile = EnvPrefix + "CONFIG"
+
+// Redacted replaces the values of secrets in printed configurations.
+const Redacted = "REDACTED"
+
+// Client identities of TLSConfig.ClientIdentity.
+const (
+	ClientIdentityCommonName = "common_name"
+	ClientIdentitySubject    = "subject"
+)
+
+// StorageMemory keeps devices and ledgers in memory; they are lost on restart.
+const StorageMemory = "memory"
+
+// Config is the configuration of the signing service. Fields are described by their flag usage.
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	TLS     TLSConfig     `yaml:"tls"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Storage StorageConfig `yaml:"storage"`
+	Signing SigningConfig `yaml:"signing"`
+	Limits  LimitsConfig  `yaml:"limits"`
+	Log     LogConfig     `yaml:"log"`
+	Trace   TraceConfig   `yaml:"trace"`
+	Health  HealthConfig  `yaml:"health"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	ListenAddress     string   `yaml:"listen_address" flag:"listen-address" usage:"address the API listens on"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" flag:"read-header-timeout" usage:"maximum duration for reading request headers"`
+	ReadTimeout       Duration `yaml:"read_timeout" flag:"read-timeout" usage:"maximum duration for reading a request including its body, for streamed content the maximum pause"`
+	WriteTimeout      Duration `yaml:"write_timeout" flag:"write-timeout" usage:"maximum duration before timing out writes of a response"`
+	IdleTimeout       Duration `yaml:"idle_timeout" flag:"idle-timeout" usage:"maximum time to wait for the next request on a keep-alive connection"`
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout" flag:"shutdown-timeout" usage:"time running requests get to finish on SIGTERM or SIGINT"`
+}
+
+// TLSConfig configures HTTPS and the authentication of clients by certificates.
+type TLSConfig struct {
+	CertFile       string   `yaml:"cert_file" flag:"tls-cert-file" usage:"PEM certificate chain of the server; empty serves plain HTTP"`
+	KeyFile        string   `yaml:"key_file" flag:"tls-key-file" usage:"PEM private key of the server certificate"`
+	ReloadInterval Duration `yaml:"reload_interval" flag:"tls-reload-interval" usage:"minimum time between checks of the certificate files for renewals"`
+	ClientAuth     string   `yaml:"client_auth" flag:"tls-client-auth" usage:"client certificates: none, optional or require; require also applies to health checks and metrics"`
+	ClientCAFile   string   `yaml:"client_ca_file" flag:"tls-client-ca-file" usage:"PEM bundle of the CAs client certificates are verified against"`
+	ClientIdentity string   `yaml:"client_identity" flag:"tls-client-identity" usage:"identity of clients taken from their certificate subject: common_name or subject"`
+}
+
+// AuthConfig configures the API key authentication.
+type AuthConfig struct {
+	Enabled  bool   `yaml:"enabled" flag:"auth-enabled" usage:"require an API key with the scope of the route on every request except health checks, metrics and the TSA certificate"`
+	AdminKey string `yaml:"admin_key" flag:"auth-admin-key" usage:"API key with the admin scope to issue further keys, at least 32 characters; prefer the environment over the flag" secret:"true"`
+}
+
+// minAdminKeyLength makes the configured admin key as hard to guess as an issued key.
+const minAdminKeyLength = 32
+
+// StorageConfig selects the store of devices and ledgers.
+type StorageConfig struct {
+	Backend string `yaml:"backend" flag:"storage-backend" usage:"store of devices and ledgers: memory"`
+	DSN     string `yaml:"dsn" flag:"storage-dsn" usage:"connection string of the store, unused by memory" secret:"true"`
+}
+
+// SigningConfig configures new devices and their signatures.
+type SigningConfig struct {
+	Algorithms         []string `yaml:"algorithms" flag:"algorithms" usage:"signature algorithms new devices may use"`
+	RSAKeySize         int      `yaml:"rsa_key_size" flag:"rsa-key-size" usage:"RSA key size of new devices in bits"`
+	ECDSAKeySize       int      `yaml:"ecdsa_key_size" flag:"ecdsa-key-size" usage:"ECDSA key size of new devices in bits: 256, 384 or 521"`
+	TransactionTimeout Duration `yaml:"transaction_timeout" flag:"transaction-timeout" usage:"duration after which active transactions without changes are flagged"`
+}
+
+// LimitsConfig limits the resources of single requests.
+type LimitsConfig struct {
+	MaxBodyBytes    int64 `yaml:"max_body_bytes" flag:"max-body-bytes" usage:"maximum size of request bodies except streamed content; 0 disables the limit"`
+	BulkConcurrency int   `yaml:"bulk_concurrency" flag:"bulk-concurrency" usage:"devices signing in parallel for a single bulk request"`
+}
+
+// LogConfig configures the log records.
+type LogConfig struct {
+	Level    string `yaml:"level" flag:"log-level" usage:"minimum level of log records: debug, info, warn or error"`
+	Format   string `yaml:"format" flag:"log-format" usage:"format of log records: text or json"`
+	Payloads bool   `yaml:"payloads" flag:"log-payloads" usage:"add the signed data to the debug records of created signatures"`
+}
+
+// TraceConfig configures the export of spans.
+type TraceConfig struct {
+	Output string `yaml:"output" flag:"trace-output" usage:"export OpenTelemetry spans as JSON to stdout or a file; empty disables tracing"`
+}
+
+// HealthConfig configures additional readiness checks.
+type HealthConfig struct {
+	DiskPath    string `yaml:"disk_path" flag:"health-disk-path" usage:"report the free space of the file system of the path on /readyz; empty disables the check"`
+	DiskMinFree uint64 `yaml:"disk_min_free" flag:"health-disk-min-free" usage:"free bytes below which /readyz fails, it warns below twice the amount"`
+}
+
+// Default returns the configuration used for settings that are not configured.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			ListenAddress:     ":8080",
+			ReadHeaderTimeout: Duration(api.DefaultReadHeaderTimeout),
+			ReadTimeout:       Duration(api.DefaultReadTimeout),
+			WriteTimeout:      Duration(api.DefaultWriteTimeout),
+			IdleTimeout:       Duration(api.DefaultIdleTimeout),
+			ShutdownTimeout:   Duration(api.DefaultShutdownTimeout),
+		},
+		TLS: TLSConfig{
+			ReloadInterval: Duration(tlsconfig.DefaultReloadInterval),
+			ClientAuth:     tlsconfig.ClientAuthNone,
+			ClientIdentity: ClientIdentityCommonName,
+		},
+		Storage: StorageConfig{
+			Backend: StorageMemory,
+		},
+		Signing: SigningConfig{
+			Algorithms:         []string{string(crypto.SignatureRSA), string(crypto.SignautreECDSA)},
+			RSAKeySize:         crypto.DefaultRSAKeySize,
+			ECDSAKeySize:       crypto.DefaultECDSAKeySize,
+			TransactionTimeout: Duration(api.DefaultTransactionTimeout),
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes:    api.DefaultMaxBodyBytes,
+			BulkConcurrency: api.DefaultBulkConcurrency,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: api.LogFormatText,
+		},
+		Health: HealthConfig{
+			DiskMinFree: 100 << 20,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the file of the -config flag or SIGNING_CONFIG, the
+// environment and the flags in args, and validates it. printConfig is set by the -print-config flag. Flag usage
+// and errors are written to output; -h returns flag.ErrHelp.
+func Load(name string, args []string, lookupEnv func(key string) (string, bool), output io.Writer) (cfg Config, printConfig bool, err error) {
+	cfg = Default()
+	settings := settingsOf(&cfg)
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	file := flags.String("config", "", "YAML configuration file, default $"+EnvConfigFile)
+	flags.BoolVar(&printConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	values := map[string]string{}
+	for _, s := range settings {
+		flags.Func(s.flag, fmt.Sprintf("%s (default %q, $%s)", s.usage, s.String(), s.env), func(value string) error {
+			values[s.flag] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	if *file == "" {
+		*file, _ = lookupEnv(EnvConfigFile)
+	}
+	if *file != "" {
+		if err := readFile(*file, &cfg); err != nil {
+			return Config{}, false, err
+		}
+	}
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok {
+			if err := s.Set(value); err != nil {
+				return Config{}, false, fmt.Errorf("Load | $%s: %w", s.env, err)
+			}
+		}
+	}
+	// flags are applied last, in the order of the settings, so they override file and environment
+	for _, s := range settings {
+		if value, ok := values[s.flag]; ok {
+			if err := s.Set(value); err != nil {
+				return Config{}, false, fmt.Errorf("Load | -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, false, err
+	}
+	return cfg, printConfig, nil
+}
+
+// readFile decodes the YAML file into cfg. Unknown keys are rejected, so typos do not go unnoticed.
+func readFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Load | %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Load | %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the configuration and reports all invalid settings at once.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.ListenAddress); err != nil {
+		invalid("server.listen_address: %v", err)
+	}
+	for name, d := range map[string]Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+	} {
+		if d < 0 {
+			invalid("%s: must not be negative", name)
+		}
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		invalid("server.shutdown_timeout: must be positive")
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		invalid("tls: cert_file and key_file have to be set together")
+	}
+	if c.TLS.ReloadInterval < 0 {
+		invalid("tls.reload_interval: must not be negative")
+	}
+	if _, err := tlsconfig.ParseClientAuth(c.TLS.ClientAuth); err != nil {
+		invalid("tls.client_auth: unsupported mode %q", c.TLS.ClientAuth)
+	} else if c.TLS.ClientAuth != tlsconfig.ClientAuthNone && (c.TLS.CertFile == "" || c.TLS.ClientCAFile == "") {
+		invalid("tls.client_auth: %s requires cert_file and client_ca_file", c.TLS.ClientAuth)
+	} else if c.TLS.ClientAuth == tlsconfig.ClientAuthNone && c.TLS.ClientCAFile != "" {
+		invalid("tls.client_ca_file: unused with client_auth %s", tlsconfig.ClientAuthNone)
+	}
+	if c.TLS.ClientIdentity != ClientIdentityCommonName && c.TLS.ClientIdentity != ClientIdentitySubject {
+		invalid("tls.client_identity: unsupported identity %q", c.TLS.ClientIdentity)
+	}
+
+	if c.Auth.AdminKey != "" && len(c.Auth.AdminKey) < minAdminKeyLength {
+		invalid("auth.admin_key: must have at least %d characters", minAdminKeyLength)
+	}
+	if c.Auth.Enabled && c.Auth.AdminKey == "" && c.Storage.Backend == StorageMemory {
+		invalid("auth.admin_key: required to issue keys with the %s backend", StorageMemory)
+	}
+
+	switch c.Storage.Backend {
+	case StorageMemory:
+		if c.Storage.DSN != "" {
+			invalid("storage.dsn: not supported by the %s backend", StorageMemory)
+		}
+	default:
+		invalid("storage.backend: unsupported backend %q", c.Storage.Backend)
+	}
+
+	if len(c.Signing.Algorithms) == 0 {
+		invalid("signing.algorithms: at least one algorithm has to be enabled")
+	}
+	seen := map[string]bool{}
+	for _, algorithm := range c.Signing.Algorithms {
+		if !crypto.IsSupportedAlgorithm(algorithm) {
+			invalid("signing.algorithms: unsupported algorithm %q", algorithm)
+		}
+		if seen[algorithm] {
+			invalid("signing.algorithms: %q is listed twice", algorithm)
+		}
+		seen[algorithm] = true
+	}
+	if err := crypto.ValidateKeySize(crypto.SignatureRSA, c.Signing.RSAKeySize); err != nil {
+		invalid("signing.rsa_key_size: %v", err)
+	}
+	if err := crypto.ValidateKeySize(crypto.SignautreECDSA, c.Signing.ECDSAKeySize); err != nil {
+		invalid("signing.ecdsa_key_size: %v", err)
+	}
+	if c.Signing.TransactionTimeout <= 0 {
+		invalid("signing.transaction_timeout: must be positive")
+	}
+
+	if c.Limits.MaxBodyBytes < 0 {
+		invalid("limits.max_body_bytes: must not be negative")
+	}
+	if c.Limits.BulkConcurrency < 1 {
+		invalid("limits.bulk_concurrency: must be at least 1")
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		invalid("log.level: %v", err)
+	}
+	if c.Log.Format != api.LogFormatText && c.Log.Format != api.LogFormatJSON {
+		invalid("log.format: unsupported format %q", c.Log.Format)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+// SlogLevel parses the level.
+func (c LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return 0, err
+	}
+	return level, nil
+}
+
+// Redacted returns a copy of the configuration with all set secrets replaced by Redacted.
+func (c Config) Redacted() Config {
+	for _, s := range settingsOf(&c) {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString(Redacted)
+		}
+	}
+	return c
+}
+
+// Print writes the configuration with secrets redacted as YAML.
+func (c Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return fmt.Errorf("Print | %w", err)
+	}
+	return encoder.Close()
+}
+
+// Duration is a time.Duration written like "30s" in files, environment variables and flags.
+type Duration time.Duration
+
+// Duration returns d as time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// setting is a configurable field of Config.
+type setting struct {
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// settingsOf lists the fields of the sections of cfg in declaration order.
+func settingsOf(cfg *Config) []setting {
+	var settings []setting
+	sections := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		sectionName := sections.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			path := sectionName + "." + field.Tag.Get("yaml")
+			settings = append(settings, setting{
+				path:   path,
+				env:    EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+				flag:   field.Tag.Get("flag"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  section.Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+// String formats the value like it is set.
+func (s setting) String() string {
+	switch v := s.value.Addr().Interface().(type) {
+	case *Duration:
+		return time.Duration(*v).String()
+	case *[]string:
+		return strings.Join(*v, ",")
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// Set parses the value of an environment variable or flag into the field.
+func (s setting) Set(value string) error {
+	switch v := s.value.Addr().Interface().(type) {
+	case *string:
+		*v = value
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *uint64:
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	case *Duration:
+		return v.UnmarshalText([]byte(value))
+	case *[]string:
+		*v = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T of %s", v, s.path)
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+// Package conformance is a black-box test suite for the signing API. It drives a running server over HTTP only and
+// checks the rules of the specification with the v0 endpoints of the README: the format of the secured data
+// <counter>_<data>_<last_signature_b64>, the device ID as last signature of counter 0, counters increasing by
+// exactly one and the list operation reflecting created devices. Extensions beyond the specification are checked
+// in optional sub-suites. It does not depend on the implementation, so it can be run against any backend.
+package conformance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Algorithms are the signature algorithms required by the specification.
+var Algorithms = []string{"RSA", "ECDSA"}
+
+// Suite runs the conformance tests against the signing API at BaseURL.
+type Suite struct {
+	// BaseURL is the scheme and host of the server, e.g. http://localhost:8080.
+	BaseURL string
+	// Client sends the requests. It defaults to http.DefaultClient.
+	Client *http.Client
+	// Header is added to every request, e.g. for authorization.
+	Header http.Header
+	// Signatures is the number of signatures created per check. It defaults to 5.
+	Signatures int
+	// Concurrency is the number of clients signing in parallel in the concurrency check. It defaults to 8.
+	Concurrency int
+
+	// V1 checks the device management endpoints under /api/v1/devices.
+	V1 bool
+	// PublicKeys verifies signatures with the key exported by GET /api/v1/devices/{id}/public-key.
+	PublicKeys bool
+	// ClientIDs checks that devices with clients registered by PUT /api/v1/devices/{id}/clients/{client_id} only
+	// sign on their behalf and that devices in the format of the README do not accept clients.
+	ClientIDs bool
+}
+
+// device is the state of a signature device as returned by the API.
+type device struct {
+	ID        string `json:"id"`
+	Label     string `json:"label"`
+	Algorithm string `json:"signature_algorithm"`
+}
+
+// deviceInfo is the state of a signature device as returned by the v1 endpoints.
+type deviceInfo struct {
+	device
+	SignatureCounter int `json:"signature_counter"`
+}
+
+// signature is a signature as returned by the API.
+type signature struct {
+	Counter    int    `json:"counter"`
+	ClientID   string `json:"client_id"`
+	SignedData string `json:"signed_data"`
+	Signature  string `json:"signature"`
+}
+
+// Run runs all checks as subtests of t. Extensions only run if enabled.
+func (s Suite) Run(t *testing.T) {
+	for _, algorithm := range Algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			t.Run("create and list", func(t *testing.T) { s.testCreateAndList(t, algorithm) })
+			t.Run("secured data", func(t *testing.T) { s.testSecuredData(t, algorithm) })
+			t.Run("concurrent signatures", func(t *testing.T) { s.testConcurrentSignatures(t, algorithm) })
+		})
+	}
+	t.Run("unknown device", s.testUnknownDevice)
+	t.Run("unsupported algorithm", s.testUnsupportedAlgorithm)
+
+	if s.V1 {
+		t.Run("v1", func(t *testing.T) {
+			for _, algorithm := range Algorithms {
+				t.Run(algorithm, func(t *testing.T) { s.testV1Devices(t, algorithm) })
+			}
+		})
+	}
+	if s.PublicKeys {
+		t.Run("public keys", func(t *testing.T) {
+			for _, algorithm := range Algorithms {
+				t.Run(algorithm, func(t *testing.T) { s.testPublicKey(t, algorithm) })
+			}
+		})
+	}
+	if s.ClientIDs {
+		t.Run("client ids", s.testClientIDs)
+	}
+}
+
+func (s Suite) testCreateAndList(t *testing.T, algorithm string) {
+	id := uuid.NewString()
+	label := "conformance " + algorithm
+
+	created := device{}
+	s.mustDo(t, "POST", createPath(id, label, algorithm), nil, http.StatusOK, &created)
+	expected := device{ID: id, Label: label, Algorithm: algorithm}
+	assert.Equal(t, expected, created)
+
+	devices := []device{}
+	s.mustDo(t, "GET", "/api/v0/devices", nil, http.StatusOK, &devices)
+	assert.Contains(t, devices, expected)
+}
+
+func (s Suite) testSecuredData(t *testing.T, algorithm string) {
+	id := s.createDevice(t, algorithm)
+
+	// the separator may occur in the data itself
+	payloads := []string{"first", "", "with_separator_", "unicode ✓"}
+	for len(payloads) < s.signatures() {
+		payloads = append(payloads, fmt.Sprintf("payload %d", len(payloads)))
+	}
+
+	lastSignature := base64.StdEncoding.EncodeToString([]byte(id))
+	for counter, data := range payloads {
+		sig := s.sign(t, id, "", data)
+
+		assert.Equal(t, counter, sig.Counter, "counter increments by one")
+		expected := fmt.Sprintf("%d_%s_%s", counter, data, lastSignature)
+		assert.Equal(t, expected, sig.SignedData, "secured data of counter %d", counter)
+		_, err := base64.StdEncoding.DecodeString(sig.Signature)
+		assert.NoError(t, err, "signature of counter %d is base64 encoded", counter)
+
+		lastSignature = sig.Signature
+	}
+}
+
+func (s Suite) testConcurrentSignatures(t *testing.T, algorithm string) {
+	id := s.createDevice(t, algorithm)
+
+	var mu sync.Mutex
+	var signatures []signature
+	var wg sync.WaitGroup
+	for worker := 0; worker < s.concurrency(); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < s.signatures(); i++ {
+				sig, err := s.trySign(id, "", fmt.Sprintf("worker %d payload %d", worker, i))
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				signatures = append(signatures, sig)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, signatures, s.concurrency()*s.signatures())
+
+	// the counters have no gaps and every signature chains to its predecessor
+	sort.Slice(signatures, func(i, j int) bool { return signatures[i].Counter < signatures[j].Counter })
+	lastSignature := base64.StdEncoding.EncodeToString([]byte(id))
+	for i, sig := range signatures {
+		require.Equal(t, i, sig.Counter, "counters are gapless")
+		prefix := fmt.Sprintf("%d_", i)
+		assert.True(t, strings.HasPrefix(sig.SignedData, prefix), "prefix of counter %d", i)
+		assert.True(t, strings.HasSuffix(sig.SignedData, "_"+lastSignature), "chaining of counter %d", i)
+		lastSignature = sig.Signature
+	}
+}
+
+func (s Suite) testUnknownDevice(t *testing.T) {
+	s.mustDo(t, "POST", "/api/v0/devices/sign", map[string]string{"id": uuid.NewString(), "data": "data"},
+		http.StatusNotFound, nil)
+}
+
+func (s Suite) testUnsupportedAlgorithm(t *testing.T) {
+	id := uuid.NewString()
+	s.mustDo(t, "POST", createPath(id, "", "DSA"), nil, http.StatusBadRequest, nil)
+
+	devices := []device{}
+	s.mustDo(t, "GET", "/api/v0/devices", nil, http.StatusOK, &devices)
+	for _, d := range devices {
+		assert.NotEqual(t, id, d.ID, "rejected devices are not created")
+	}
+}
+
+// testV1Devices checks that the v1 endpoints create, list and retrieve devices and report their signature
+// counter.
+func (s Suite) testV1Devices(t *testing.T, algorithm string) {
+	id := uuid.NewString()
+	label := "conformance v1 " + algorithm
+
+	created := deviceInfo{}
+	s.mustDo(t, "POST", "/api/v1/devices", map[string]string{"id": id, "label": label, "algorithm": algorithm},
+		http.StatusCreated, &created)
+	expected := deviceInfo{device: device{ID: id, Label: label, Algorithm: algorithm}}
+	assert.Equal(t, expected, created)
+
+	devices := []deviceInfo{}
+	s.mustDo(t, "GET", "/api/v1/devices", nil, http.StatusOK, &devices)
+	assert.Contains(t, devices, expected)
+
+	for i := 0; i < s.signatures(); i++ {
+		s.sign(t, id, "", "data")
+	}
+	retrieved := deviceInfo{}
+	s.mustDo(t, "GET", "/api/v1/devices/"+id, nil, http.StatusOK, &retrieved)
+	assert.Equal(t, s.signatures(), retrieved.SignatureCounter)
+
+	s.mustDo(t, "GET", "/api/v1/devices/"+uuid.NewString(), nil, http.StatusNotFound, nil)
+}
+
+// testPublicKey checks that the signatures verify with the exported public key.
+func (s Suite) testPublicKey(t *testing.T, algorithm string) {
+	id := s.createDevice(t, algorithm)
+	verify := s.verifier(t, id, algorithm)
+
+	for counter := 0; counter < s.signatures(); counter++ {
+		sig := s.sign(t, id, "", fmt.Sprintf("payload %d", counter))
+		assert.True(t, verify(sig), "signature of counter %d", counter)
+	}
+}
+
+// testClientIDs checks that devices with registered clients reject other clients and sign the client of each
+// signature. Clients need the secured data format v1, the format of the README has no room for them.
+func (s Suite) testClientIDs(t *testing.T) {
+	readme := s.createDevice(t, Algorithms[0])
+	s.mustDo(t, "PUT", "/api/v1/devices/"+readme+"/clients/conformance", nil, http.StatusConflict, nil)
+
+	id := uuid.NewString()
+	s.mustDo(t, "POST", createPath(id, "", Algorithms[0])+"&secured_data_format=v1", nil, http.StatusOK, nil)
+	clientID := "conformance-" + id[:8]
+	s.mustDo(t, "PUT", "/api/v1/devices/"+id+"/clients/"+clientID, nil, http.StatusOK, nil)
+
+	for _, rejected := range []string{"unregistered", ""} {
+		s.mustDo(t, "POST", "/api/v0/devices/sign", map[string]string{"id": id, "client_id": rejected, "data": "data"},
+			http.StatusForbidden, nil)
+	}
+
+	sig := s.sign(t, id, clientID, "data")
+	assert.Equal(t, 0, sig.Counter, "rejected requests do not count")
+	assert.Equal(t, clientID, sig.ClientID)
+	lastSignature := base64.StdEncoding.EncodeToString([]byte(id))
+	expected := fmt.Sprintf("v1:1:0,%d:%s,4:DATA,4:data,%d:%s,", len(clientID), clientID, len(lastSignature), lastSignature)
+	assert.Equal(t, expected, sig.SignedData, "the client is part of the secured data")
+}
+
+// createPath returns the v0 path that creates a device.
+func createPath(id string, label string, algorithm string) string {
+	query := url.Values{"id": {id}, "label": {label}, "algorithm": {algorithm}}
+	return "/api/v0/devices/create?" + query.Encode()
+}
+
+// createDevice creates a device with the v0 endpoint and returns its ID.
+func (s Suite) createDevice(t *testing.T, algorithm string) string {
+	t.Helper()
+	id := uuid.NewString()
+	s.mustDo(t, "POST", createPath(id, "", algorithm), nil, http.StatusOK, nil)
+	return id
+}
+
+// sign signs data with the device on behalf of the client. Without client ID, no client_id is sent.
+func (s Suite) sign(t *testing.T, id string, clientID string, data string) signature {
+	t.Helper()
+	sig, err := s.trySign(id, clientID, data)
+	require.NoError(t, err)
+	return sig
+}
+
+// trySign works like sign, but reports failures instead of failing the test. It is safe for other goroutines.
+func (s Suite) trySign(id string, clientID string, data string) (signature, error) {
+	payload := map[string]string{"id": id, "data": data}
+	if clientID != "" {
+		payload["client_id"] = clientID
+	}
+	sig := signature{}
+	status, err := s.do("POST", "/api/v0/devices/sign", payload, &sig)
+	if err != nil {
+		return signature{}, err
+	}
+	if status != http.StatusOK {
+		return signature{}, fmt.Errorf("sign: unexpected status %d", status)
+	}
+	return sig, nil
+}
+
+// verifier returns a function that checks signatures with the public key exported for the device. The key is
+// parsed independently of the server implementation: PKCS #1 for RSA and PKIX for ECDSA, both over SHA-256.
+func (s Suite) verifier(t *testing.T, id string, algorithm string) func(signature) bool {
+	t.Helper()
+	exported := struct {
+		Algorithm string `json:"signature_algorithm"`
+		PublicKey string `json:"public_key"`
+	}{}
+	s.mustDo(t, "GET", "/api/v1/devices/"+id+"/public-key", nil, http.StatusOK, &exported)
+	require.Equal(t, algorithm, exported.Algorithm)
+
+	block, _ := pem.Decode([]byte(exported.PublicKey))
+	require.NotNil(t, block, "public key is PEM encoded")
+
+	var check func(hash []byte, sig []byte) bool
+	switch algorithm {
+	case "RSA":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		require.NoError(t, err)
+		check = func(hash []byte, sig []byte) bool {
+			return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash, sig) == nil
+		}
+	case "ECDSA":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		require.NoError(t, err)
+		key, ok := parsed.(*ecdsa.PublicKey)
+		require.True(t, ok, "ECDSA public key")
+		check = func(hash []byte, sig []byte) bool {
+			return ecdsa.VerifyASN1(key, hash, sig)
+		}
+	default:
+		require.Fail(t, "unknown algorithm", algorithm)
+	}
+
+	return func(sig signature) bool {
+		raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+		if err != nil {
+			return false
+		}
+		hash := sha256.Sum256([]byte(sig.SignedData))
+		return check(hash[:], raw)
+	}
+}
+
+// mustDo sends the request, requires the expected status and decodes the data of the response into v (if not nil).
+func (s Suite) mustDo(t *testing.T, method string, path string, payload interface{}, status int, v interface{}) {
+	t.Helper()
+	got, err := s.do(method, path, payload, v)
+	require.NoError(t, err)
+	require.Equal(t, status, got, "%s %s", method, path)
+}
+
+// do sends a JSON request and decodes the data of a successful response into v (if not nil).
+func (s Suite) do(method string, path string, payload interface{}, v interface{}) (int, error) {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequest(method, strings.TrimSuffix(s.BaseURL, "/")+path, body)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	for key, values := range s.Header {
+		request.Header[key] = values
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if v != nil && response.StatusCode < 300 {
+		envelope := struct {
+			Data interface{} `json:"data"`
+		}{Data: v}
+		if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+			return response.StatusCode, fmt.Errorf("%s %s: decode: %w", method, path, err)
+		}
+	}
+	return response.StatusCode, nil
+}
+
+func (s Suite) signatures() int {
+	if s.Signatures > 0 {
+		return s.Signatures
+	}
+	return 5
+}
+
+func (s Suite) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return 8
+}
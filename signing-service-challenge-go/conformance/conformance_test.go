@@ -0,0 +1,30 @@
+package conformance
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+)
+
+// BaseURLEnv names the environment variable with the base URL of an external server to test. Without it, the
+// suite runs against an in-process server.
+const BaseURLEnv = "CONFORMANCE_BASE_URL"
+
+// ExtensionsEnv names the environment variable that enables the extensions for an external server if "true". They
+// are always checked against the in-process server.
+const ExtensionsEnv = "CONFORMANCE_EXTENSIONS"
+
+func TestConformance(t *testing.T) {
+	baseURL := os.Getenv(BaseURLEnv)
+	extensions := os.Getenv(ExtensionsEnv) == "true"
+	if baseURL == "" {
+		server := httptest.NewServer(api.NewServer("").Handler())
+		defer server.Close()
+		baseURL = server.URL
+		extensions = true
+	}
+
+	Suite{BaseURL: baseURL, V1: extensions, PublicKeys: extensions, ClientIDs: extensions}.Run(t)
+}
@@ -0,0 +1,214 @@
+package crypto
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE algorithm identifiers as registered by RFC 9053 and RFC 8812.
+const (
+	COSEAlgorithmES256 int64 = -7
+	COSEAlgorithmES384 int64 = -35
+	COSEAlgorithmES512 int64 = -36
+	COSEAlgorithmRS256 int64 = -257
+)
+
+// coseECDSAAlgorithms maps the curves of ECDSA keys to the COSE algorithm and the hash it prescribes
+// (RFC 9053 Section 2.1).
+var coseECDSAAlgorithms = map[string]struct {
+	alg  int64
+	hash crypto.Hash
+}{
+	"P-256": {COSEAlgorithmES256, crypto.SHA256},
+	"P-384": {COSEAlgorithmES384, crypto.SHA384},
+	"P-521": {COSEAlgorithmES512, crypto.SHA512},
+}
+
+const (
+	coseHeaderAlgorithm = 1
+	coseSign1Tag        = 18
+	coseSign1Context    = "Signature1"
+)
+
+// coseEncMode produces deterministically encoded CBOR as required for COSE structures.
+var coseEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// coseSign1 is the untagged COSE_Sign1 structure (RFC 9052 Section 4.2).
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[interface{}]interface{}
+	Payload     []byte
+	Signature   []byte
+}
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// COSEAlgorithm returns the COSE algorithm identifier of COSE_Sign1 messages signed by signer: RS256 for RSA,
+// ES256, ES384 or ES512 depending on the curve for ECDSA.
+func COSEAlgorithm(signer Signer) (int64, error) {
+	alg, _, err := coseAlgorithm(signer)
+	if err != nil {
+		return 0, fmt.Errorf("COSEAlgorithm | %w", err)
+	}
+	return alg, nil
+}
+
+// coseAlgorithm returns the COSE algorithm identifier of signer and the hash the algorithm signs with.
+func coseAlgorithm(signer Signer) (int64, crypto.Hash, error) {
+	switch s := signer.(type) {
+	case RSASigner:
+		return COSEAlgorithmRS256, crypto.SHA256, nil
+	case ECDSASigner:
+		curve := s.key.Public.Curve.Params().Name
+		algorithm, ok := coseECDSAAlgorithms[curve]
+		if !ok {
+			return 0, 0, fmt.Errorf("unsupported curve: %s", curve)
+		}
+		return algorithm.alg, algorithm.hash, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported signer: %T", signer)
+}
+
+// SignCOSE1 signs the payload with the provided signer and returns a tagged COSE_Sign1 message (RFC 9052).
+func SignCOSE1(signer Signer, payload []byte) ([]byte, error) {
+	alg, hash, err := coseAlgorithm(signer)
+	if err != nil {
+		return nil, fmt.Errorf("SignCOSE1 | %w", err)
+	}
+	protected, err := coseEncMode.Marshal(map[int64]int64{coseHeaderAlgorithm: alg})
+	if err != nil {
+		return nil, fmt.Errorf("SignCOSE1 protected header | %w", err)
+	}
+	toBeSigned, err := coseSigStructure(protected, payload)
+	if err != nil {
+		return nil, fmt.Errorf("SignCOSE1 | %w", err)
+	}
+
+	signature, err := coseSign(signer, hash, toBeSigned)
+	if err != nil {
+		return nil, fmt.Errorf("SignCOSE1 sign | %w", err)
+	}
+	signature, err = toCOSESignature(signer, signature)
+	if err != nil {
+		return nil, fmt.Errorf("SignCOSE1 | %w", err)
+	}
+
+	msg := cbor.Tag{
+		Number: coseSign1Tag,
+		Content: coseSign1{
+			Protected:   protected,
+			Unprotected: map[interface{}]interface{}{},
+			Payload:     payload,
+			Signature:   signature,
+		},
+	}
+	return coseEncMode.Marshal(msg)
+}
+
+// VerifyCOSE1 verifies a tagged COSE_Sign1 message created by SignCOSE1 and returns its payload.
+func VerifyCOSE1(signer Signer, msg []byte) ([]byte, error) {
+	var tag cbor.RawTag
+	if err := cbor.Unmarshal(msg, &tag); err != nil {
+		return nil, fmt.Errorf("VerifyCOSE1 decode | %w", err)
+	}
+	if tag.Number != coseSign1Tag {
+		return nil, fmt.Errorf("VerifyCOSE1 | unexpected tag: %d", tag.Number)
+	}
+	var sign1 coseSign1
+	if err := cbor.Unmarshal(tag.Content, &sign1); err != nil {
+		return nil, fmt.Errorf("VerifyCOSE1 decode | %w", err)
+	}
+
+	header := map[int64]int64{}
+	if err := cbor.Unmarshal(sign1.Protected, &header); err != nil {
+		return nil, fmt.Errorf("VerifyCOSE1 protected header | %w", err)
+	}
+	alg, hash, err := coseAlgorithm(signer)
+	if err != nil {
+		return nil, fmt.Errorf("VerifyCOSE1 | %w", err)
+	}
+	if header[coseHeaderAlgorithm] != alg {
+		return nil, fmt.Errorf("VerifyCOSE1 | algorithm mismatch: %d", header[coseHeaderAlgorithm])
+	}
+
+	toBeSigned, err := coseSigStructure(sign1.Protected, sign1.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("VerifyCOSE1 | %w", err)
+	}
+	signature, err := fromCOSESignature(signer, sign1.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("VerifyCOSE1 | %w", err)
+	}
+	if !coseVerify(signer, hash, toBeSigned, signature) {
+		return nil, fmt.Errorf("VerifyCOSE1 | invalid signature")
+	}
+	return sign1.Payload, nil
+}
+
+// coseSign signs with the hash of the COSE algorithm. RS256 hashes with SHA-256 like every signer.
+func coseSign(signer Signer, hash crypto.Hash, toBeSigned []byte) ([]byte, error) {
+	if s, ok := signer.(ECDSASigner); ok {
+		return s.signWithHash(hash, toBeSigned)
+	}
+	return signer.Sign(toBeSigned)
+}
+
+// coseVerify reverses coseSign.
+func coseVerify(signer Signer, hash crypto.Hash, toBeSigned []byte, signature []byte) bool {
+	if s, ok := signer.(ECDSASigner); ok {
+		return s.verifyWithHash(hash, toBeSigned, signature)
+	}
+	return signer.Verify(toBeSigned, signature)
+}
+
+// coseSigStructure builds the Sig_structure for COSE_Sign1 without external additional data.
+func coseSigStructure(protected []byte, payload []byte) ([]byte, error) {
+	sigStructure := []interface{}{coseSign1Context, protected, []byte{}, payload}
+	toBeSigned, err := coseEncMode.Marshal(sigStructure)
+	if err != nil {
+		return nil, fmt.Errorf("coseSigStructure | %w", err)
+	}
+	return toBeSigned, nil
+}
+
+// toCOSESignature converts ASN.1 encoded ECDSA signatures into the fixed size r|s form COSE expects.
+// Other signatures are returned unchanged.
+func toCOSESignature(signer Signer, signature []byte) ([]byte, error) {
+	s, ok := signer.(ECDSASigner)
+	if !ok {
+		return signature, nil
+	}
+	var sig ecdsaSignature
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		return nil, fmt.Errorf("toCOSESignature | %w", err)
+	}
+	size := s.coordinateSize()
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// fromCOSESignature reverses toCOSESignature.
+func fromCOSESignature(signer Signer, signature []byte) ([]byte, error) {
+	s, ok := signer.(ECDSASigner)
+	if !ok {
+		return signature, nil
+	}
+	size := s.coordinateSize()
+	if len(signature) != 2*size {
+		return nil, fmt.Errorf("fromCOSESignature | invalid signature length: %d", len(signature))
+	}
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(signature[:size]),
+		S: new(big.Int).SetBytes(signature[size:]),
+	})
+}
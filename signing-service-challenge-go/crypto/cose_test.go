@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCOSEAlgorithm(t *testing.T) {
+	rsaSigner, err := NewRSASigner()
+	require.Nil(t, err)
+	alg, err := COSEAlgorithm(rsaSigner)
+	require.Nil(t, err)
+	assert.Equal(t, COSEAlgorithmRS256, alg)
+
+	for bits, expected := range map[int]int64{256: COSEAlgorithmES256, 384: COSEAlgorithmES384, 521: COSEAlgorithmES512} {
+		signer, err := newECDSASigner(nil, bits)
+		require.Nil(t, err)
+		alg, err := COSEAlgorithm(signer)
+		require.Nil(t, err)
+		assert.Equal(t, expected, alg, "P-%d", bits)
+	}
+
+	_, err = COSEAlgorithm(nil)
+	assert.NotNil(t, err, "expect error for unsupported signer")
+}
+
+func TestSignCOSE1(t *testing.T) {
+	payload := []byte("0_data_bGFzdA==")
+	for _, algorithm := range []SignatureAlgorithm{SignatureRSA, SignautreECDSA} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			signer, err := NewSigner(algorithm)
+			require.Nil(t, err)
+
+			msg, err := SignCOSE1(signer, payload)
+			require.Nil(t, err)
+
+			var tag cbor.RawTag
+			require.Nil(t, cbor.Unmarshal(msg, &tag))
+			assert.Equal(t, uint64(coseSign1Tag), tag.Number)
+
+			gotPayload, err := VerifyCOSE1(signer, msg)
+			require.Nil(t, err)
+			assert.Equal(t, payload, gotPayload)
+		})
+	}
+	t.Run("ecdsa raw signature size", func(t *testing.T) {
+		signer, err := NewECDSASigner()
+		require.Nil(t, err)
+
+		msg, err := SignCOSE1(signer, payload)
+		require.Nil(t, err)
+
+		var tag cbor.RawTag
+		require.Nil(t, cbor.Unmarshal(msg, &tag))
+		var sign1 coseSign1
+		require.Nil(t, cbor.Unmarshal(tag.Content, &sign1))
+		assert.Equal(t, 96, len(sign1.Signature), "P-384 signatures are encoded as r|s with 48 bytes each")
+	})
+	t.Run("ecdsa curves", func(t *testing.T) {
+		for bits, hash := range map[int]crypto.Hash{256: crypto.SHA256, 384: crypto.SHA384, 521: crypto.SHA512} {
+			signer, err := newECDSASigner(nil, bits)
+			require.Nil(t, err)
+			msg, err := SignCOSE1(signer, payload)
+			require.Nil(t, err)
+
+			var tag cbor.RawTag
+			require.Nil(t, cbor.Unmarshal(msg, &tag))
+			var sign1 coseSign1
+			require.Nil(t, cbor.Unmarshal(tag.Content, &sign1))
+			header := map[int64]int64{}
+			require.Nil(t, cbor.Unmarshal(sign1.Protected, &header))
+			alg, err := COSEAlgorithm(signer)
+			require.Nil(t, err)
+			assert.Equal(t, alg, header[coseHeaderAlgorithm])
+
+			// verify like any COSE implementation would: r|s over the hash the algorithm prescribes
+			toBeSigned, err := coseSigStructure(sign1.Protected, sign1.Payload)
+			require.Nil(t, err)
+			h := hash.New()
+			h.Write(toBeSigned)
+			size := signer.coordinateSize()
+			require.Len(t, sign1.Signature, 2*size)
+			r := new(big.Int).SetBytes(sign1.Signature[:size])
+			sig := new(big.Int).SetBytes(sign1.Signature[size:])
+			assert.True(t, ecdsa.Verify(signer.key.Public, h.Sum(nil), r, sig), "P-%d", bits)
+		}
+	})
+	t.Run("deterministic ecdsa", func(t *testing.T) {
+		signer, err := newECDSASigner(nil, 384)
+		require.Nil(t, err)
+		signer = signer.WithDeterministicNonces()
+		msg, err := SignCOSE1(signer, payload)
+		require.Nil(t, err)
+		again, err := SignCOSE1(signer, payload)
+		require.Nil(t, err)
+		assert.Equal(t, msg, again)
+		_, err = VerifyCOSE1(signer, msg)
+		assert.Nil(t, err)
+	})
+	t.Run("tampered payload", func(t *testing.T) {
+		signer, err := NewRSASigner()
+		require.Nil(t, err)
+
+		msg, err := SignCOSE1(signer, payload)
+		require.Nil(t, err)
+
+		var tag cbor.RawTag
+		require.Nil(t, cbor.Unmarshal(msg, &tag))
+		var sign1 coseSign1
+		require.Nil(t, cbor.Unmarshal(tag.Content, &sign1))
+		sign1.Payload = []byte("1_data_bGFzdA==")
+		tampered, err := coseEncMode.Marshal(cbor.Tag{Number: coseSign1Tag, Content: sign1})
+		require.Nil(t, err)
+
+		_, err = VerifyCOSE1(signer, tampered)
+		assert.NotNil(t, err, "expect error for tampered payload")
+	})
+	t.Run("wrong key", func(t *testing.T) {
+		signer, err := NewECDSASigner()
+		require.Nil(t, err)
+		other, err := NewECDSASigner()
+		require.Nil(t, err)
+
+		msg, err := SignCOSE1(signer, payload)
+		require.Nil(t, err)
+
+		_, err = VerifyCOSE1(other, msg)
+		assert.NotNil(t, err, "expect error for foreign key")
+	})
+}
@@ -1,33 +1,70 @@
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/rand"
-	"crypto/sha256"
 	"fmt"
 )
 
 // ECDSASigner holds the keys and signs data with ECDSA
 type ECDSASigner struct {
-	key *ECCKeyPair
+	key           *ECCKeyPair
+	entropy       Entropy
+	deterministic bool
 }
 
 // NewECDSASigner gnereates an ECDSASigner with a key pair
 func NewECDSASigner() (ECDSASigner, error) {
-	g := ECCGenerator{}
+	return NewECDSASignerWithEntropy(SystemEntropy())
+}
+
+// NewECDSASignerWithEntropy generates an ECDSASigner that draws key and signature randomness from entropy.
+func NewECDSASignerWithEntropy(entropy Entropy) (ECDSASigner, error) {
+	return newECDSASigner(entropy, 0)
+}
+
+// newECDSASigner generates an ECDSASigner with a key of the size in bits, the default size if zero.
+func newECDSASigner(entropy Entropy, bits int) (ECDSASigner, error) {
+	g := ECCGenerator{Entropy: entropy, Bits: bits}
 	key, err := g.Generate()
 	if err != nil {
 		return ECDSASigner{}, fmt.Errorf("NewECDSASigner | %w", err)
 	}
 	return ECDSASigner{
-		key: key,
+		key:     key,
+		entropy: orSystemEntropy(entropy),
 	}, nil
 }
 
-// Sign produces a digital signature for the provided payload
+// WithDeterministicNonces returns a copy of the signer that derives the nonce of each signature from the key and
+// the payload as specified by RFC 6979 instead of drawing it from entropy.
+func (s ECDSASigner) WithDeterministicNonces() ECDSASigner {
+	s.deterministic = true
+	return s
+}
+
+// Deterministic reports whether the signer produces the same signature for the same payload.
+func (s ECDSASigner) Deterministic() bool {
+	return s.deterministic
+}
+
+// Sign produces a digital signature for the provided payload.
+// ECDSA signatures are randomized, they differ even for the same entropy, unless the signer uses deterministic nonces.
 func (s ECDSASigner) Sign(dataTobeSigned []byte) ([]byte, error) {
-	hash := sha256.Sum256(dataTobeSigned)
-	signature, err := ecdsa.SignASN1(rand.Reader, s.key.Private, hash[:])
+	return s.signWithHash(crypto.SHA256, dataTobeSigned)
+}
+
+// signWithHash works like Sign, but signs the digest of the hash function instead of SHA-256.
+func (s ECDSASigner) signWithHash(hash crypto.Hash, dataTobeSigned []byte) ([]byte, error) {
+	digest := hashData(hash, dataTobeSigned)
+	if s.deterministic {
+		signature, err := signRFC6979(s.key.Private, hash, digest)
+		if err != nil {
+			return nil, fmt.Errorf("ECDSASigner.Sign | %w", err)
+		}
+		return signature, nil
+	}
+	signature, err := ecdsa.SignASN1(orSystemEntropy(s.entropy), s.key.Private, digest)
 	if err != nil {
 		return nil, fmt.Errorf("ECDSASigner.SignASN1 | %w", err)
 	}
@@ -35,6 +72,24 @@ func (s ECDSASigner) Sign(dataTobeSigned []byte) ([]byte, error) {
 }
 
 func (s ECDSASigner) Verify(dataToBeSigned []byte, signature []byte) bool {
-	hash := sha256.Sum256(dataToBeSigned)
-	return ecdsa.VerifyASN1(s.key.Public, hash[:], signature)
+	return ECDSAVerifier{key: s.key.Public}.Verify(dataToBeSigned, signature)
+}
+
+// verifyWithHash checks a signature created by signWithHash.
+func (s ECDSASigner) verifyWithHash(hash crypto.Hash, dataToBeSigned []byte, signature []byte) bool {
+	return ecdsa.VerifyASN1(s.key.Public, hashData(hash, dataToBeSigned), signature)
+}
+
+// PublicKey returns the PEM encoded public key of the signer
+func (s ECDSASigner) PublicKey() ([]byte, error) {
+	publicKey, _, err := NewECCMarshaler().Encode(*s.key)
+	if err != nil {
+		return nil, fmt.Errorf("ECDSASigner.PublicKey | %w", err)
+	}
+	return publicKey, nil
+}
+
+// coordinateSize returns the byte length of a single curve coordinate of the signer key.
+func (s ECDSASigner) coordinateSize() int {
+	return (s.key.Public.Curve.Params().BitSize + 7) / 8
 }
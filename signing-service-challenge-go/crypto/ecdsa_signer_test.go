@@ -1,9 +1,11 @@
 package crypto
 
 import (
+	"encoding/pem"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewECDSASigner(t *testing.T) {
@@ -44,3 +46,37 @@ func TestECDSASign(t *testing.T) {
 		assert.True(t, verified)
 	})
 }
+
+func TestECDSAPublicKey(t *testing.T) {
+	signer, err := NewECDSASigner()
+	assert.Nil(t, err)
+
+	publicKey, err := signer.PublicKey()
+	assert.Nil(t, err)
+
+	block, _ := pem.Decode(publicKey)
+	assert.NotNil(t, block)
+	assert.Equal(t, "PUBLIC_KEY", block.Type)
+}
+
+func TestDeterministicECDSASigner(t *testing.T) {
+	signer, err := NewECDSASigner()
+	require.Nil(t, err)
+	assert.False(t, signer.Deterministic())
+
+	deterministic := signer.WithDeterministicNonces()
+	assert.True(t, deterministic.Deterministic())
+	assert.False(t, signer.Deterministic())
+
+	payload := []byte("toBeSigned")
+	first, err := deterministic.Sign(payload)
+	require.Nil(t, err)
+	second, err := deterministic.Sign(payload)
+	require.Nil(t, err)
+	assert.Equal(t, first, second)
+	assert.True(t, signer.Verify(payload, first))
+
+	other, err := deterministic.Sign([]byte("other"))
+	require.Nil(t, err)
+	assert.NotEqual(t, first, other)
+}
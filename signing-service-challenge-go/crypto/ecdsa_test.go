@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECCMarshaler(t *testing.T) {
+	m := NewECCMarshaler()
+	t.Run("round trip", func(t *testing.T) {
+		keyPair, err := (&ECCGenerator{}).Generate()
+		require.Nil(t, err)
+
+		_, private, err := m.Encode(*keyPair)
+		require.Nil(t, err)
+		decoded, err := m.Decode(private)
+		require.Nil(t, err)
+		assert.True(t, keyPair.Private.Equal(decoded.Private))
+		assert.True(t, keyPair.Public.Equal(decoded.Public))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, input := range []string{"", "no pem", "-----BEGIN PRIVATE_KEY-----\nAAAA\n-----END PRIVATE_KEY-----\n"} {
+			keyPair, err := m.Decode([]byte(input))
+			assert.NotNil(t, err, input)
+			assert.Nil(t, keyPair, input)
+		}
+	})
+}
+
+func FuzzECCMarshalerDecode(f *testing.F) {
+	keyPair, err := (&ECCGenerator{}).Generate()
+	require.Nil(f, err)
+	public, private, err := NewECCMarshaler().Encode(*keyPair)
+	require.Nil(f, err)
+	f.Add(private)
+	f.Add(public)
+	f.Add([]byte("-----BEGIN PRIVATE_KEY-----\n\n-----END PRIVATE_KEY-----\n"))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		m := NewECCMarshaler()
+		decoded, err := m.Decode(input)
+		if err != nil {
+			assert.Nil(t, decoded)
+			return
+		}
+		// whatever is accepted survives a round trip
+		_, encoded, err := m.Encode(*decoded)
+		require.Nil(t, err)
+		again, err := m.Decode(encoded)
+		require.Nil(t, err)
+		assert.True(t, decoded.Private.Equal(again.Private))
+	})
+}
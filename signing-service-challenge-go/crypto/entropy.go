@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// Entropy is the source of randomness for key generation and signatures.
+type Entropy interface {
+	io.Reader
+}
+
+// SystemEntropy returns the cryptographically secure randomness of the operating system.
+func SystemEntropy() Entropy {
+	return rand.Reader
+}
+
+// orSystemEntropy returns entropy or the system entropy if it is nil.
+func orSystemEntropy(entropy Entropy) Entropy {
+	if entropy == nil {
+		return SystemEntropy()
+	}
+	return entropy
+}
+
+// DeterministicEntropy expands a seed into a reproducible byte stream (SHA-256 of seed and block counter). It
+// exists for tests and golden vectors: everything derived from it is only as secret as the seed.
+type DeterministicEntropy struct {
+	mu      sync.Mutex
+	seed    []byte
+	counter uint64
+	buffer  []byte
+}
+
+// NewDeterministicEntropy creates a DeterministicEntropy for the seed.
+func NewDeterministicEntropy(seed []byte) *DeterministicEntropy {
+	return &DeterministicEntropy{seed: append([]byte{}, seed...)}
+}
+
+// Read fills p with the next bytes of the stream. It never fails.
+func (e *DeterministicEntropy) Read(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for n := 0; n < len(p); {
+		if len(e.buffer) == 0 {
+			block := make([]byte, 8)
+			binary.BigEndian.PutUint64(block, e.counter)
+			sum := sha256.Sum256(append(append([]byte{}, e.seed...), block...))
+			e.buffer = sum[:]
+			e.counter++
+		}
+		copied := copy(p[n:], e.buffer)
+		e.buffer = e.buffer[copied:]
+		n += copied
+	}
+	return len(p), nil
+}
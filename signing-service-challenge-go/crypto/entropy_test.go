@@ -0,0 +1,27 @@
+package crypto
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministicEntropy(t *testing.T) {
+	read := func(entropy Entropy, sizes ...int) []byte {
+		var out []byte
+		for _, size := range sizes {
+			buf := make([]byte, size)
+			_, err := io.ReadFull(entropy, buf)
+			require.Nil(t, err)
+			out = append(out, buf...)
+		}
+		return out
+	}
+
+	expected := read(NewDeterministicEntropy([]byte("seed")), 100)
+	assert.Equal(t, expected, read(NewDeterministicEntropy([]byte("seed")), 100), "same seed, same stream")
+	assert.Equal(t, expected, read(NewDeterministicEntropy([]byte("seed")), 1, 31, 32, 36), "independent of read sizes")
+	assert.NotEqual(t, expected, read(NewDeterministicEntropy([]byte("other")), 100))
+}
@@ -1,22 +1,91 @@
 package crypto
 
 import (
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 )
 
-// RSAGenerator generates a RSA key pair.
-type RSAGenerator struct{}
+// Default key sizes in bits. ECDSA keys use the NIST curve of the size.
+const (
+	DefaultRSAKeySize   = 512
+	DefaultECDSAKeySize = 384
+)
+
+// Bounds of RSA key sizes. Both primes are generated in whole bytes, so sizes are multiples of 16.
+const (
+	minRSAKeySize = 512
+	maxRSAKeySize = 8192
+)
+
+// ValidateKeySize checks whether keys of the algorithm can be generated with the size in bits: RSA keys from 512
+// to 8192 bits in steps of 16, ECDSA keys of 256, 384 and 521 bits.
+func ValidateKeySize(algorithm SignatureAlgorithm, bits int) error {
+	switch algorithm {
+	case SignatureRSA:
+		if bits < minRSAKeySize || bits > maxRSAKeySize || bits%16 != 0 {
+			return fmt.Errorf("ValidateKeySize | RSA keys need %d to %d bits in steps of 16, got %d", minRSAKeySize, maxRSAKeySize, bits)
+		}
+		return nil
+	case SignautreECDSA:
+		if _, _, err := curveOfSize(bits); err != nil {
+			return fmt.Errorf("ValidateKeySize | %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("ValidateKeySize | unsupported algorithm: %s", algorithm)
+}
+
+// curveOfSize returns the NIST curve with keys of the size in bits.
+func curveOfSize(bits int) (elliptic.Curve, ecdh.Curve, error) {
+	switch bits {
+	case 256:
+		return elliptic.P256(), ecdh.P256(), nil
+	case 384:
+		return elliptic.P384(), ecdh.P384(), nil
+	case 521:
+		return elliptic.P521(), ecdh.P521(), nil
+	}
+	return nil, nil, fmt.Errorf("ECDSA keys need 256, 384 or 521 bits, got %d", bits)
+}
+
+// rsaPublicExponent is the public exponent of generated RSA keys.
+const rsaPublicExponent = 65537
+
+// RSAGenerator generates a RSA key pair with rsa.GenerateKey from Entropy (the system entropy if nil).
+// A DeterministicEntropy is the exception: the standard library generators consume additional random bytes, so
+// for reproducible test keys the key is derived from the bytes of the stream only.
+type RSAGenerator struct {
+	Entropy Entropy
+	// Bits is the key size, DefaultRSAKeySize if zero.
+	Bits int
+}
 
 // Generate generates a new RSAKeyPair.
 func (g *RSAGenerator) Generate() (*RSAKeyPair, error) {
 	// Security has been ignored for the sake of simplicity.
-	key, err := rsa.GenerateKey(rand.Reader, 512)
-	if err != nil {
+	bits := g.Bits
+	if bits == 0 {
+		bits = DefaultRSAKeySize
+	}
+	if err := ValidateKeySize(SignatureRSA, bits); err != nil {
 		return nil, err
 	}
+	var key *rsa.PrivateKey
+	var err error
+	if deterministic, ok := g.Entropy.(*DeterministicEntropy); ok {
+		key, err = deriveRSAKey(deterministic, bits)
+	} else {
+		key, err = rsa.GenerateKey(orSystemEntropy(g.Entropy), bits)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("RSAGenerator | %w", err)
+	}
 
 	return &RSAKeyPair{
 		Public:  &key.PublicKey,
@@ -24,15 +93,33 @@ func (g *RSAGenerator) Generate() (*RSAKeyPair, error) {
 	}, nil
 }
 
-// ECCGenerator generates an ECC key pair.
-type ECCGenerator struct{}
+// ECCGenerator generates an ECC key pair with ecdsa.GenerateKey from Entropy (the system entropy if nil).
+// Like RSAGenerator it derives keys from the bytes of a DeterministicEntropy only.
+type ECCGenerator struct {
+	Entropy Entropy
+	// Bits selects the curve by key size, DefaultECDSAKeySize if zero.
+	Bits int
+}
 
 // Generate generates a new ECCKeyPair.
 func (g *ECCGenerator) Generate() (*ECCKeyPair, error) {
 	// Security has been ignored for the sake of simplicity.
-	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	bits := g.Bits
+	if bits == 0 {
+		bits = DefaultECDSAKeySize
+	}
+	curve, ecdhCurve, err := curveOfSize(bits)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("ECCGenerator | %w", err)
+	}
+	var key *ecdsa.PrivateKey
+	if deterministic, ok := g.Entropy.(*DeterministicEntropy); ok {
+		key, err = deriveECDSAKey(deterministic, curve, ecdhCurve)
+	} else {
+		key, err = ecdsa.GenerateKey(curve, orSystemEntropy(g.Entropy))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ECCGenerator | %w", err)
 	}
 
 	return &ECCKeyPair{
@@ -40,3 +127,100 @@ func (g *ECCGenerator) Generate() (*ECCKeyPair, error) {
 		Private: key,
 	}, nil
 }
+
+// deriveRSAKey derives an RSA key with two primes of half the size from entropy. It is only used for
+// DeterministicEntropy, see RSAGenerator.
+func deriveRSAKey(entropy io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(rsaPublicExponent)
+	one := big.NewInt(1)
+	for {
+		p, err := generatePrime(entropy, bits-bits/2)
+		if err != nil {
+			return nil, fmt.Errorf("deriveRSAKey | %w", err)
+		}
+		q, err := generatePrime(entropy, bits/2)
+		if err != nil {
+			return nil, fmt.Errorf("deriveRSAKey | %w", err)
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+		totient := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, totient)
+		if d == nil {
+			// e is not coprime to the totient
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: rsaPublicExponent},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("deriveRSAKey | %w", err)
+		}
+		key.Precompute()
+		return key, nil
+	}
+}
+
+// generatePrime reads candidates of the given bit length from entropy until one is prime. The top two bits are
+// set so that the product of two primes has exactly twice the length.
+func generatePrime(entropy io.Reader, bits int) (*big.Int, error) {
+	if bits < 16 || bits%8 != 0 {
+		return nil, errors.New("generatePrime | unsupported prime size")
+	}
+	candidate := make([]byte, bits/8)
+	p := new(big.Int)
+	for {
+		if _, err := io.ReadFull(entropy, candidate); err != nil {
+			return nil, fmt.Errorf("generatePrime | %w", err)
+		}
+		candidate[0] |= 0xc0
+		candidate[len(candidate)-1] |= 1
+		// ProbablyPrime is deterministic for a given candidate
+		if p.SetBytes(candidate).ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+// deriveECDSAKey derives the private scalar from entropy with extra random bits (FIPS 186-4 B.4.1):
+// d = (c mod (n-1)) + 1 for a c with 64 bits more than the group order n. It is only used for
+// DeterministicEntropy, see ECCGenerator.
+func deriveECDSAKey(entropy io.Reader, curve elliptic.Curve, ecdhCurve ecdh.Curve) (*ecdsa.PrivateKey, error) {
+	params := curve.Params()
+	size := (params.N.BitLen() + 7) / 8
+	c := make([]byte, size+8)
+	if _, err := io.ReadFull(entropy, c); err != nil {
+		return nil, fmt.Errorf("deriveECDSAKey | %w", err)
+	}
+	nMinusOne := new(big.Int).Sub(params.N, big.NewInt(1))
+	d := new(big.Int).SetBytes(c)
+	d.Mod(d, nMinusOne).Add(d, big.NewInt(1))
+
+	// the public point is computed by crypto/ecdh, the elliptic arithmetic functions are deprecated
+	private, err := ecdhCurve.NewPrivateKey(d.FillBytes(make([]byte, size)))
+	if err != nil {
+		return nil, fmt.Errorf("deriveECDSAKey | %w", err)
+	}
+	point := private.PublicKey().Bytes()
+	coordinateSize := (params.BitSize + 7) / 8
+	if len(point) != 1+2*coordinateSize || point[0] != 4 {
+		return nil, errors.New("deriveECDSAKey | unexpected public key encoding")
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(point[1 : 1+coordinateSize]),
+			Y:     new(big.Int).SetBytes(point[1+coordinateSize:]),
+		},
+		D: d,
+	}, nil
+}
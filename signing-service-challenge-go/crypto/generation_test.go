@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSAGenerator(t *testing.T) {
+	g := RSAGenerator{Entropy: NewDeterministicEntropy([]byte("seed"))}
+	key, err := g.Generate()
+	require.Nil(t, err)
+	assert.Equal(t, DefaultRSAKeySize, key.Public.N.BitLen())
+	assert.Nil(t, key.Private.Validate())
+
+	again := RSAGenerator{Entropy: NewDeterministicEntropy([]byte("seed"))}
+	sameKey, err := again.Generate()
+	require.Nil(t, err)
+	assert.True(t, key.Private.Equal(sameKey.Private), "same entropy, same key")
+
+	system := RSAGenerator{}
+	otherKey, err := system.Generate()
+	require.Nil(t, err)
+	assert.False(t, key.Private.Equal(otherKey.Private))
+}
+
+func TestECCGenerator(t *testing.T) {
+	g := ECCGenerator{Entropy: NewDeterministicEntropy([]byte("seed"))}
+	key, err := g.Generate()
+	require.Nil(t, err)
+	assert.True(t, key.Public.Curve.IsOnCurve(key.Public.X, key.Public.Y))
+
+	again := ECCGenerator{Entropy: NewDeterministicEntropy([]byte("seed"))}
+	sameKey, err := again.Generate()
+	require.Nil(t, err)
+	assert.True(t, key.Private.Equal(sameKey.Private), "same entropy, same key")
+
+	// the derived key has to be usable by the standard library
+	signer := ECDSASigner{key: key}
+	signature, err := signer.Sign([]byte("data"))
+	require.Nil(t, err)
+	assert.True(t, signer.Verify([]byte("data"), signature))
+
+	system := ECCGenerator{}
+	otherKey, err := system.Generate()
+	require.Nil(t, err)
+	assert.False(t, key.Private.Equal(otherKey.Private))
+}
+
+func TestKeySize(t *testing.T) {
+	t.Run("rsa", func(t *testing.T) {
+		key, err := (&RSAGenerator{Bits: 1024}).Generate()
+		require.Nil(t, err)
+		assert.Equal(t, 1024, key.Public.N.BitLen())
+
+		_, err = (&RSAGenerator{Bits: 1000}).Generate()
+		assert.NotNil(t, err)
+	})
+	t.Run("ecdsa", func(t *testing.T) {
+		for bits, curve := range map[int]string{0: "P-384", 256: "P-256", 384: "P-384", 521: "P-521"} {
+			key, err := (&ECCGenerator{Bits: bits}).Generate()
+			require.Nil(t, err)
+			assert.Equal(t, curve, key.Public.Curve.Params().Name)
+		}
+		_, err := (&ECCGenerator{Bits: 512}).Generate()
+		assert.NotNil(t, err)
+	})
+	t.Run("validate", func(t *testing.T) {
+		for _, tt := range []struct {
+			algorithm SignatureAlgorithm
+			bits      int
+			valid     bool
+		}{
+			{SignatureRSA, 512, true},
+			{SignatureRSA, 4096, true},
+			{SignatureRSA, 256, false},
+			{SignatureRSA, 520, false},
+			{SignatureRSA, 16384, false},
+			{SignautreECDSA, 256, true},
+			{SignautreECDSA, 521, true},
+			{SignautreECDSA, 2048, false},
+			{"AES", 256, false},
+		} {
+			err := ValidateKeySize(tt.algorithm, tt.bits)
+			assert.Equal(t, tt.valid, err == nil, "%s %d: %v", tt.algorithm, tt.bits, err)
+		}
+	})
+	t.Run("signer", func(t *testing.T) {
+		signer, err := NewSignerWithKeySize(SignautreECDSA, 256, nil)
+		require.Nil(t, err)
+		signature, err := signer.Sign([]byte("data"))
+		require.Nil(t, err)
+		assert.True(t, signer.Verify([]byte("data"), signature))
+
+		publicKey, err := signer.PublicKey()
+		require.Nil(t, err)
+		verifier, algorithm, err := NewVerifier(publicKey)
+		require.Nil(t, err)
+		assert.Equal(t, SignautreECDSA, algorithm)
+		assert.True(t, verifier.Verify([]byte("data"), signature))
+	})
+}
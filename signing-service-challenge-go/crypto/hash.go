@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto"
+	_ "crypto/sha256" // register SHA-256 for hashData
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for hashData
+	"fmt"
+)
+
+// HashAlgorithm identifies the hash function a client used to create a digest.
+type HashAlgorithm string
+
+const (
+	HashSHA256 HashAlgorithm = "SHA-256"
+	HashSHA384 HashAlgorithm = "SHA-384"
+	HashSHA512 HashAlgorithm = "SHA-512"
+)
+
+// DigestSize returns the length in bytes of digests produced by the hash algorithm.
+func (h HashAlgorithm) DigestSize() (int, error) {
+	switch h {
+	case HashSHA256:
+		return 32, nil
+	case HashSHA384:
+		return 48, nil
+	case HashSHA512:
+		return 64, nil
+	}
+	return 0, fmt.Errorf("unsupported hash algorithm: %s", h)
+}
+
+// ValidateDigest checks that the hash algorithm is supported and the digest has its length.
+func ValidateDigest(hashAlgorithm HashAlgorithm, digest []byte) error {
+	size, err := hashAlgorithm.DigestSize()
+	if err != nil {
+		return fmt.Errorf("ValidateDigest | %w", err)
+	}
+	if len(digest) != size {
+		return fmt.Errorf("ValidateDigest | %s digest has to be %d bytes, got %d", hashAlgorithm, size, len(digest))
+	}
+	return nil
+}
+
+// hashData returns the digest of data with the hash function.
+func hashData(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,30 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDigest(t *testing.T) {
+	sha256Digest := sha256.Sum256([]byte("data"))
+	sha384Digest := sha512.Sum384([]byte("data"))
+	sha512Digest := sha512.Sum512([]byte("data"))
+
+	t.Run("valid", func(t *testing.T) {
+		assert.Nil(t, ValidateDigest(HashSHA256, sha256Digest[:]))
+		assert.Nil(t, ValidateDigest(HashSHA384, sha384Digest[:]))
+		assert.Nil(t, ValidateDigest(HashSHA512, sha512Digest[:]))
+	})
+	t.Run("length mismatch", func(t *testing.T) {
+		assert.NotNil(t, ValidateDigest(HashSHA256, sha512Digest[:]))
+		assert.NotNil(t, ValidateDigest(HashSHA512, sha256Digest[:]))
+		assert.NotNil(t, ValidateDigest(HashSHA256, nil))
+	})
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		assert.NotNil(t, ValidateDigest("MD5", sha256Digest[:16]))
+		assert.NotNil(t, ValidateDigest("", sha256Digest[:]))
+	})
+}
@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// signRFC6979 signs the digest (hashed with hash) with a nonce derived from the key and the digest as specified by
+// RFC 6979 section 3.2, so the signature is the same for the same key and digest. It returns an ASN.1 signature.
+func signRFC6979(key *ecdsa.PrivateKey, hash crypto.Hash, digest []byte) ([]byte, error) {
+	curve, err := ecdhCurve(key.Curve)
+	if err != nil {
+		return nil, fmt.Errorf("signRFC6979 | %w", err)
+	}
+	n := key.Curve.Params().N
+	e := bitsToInt(digest, n)
+
+	nonces := newNonceGenerator(key.D, n, hash, digest)
+	for {
+		k := nonces.next()
+
+		// r = x(k*G) mod n
+		point, err := curve.NewPrivateKey(k.FillBytes(make([]byte, (n.BitLen()+7)/8)))
+		if err != nil {
+			return nil, fmt.Errorf("signRFC6979 | %w", err)
+		}
+		coordinates := point.PublicKey().Bytes()[1:]
+		r := new(big.Int).SetBytes(coordinates[:len(coordinates)/2])
+		r.Mod(r, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k^-1 * (e + r*d) mod n
+		s := new(big.Int).Mul(r, key.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			return nil, fmt.Errorf("signRFC6979 | %w", err)
+		}
+		return signature, nil
+	}
+}
+
+// ecdhCurve returns the crypto/ecdh curve used for the scalar multiplication on curve.
+func ecdhCurve(curve elliptic.Curve) (ecdh.Curve, error) {
+	switch curve.Params().Name {
+	case "P-256":
+		return ecdh.P256(), nil
+	case "P-384":
+		return ecdh.P384(), nil
+	case "P-521":
+		return ecdh.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported curve %s", curve.Params().Name)
+}
+
+// nonceGenerator is the HMAC_DRBG of RFC 6979 section 3.2 that yields the candidates for the nonce k.
+type nonceGenerator struct {
+	hash crypto.Hash
+	n    *big.Int
+	k, v []byte
+}
+
+// newNonceGenerator seeds the generator with the private key x and the message digest (steps a to g).
+func newNonceGenerator(x, n *big.Int, hash crypto.Hash, digest []byte) *nonceGenerator {
+	size := (n.BitLen() + 7) / 8
+	seed := append(x.FillBytes(make([]byte, size)), bitsToOctets(digest, n)...)
+
+	g := &nonceGenerator{
+		hash: hash,
+		n:    n,
+		k:    make([]byte, hash.Size()),
+		v:    make([]byte, hash.Size()),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = g.mac(g.k, g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+// next returns the next nonce candidate in [1, n-1] (step h). The state is updated after every candidate, so
+// another call yields the candidate that follows if the nonce turned out to be unusable.
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		var t []byte
+		for len(t)*8 < g.n.BitLen() {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+		k := bitsToInt(t, g.n)
+
+		g.k = g.mac(g.k, g.v, []byte{0x00})
+		g.v = g.mac(g.k, g.v)
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+// mac returns the HMAC of the concatenated data under key.
+func (g *nonceGenerator) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(g.hash.New, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// bitsToInt interprets the leftmost bits of b, up to the bit length of n, as an integer (RFC 6979 section 2.3.2).
+func bitsToInt(b []byte, n *big.Int) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - n.BitLen(); excess > 0 {
+		i.Rsh(i, uint(excess))
+	}
+	return i
+}
+
+// bitsToOctets reduces b modulo n and encodes it with the byte length of n (RFC 6979 section 2.3.4).
+func bitsToOctets(b []byte, n *big.Int) []byte {
+	i := bitsToInt(b, n)
+	if i.Cmp(n) >= 0 {
+		i.Sub(i, n)
+	}
+	return i.FillBytes(make([]byte, (n.BitLen()+7)/8))
+}
@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6979Vector is a test vector of RFC 6979 appendix A.2 with SHA-256.
+type rfc6979Vector struct {
+	name    string
+	curve   elliptic.Curve
+	x       string
+	ux, uy  string
+	message string
+	r, s    string
+}
+
+var rfc6979Vectors = []rfc6979Vector{
+	{
+		name:    "P-256 sample",
+		curve:   elliptic.P256(),
+		x:       "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+		ux:      "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6",
+		uy:      "7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299",
+		message: "sample",
+		r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+		s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+	},
+	{
+		name:    "P-256 test",
+		curve:   elliptic.P256(),
+		x:       "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+		ux:      "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6",
+		uy:      "7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299",
+		message: "test",
+		r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+		s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+	},
+	{
+		name:    "P-384 sample",
+		curve:   elliptic.P384(),
+		x:       "6B9D3DAD2E1B8C1C05B19875B6659F4DE23C3B667BF297BA9AA47740787137D896D5724E4C70A825F872C9EA60D2EDF5",
+		ux:      "EC3A4E415B4E19A4568618029F427FA5DA9A8BC4AE92E02E06AAE5286B300C64DEF8F0EA9055866064A254515480BC13",
+		uy:      "8015D9B72D7D57244EA8EF9AC0C621896708A59367F9DFB9F54CA84B3F1C9DB1288B231C3AE0D4FE7344FD2533264720",
+		message: "sample",
+		r:       "21B13D1E013C7FA1392D03C5F99AF8B30C570C6F98D4EA8E354B63A21D3DAA33BDE1E888E63355D92FA2B3C36D8FB2CD",
+		s:       "F3AA443FB107745BF4BD77CB3891674632068A10CA67E3D45DB2266FA7D1FEEBEFDC63ECCD1AC42EC0CB8668A4FA0AB0",
+	},
+	{
+		name:    "P-384 test",
+		curve:   elliptic.P384(),
+		x:       "6B9D3DAD2E1B8C1C05B19875B6659F4DE23C3B667BF297BA9AA47740787137D896D5724E4C70A825F872C9EA60D2EDF5",
+		ux:      "EC3A4E415B4E19A4568618029F427FA5DA9A8BC4AE92E02E06AAE5286B300C64DEF8F0EA9055866064A254515480BC13",
+		uy:      "8015D9B72D7D57244EA8EF9AC0C621896708A59367F9DFB9F54CA84B3F1C9DB1288B231C3AE0D4FE7344FD2533264720",
+		message: "test",
+		r:       "6D6DEFAC9AB64DABAFE36C6BF510352A4CC27001263638E5B16D9BB51D451559F918EEDAF2293BE5B475CC8F0188636B",
+		s:       "2D46F3BECBCC523D5F1A1256BF0C9B024D879BA9E838144C8BA6BAEB4B53B47D51AB373F9845C0514EEFB14024787265",
+	},
+	{
+		// not from the RFC: this message makes the first nonce candidate exceed the order, so k is drawn again
+		name:    "P-256 retry",
+		curve:   elliptic.P256(),
+		x:       "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721",
+		ux:      "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6",
+		uy:      "7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299",
+		message: "wv[vnX",
+		r:       "EFD9073B652E76DA1B5A019C0E4A2E3FA529B035A6ABB91EF67F0ED7A1F21234",
+		s:       "3DB4706C9D9F4A4FE13BB5E08EF0FAB53A57DBAB2061C83A35FA411C68D2BA33",
+	},
+}
+
+func fromHex(t *testing.T, s string) *big.Int {
+	t.Helper()
+	i, ok := new(big.Int).SetString(s, 16)
+	require.True(t, ok, s)
+	return i
+}
+
+func TestSignRFC6979(t *testing.T) {
+	for _, v := range rfc6979Vectors {
+		t.Run(v.name, func(t *testing.T) {
+			key := &ecdsa.PrivateKey{
+				PublicKey: ecdsa.PublicKey{Curve: v.curve, X: fromHex(t, v.ux), Y: fromHex(t, v.uy)},
+				D:         fromHex(t, v.x),
+			}
+			digest := sha256.Sum256([]byte(v.message))
+
+			signature, err := signRFC6979(key, crypto.SHA256, digest[:])
+			require.Nil(t, err)
+
+			var rs struct{ R, S *big.Int }
+			_, err = asn1.Unmarshal(signature, &rs)
+			require.Nil(t, err)
+			assert.Equal(t, fromHex(t, v.r), rs.R)
+			assert.Equal(t, fromHex(t, v.s), rs.S)
+			assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+		})
+	}
+	t.Run("unsupported curve", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P224(), SystemEntropy())
+		require.Nil(t, err)
+		digest := sha256.Sum256([]byte("sample"))
+
+		_, err = signRFC6979(key, crypto.SHA256, digest[:])
+		assert.NotNil(t, err)
+	})
+}
@@ -2,7 +2,6 @@ package crypto
 
 import (
 	"crypto"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"fmt"
@@ -15,7 +14,17 @@ type RSASigner struct {
 
 // NewRSASigner gnereates an RSASigner with a key pair
 func NewRSASigner() (RSASigner, error) {
-	g := RSAGenerator{}
+	return NewRSASignerWithEntropy(SystemEntropy())
+}
+
+// NewRSASignerWithEntropy generates an RSASigner with a key pair derived from entropy.
+func NewRSASignerWithEntropy(entropy Entropy) (RSASigner, error) {
+	return newRSASigner(entropy, 0)
+}
+
+// newRSASigner generates an RSASigner with a key of the size in bits, the default size if zero.
+func newRSASigner(entropy Entropy, bits int) (RSASigner, error) {
+	g := RSAGenerator{Entropy: entropy, Bits: bits}
 	key, err := g.Generate()
 	if err != nil {
 		return RSASigner{}, fmt.Errorf("NewRSASigner | %w", err)
@@ -25,10 +34,11 @@ func NewRSASigner() (RSASigner, error) {
 	}, nil
 }
 
-// Sign produces a digital signature for the provided payload with RSA PKCS1v15
+// Sign produces a digital signature for the provided payload with RSA PKCS1v15.
+// PKCS1v15 signatures are deterministic and need no randomness.
 func (s RSASigner) Sign(dataTobeSigned []byte) ([]byte, error) {
 	hash := sha256.Sum256(dataTobeSigned)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key.Private, crypto.SHA256, hash[:])
+	signature, err := rsa.SignPKCS1v15(nil, s.key.Private, crypto.SHA256, hash[:])
 	if err != nil {
 		return nil, fmt.Errorf("RSASigner.SignPKCS1v15 | %w", err)
 	}
@@ -36,7 +46,15 @@ func (s RSASigner) Sign(dataTobeSigned []byte) ([]byte, error) {
 }
 
 func (s RSASigner) Verify(dataToBeSigned []byte, signature []byte) bool {
-	hash := sha256.Sum256(dataToBeSigned)
-	err := rsa.VerifyPKCS1v15(s.key.Public, crypto.SHA256, hash[:], signature)
-	return err == nil
+	return RSAVerifier{key: s.key.Public}.Verify(dataToBeSigned, signature)
+}
+
+// PublicKey returns the PEM encoded public key of the signer
+func (s RSASigner) PublicKey() ([]byte, error) {
+	m := NewRSAMarshaler()
+	publicKey, _, err := m.Marshal(*s.key)
+	if err != nil {
+		return nil, fmt.Errorf("RSASigner.PublicKey | %w", err)
+	}
+	return publicKey, nil
 }
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"encoding/pem"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,3 +45,15 @@ func TestRSASign(t *testing.T) {
 		assert.True(t, verified)
 	})
 }
+
+func TestRSAPublicKey(t *testing.T) {
+	signer, err := NewRSASigner()
+	assert.Nil(t, err)
+
+	publicKey, err := signer.PublicKey()
+	assert.Nil(t, err)
+
+	block, _ := pem.Decode(publicKey)
+	assert.NotNil(t, block)
+	assert.Equal(t, "RSA_PUBLIC_KEY", block.Type)
+}
@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSAMarshaler(t *testing.T) {
+	m := NewRSAMarshaler()
+	t.Run("round trip", func(t *testing.T) {
+		keyPair, err := (&RSAGenerator{}).Generate()
+		require.Nil(t, err)
+
+		_, private, err := m.Marshal(*keyPair)
+		require.Nil(t, err)
+		decoded, err := m.Unmarshal(private)
+		require.Nil(t, err)
+		assert.True(t, keyPair.Private.Equal(decoded.Private))
+		assert.True(t, keyPair.Public.Equal(decoded.Public))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, input := range []string{"", "no pem", "-----BEGIN RSA_PRIVATE_KEY-----\nAAAA\n-----END RSA_PRIVATE_KEY-----\n"} {
+			keyPair, err := m.Unmarshal([]byte(input))
+			assert.NotNil(t, err, input)
+			assert.Nil(t, keyPair, input)
+		}
+	})
+}
+
+func FuzzRSAMarshalerUnmarshal(f *testing.F) {
+	m := NewRSAMarshaler()
+	keyPair, err := (&RSAGenerator{}).Generate()
+	require.Nil(f, err)
+	public, private, err := m.Marshal(*keyPair)
+	require.Nil(f, err)
+	f.Add(private)
+	f.Add(public)
+	f.Add([]byte("-----BEGIN RSA_PRIVATE_KEY-----\n\n-----END RSA_PRIVATE_KEY-----\n"))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		m := NewRSAMarshaler()
+		decoded, err := m.Unmarshal(input)
+		if err != nil {
+			assert.Nil(t, decoded)
+			return
+		}
+		// whatever is accepted survives a round trip
+		_, encoded, err := m.Marshal(*decoded)
+		require.Nil(t, err)
+		again, err := m.Unmarshal(encoded)
+		require.Nil(t, err)
+		assert.True(t, decoded.Private.Equal(again.Private))
+	})
+}
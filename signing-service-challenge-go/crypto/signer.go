@@ -25,15 +25,40 @@ func IsSupportedAlgorithm(algorithm string) bool {
 type Signer interface {
 	Sign(dataToBeSigned []byte) ([]byte, error)
 	Verify(dataToBeSigned []byte, signature []byte) bool
+	// PublicKey returns the PEM encoded public key to verify signatures.
+	PublicKey() ([]byte, error)
+}
+
+// Deterministic reports whether the signer produces the same signature for the same payload. RSA signatures
+// (PKCS #1 v1.5) always are, ECDSA signatures only with deterministic nonces.
+func Deterministic(signer Signer) bool {
+	switch s := signer.(type) {
+	case RSASigner:
+		return true
+	case ECDSASigner:
+		return s.Deterministic()
+	}
+	return false
 }
 
 // NewSigner returns an implementation of Signer based on the provided algorithm
 func NewSigner(algorithm SignatureAlgorithm) (Signer, error) {
+	return NewSignerWithEntropy(algorithm, SystemEntropy())
+}
+
+// NewSignerWithEntropy works like NewSigner, but draws all randomness from entropy.
+func NewSignerWithEntropy(algorithm SignatureAlgorithm, entropy Entropy) (Signer, error) {
+	return NewSignerWithKeySize(algorithm, 0, entropy)
+}
+
+// NewSignerWithKeySize works like NewSignerWithEntropy with a key of the size in bits, see ValidateKeySize. Zero
+// selects the default size of the algorithm.
+func NewSignerWithKeySize(algorithm SignatureAlgorithm, bits int, entropy Entropy) (Signer, error) {
 	switch algorithm {
 	case SignatureRSA:
-		return NewRSASigner()
+		return newRSASigner(entropy, bits)
 	case SignautreECDSA:
-		return NewECDSASigner()
+		return newECDSASigner(entropy, bits)
 	}
 	return nil, fmt.Errorf("invalid signature algorithm provided")
 }
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// Verifier checks signatures with a public key only.
+type Verifier interface {
+	Verify(dataToBeSigned []byte, signature []byte) bool
+}
+
+// ECDSAVerifier verifies signatures created by an ECDSASigner
+type ECDSAVerifier struct {
+	key *ecdsa.PublicKey
+}
+
+// Verify checks the ASN.1 encoded ECDSA signature of the SHA-256 hash of dataToBeSigned
+func (v ECDSAVerifier) Verify(dataToBeSigned []byte, signature []byte) bool {
+	hash := sha256.Sum256(dataToBeSigned)
+	return ecdsa.VerifyASN1(v.key, hash[:], signature)
+}
+
+// RSAVerifier verifies signatures created by an RSASigner
+type RSAVerifier struct {
+	key *rsa.PublicKey
+}
+
+// Verify checks the RSA PKCS1v15 signature of the SHA-256 hash of dataToBeSigned
+func (v RSAVerifier) Verify(dataToBeSigned []byte, signature []byte) bool {
+	hash := sha256.Sum256(dataToBeSigned)
+	return rsa.VerifyPKCS1v15(v.key, crypto.SHA256, hash[:], signature) == nil
+}
+
+// NewVerifier creates a Verifier from a PEM encoded public key as returned by Signer.PublicKey and reports the
+// signature algorithm of the key.
+func NewVerifier(publicKey []byte) (Verifier, SignatureAlgorithm, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, "", fmt.Errorf("NewVerifier | no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA_PUBLIC_KEY", "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, "", fmt.Errorf("NewVerifier | %w", err)
+		}
+		return RSAVerifier{key: key}, SignatureRSA, nil
+	case "PUBLIC_KEY", "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, "", fmt.Errorf("NewVerifier | %w", err)
+		}
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			return ECDSAVerifier{key: key}, SignautreECDSA, nil
+		case *rsa.PublicKey:
+			return RSAVerifier{key: key}, SignatureRSA, nil
+		}
+		return nil, "", fmt.Errorf("NewVerifier | unsupported key type: %T", key)
+	}
+	return nil, "", fmt.Errorf("NewVerifier | unsupported PEM block: %s", block.Type)
+}
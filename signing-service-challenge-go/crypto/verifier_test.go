@@ -0,0 +1,58 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVerifier(t *testing.T) {
+	for _, algorithm := range []SignatureAlgorithm{SignatureRSA, SignautreECDSA} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			signer, err := NewSigner(algorithm)
+			require.Nil(t, err)
+			publicKey, err := signer.PublicKey()
+			require.Nil(t, err)
+			payload := []byte("toBeSigned")
+			signature, err := signer.Sign(payload)
+			require.Nil(t, err)
+
+			verifier, keyAlgorithm, err := NewVerifier(publicKey)
+			require.Nil(t, err)
+			assert.Equal(t, algorithm, keyAlgorithm)
+			assert.True(t, verifier.Verify(payload, signature))
+			assert.False(t, verifier.Verify([]byte("other"), signature))
+		})
+	}
+	t.Run("no pem", func(t *testing.T) {
+		_, _, err := NewVerifier([]byte("no key"))
+		assert.NotNil(t, err)
+	})
+	t.Run("unsupported block", func(t *testing.T) {
+		_, _, err := NewVerifier([]byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"))
+		assert.NotNil(t, err)
+	})
+}
+
+func FuzzNewVerifier(f *testing.F) {
+	for _, algorithm := range []SignatureAlgorithm{SignatureRSA, SignautreECDSA} {
+		signer, err := NewSigner(algorithm)
+		require.Nil(f, err)
+		publicKey, err := signer.PublicKey()
+		require.Nil(f, err)
+		f.Add(publicKey, []byte("signature"))
+	}
+	f.Add([]byte("-----BEGIN PUBLIC_KEY-----\n\n-----END PUBLIC_KEY-----\n"), []byte{})
+
+	f.Fuzz(func(t *testing.T, publicKey []byte, signature []byte) {
+		verifier, algorithm, err := NewVerifier(publicKey)
+		if err != nil {
+			assert.Nil(t, verifier)
+			return
+		}
+		assert.True(t, IsSupportedAlgorithm(string(algorithm)))
+		// arbitrary signatures are rejected without panicking
+		verifier.Verify([]byte("data"), signature)
+	})
+}
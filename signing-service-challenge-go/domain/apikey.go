@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope is a permission granted to an API key.
+type Scope string
+
+const (
+	// ScopeDevicesCreate allows creating devices.
+	ScopeDevicesCreate Scope = "devices:create"
+	// ScopeDevicesRead allows reading devices, their clients, transactions and signature exports.
+	ScopeDevicesRead Scope = "devices:read"
+	// ScopeDevicesWrite allows changing and decommissioning devices and registering their clients.
+	ScopeDevicesWrite Scope = "devices:write"
+	// ScopeSign allows creating signatures, transactions and timestamps.
+	ScopeSign Scope = "sign"
+	// ScopeAdmin allows everything, including issuing and revoking API keys.
+	ScopeAdmin Scope = "admin"
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognize.
+const apiKeyPrefix = "sk_"
+
+var (
+	// ErrInvalidScope is returned for API keys requesting unknown scopes or none.
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidAPIKey is returned for API keys that cannot be issued as requested.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// IsSupportedScope checks whether API keys can be granted the scope.
+func IsSupportedScope(scope string) bool {
+	switch Scope(scope) {
+	case ScopeDevicesCreate, ScopeDevicesRead, ScopeDevicesWrite, ScopeSign, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIKey authorizes requests with the key sent by the caller. Only the SHA-256 hash of the key is kept; the key
+// itself is returned once when it is issued.
+type APIKey struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	// DeviceIDs restricts the key to the devices. Empty allows all devices.
+	DeviceIDs []uuid.UUID `json:"device_ids,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	RevokedAt *time.Time  `json:"revoked_at,omitempty"`
+	Hash      string      `json:"-"`
+}
+
+// NewAPIKey issues a key with the scopes, restricted to the devices if any are given. It returns the key to be
+// handed to the caller, which cannot be recovered from the APIKey.
+func NewAPIKey(name string, scopes []Scope, deviceIDs []uuid.UUID, now time.Time) (APIKey, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return APIKey{}, "", fmt.Errorf("NewAPIKey | name missing | %w", ErrInvalidAPIKey)
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", fmt.Errorf("NewAPIKey | %w", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !IsSupportedScope(string(scope)) {
+			return APIKey{}, "", fmt.Errorf("NewAPIKey | %q | %w", scope, ErrInvalidScope)
+		}
+	}
+	for _, id := range deviceIDs {
+		if id == uuid.Nil {
+			return APIKey{}, "", fmt.Errorf("NewAPIKey | invalid device id | %w", ErrInvalidAPIKey)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return APIKey{}, "", fmt.Errorf("NewAPIKey | %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return APIKey{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Scopes:    scopes,
+		DeviceIDs: deviceIDs,
+		CreatedAt: now,
+		Hash:      HashAPIKey(key),
+	}, key, nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. Keys are random with 256 bits, so a single
+// unsalted SHA-256 round is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Revoked reports whether the key has been revoked.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Permits reports whether the key has been granted the scope. The admin scope permits everything.
+func (k APIKey) Permits(scope Scope) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsDevice reports whether the key may be used for the device.
+func (k APIKey) AllowsDevice(id uuid.UUID) bool {
+	if len(k.DeviceIDs) == 0 {
+		return true
+	}
+	for _, allowed := range k.DeviceIDs {
+		if allowed == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	now := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	t.Run("issue", func(t *testing.T) {
+		key, secret, err := NewAPIKey("pos", []Scope{ScopeSign}, nil, now)
+		require.Nil(t, err)
+		assert.True(t, strings.HasPrefix(secret, apiKeyPrefix))
+		assert.Equal(t, HashAPIKey(secret), key.Hash)
+		assert.NotContains(t, key.Hash, secret)
+		assert.Equal(t, now, key.CreatedAt)
+		assert.False(t, key.Revoked())
+
+		other, otherSecret, err := NewAPIKey("pos", []Scope{ScopeSign}, nil, now)
+		require.Nil(t, err)
+		assert.NotEqual(t, secret, otherSecret)
+		assert.NotEqual(t, key.ID, other.ID)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := NewAPIKey("", []Scope{ScopeSign}, nil, now)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		_, _, err = NewAPIKey("pos", nil, nil, now)
+		assert.ErrorIs(t, err, ErrInvalidScope)
+		_, _, err = NewAPIKey("pos", []Scope{"devices:delete"}, nil, now)
+		assert.ErrorIs(t, err, ErrInvalidScope)
+		_, _, err = NewAPIKey("pos", []Scope{ScopeSign}, []uuid.UUID{uuid.Nil}, now)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+}
+
+func TestAPIKeyPermissions(t *testing.T) {
+	t.Run("scopes", func(t *testing.T) {
+		key := APIKey{Scopes: []Scope{ScopeDevicesRead, ScopeSign}}
+		assert.True(t, key.Permits(ScopeSign))
+		assert.True(t, key.Permits(ScopeDevicesRead))
+		assert.False(t, key.Permits(ScopeDevicesCreate))
+		assert.False(t, key.Permits(ScopeAdmin))
+
+		admin := APIKey{Scopes: []Scope{ScopeAdmin}}
+		assert.True(t, admin.Permits(ScopeDevicesCreate), "admin permits everything")
+	})
+	t.Run("devices", func(t *testing.T) {
+		allowed := uuid.New()
+		key := APIKey{DeviceIDs: []uuid.UUID{allowed}}
+		assert.True(t, key.AllowsDevice(allowed))
+		assert.False(t, key.AllowsDevice(uuid.New()))
+		assert.True(t, APIKey{}.AllowsDevice(uuid.New()), "unrestricted keys allow all devices")
+	})
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var (
+	// ErrInvalidClientID is returned for client IDs that do not match clientIDPattern.
+	ErrInvalidClientID = errors.New("invalid client id")
+	// ErrClientNotRegistered is returned when an unregistered client tries to use a device.
+	ErrClientNotRegistered = errors.New("client not registered")
+	// ErrClientsUnsupported is returned when clients are registered to a device whose secured data format does
+	// not record the client ID.
+	ErrClientsUnsupported = errors.New("secured data format does not record clients")
+)
+
+// clientIDPattern restricts client IDs to characters that are safe in URL paths and cannot be confused with the
+// separators of the secured data.
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:-]{0,63}$`)
+
+// ValidateClientID checks whether the client ID can be registered.
+func ValidateClientID(clientID string) error {
+	if !clientIDPattern.MatchString(clientID) {
+		return fmt.Errorf("ValidateClientID | %q | %w", clientID, ErrInvalidClientID)
+	}
+	return nil
+}
+
+// RegisterClient allows the client (e.g. a cash register) to sign with the device. Registering a client twice
+// has no effect. Only devices whose secured data format records the client ID accept clients, otherwise the
+// client of a signature could be changed in the ledger without invalidating it.
+func (sd *SignatureDevice) RegisterClient(clientID string) error {
+	if err := ValidateClientID(clientID); err != nil {
+		return fmt.Errorf("SignatureDevice RegisterClient | id: %s | %w", sd.ID, err)
+	}
+	if !sd.format.RecordsMetadata() {
+		return fmt.Errorf("SignatureDevice RegisterClient | id: %s | format: %s | %w", sd.ID, sd.format, ErrClientsUnsupported)
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if sd.clients == nil {
+		sd.clients = map[string]struct{}{}
+	}
+	sd.clients[clientID] = struct{}{}
+	return nil
+}
+
+// DeregisterClient revokes the permission of the client to sign with the device.
+func (sd *SignatureDevice) DeregisterClient(clientID string) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if _, ok := sd.clients[clientID]; !ok {
+		return fmt.Errorf("SignatureDevice DeregisterClient | id: %s | client: %s | %w", sd.ID, clientID, ErrClientNotRegistered)
+	}
+	delete(sd.clients, clientID)
+	return nil
+}
+
+// Clients returns the sorted IDs of all registered clients.
+func (sd *SignatureDevice) Clients() []string {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	clients := make([]string, 0, len(sd.clients))
+	for clientID := range sd.clients {
+		clients = append(clients, clientID)
+	}
+	sort.Strings(clients)
+	return clients
+}
+
+// authorize checks that the device is in use, supports the signing mode and the client is registered. Devices
+// without registered clients also sign without client ID, as described in the README. The caller has to hold sd.mu.
+func (sd *SignatureDevice) authorize(clientID string, mode SigningMode) error {
+	if sd.decommissioned {
+		return fmt.Errorf("SignatureDevice | id: %s | %w", sd.ID, ErrDeviceDecommissioned)
+	}
+	if err := sd.SupportsMode(mode); err != nil {
+		return err
+	}
+	if clientID == "" && len(sd.clients) == 0 {
+		return nil
+	}
+	if _, ok := sd.clients[clientID]; !ok {
+		return fmt.Errorf("SignatureDevice | id: %s | client: %q | %w", sd.ID, clientID, ErrClientNotRegistered)
+	}
+	return nil
+}
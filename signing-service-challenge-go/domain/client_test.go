@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateClientID(t *testing.T) {
+	testData := map[string]bool{
+		"register-1":             true,
+		"pos.store-42:01":        true,
+		"A":                      true,
+		"":                       false,
+		"register_1":             false,
+		"-register":              false,
+		"register 1":             false,
+		string(make([]byte, 65)): false,
+	}
+	for clientID, valid := range testData {
+		err := ValidateClientID(clientID)
+		assert.Equal(t, valid, err == nil, "client id: %q", clientID)
+	}
+}
+
+func TestClientRegistration(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+
+	t.Run("unregistered", func(t *testing.T) {
+		_, err := sd.Sign(context.Background(), testClientID, "data", nil)
+		assert.True(t, errors.Is(err, ErrClientNotRegistered))
+		_, err = sd.SignBatch(context.Background(), testClientID, []string{"data"}, nil)
+		assert.True(t, errors.Is(err, ErrClientNotRegistered))
+		_, _, err = sd.StartTransaction(context.Background(), testClientID, "data", nil)
+		assert.True(t, errors.Is(err, ErrClientNotRegistered))
+		assert.Equal(t, 0, sd.signatureCounter, "rejected signatures do not consume counters")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		err := sd.RegisterClient("register_1")
+		assert.True(t, errors.Is(err, ErrInvalidClientID))
+	})
+	t.Run("register", func(t *testing.T) {
+		require.Nil(t, sd.RegisterClient("register-2"))
+		require.Nil(t, sd.RegisterClient(testClientID))
+		require.Nil(t, sd.RegisterClient(testClientID), "registering twice has no effect")
+		assert.Equal(t, []string{testClientID, "register-2"}, sd.Clients())
+
+		signature, err := sd.Sign(context.Background(), testClientID, "data", nil)
+		require.Nil(t, err)
+		assert.Equal(t, testClientID, signature.ClientID)
+		parsed, err := ParseSecuredData(signature.Format, signature.SignedData)
+		require.Nil(t, err)
+		assert.Equal(t, testClientID, parsed.ClientID, "the client ID is signed")
+	})
+	t.Run("format without client id", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV0))
+		require.Nil(t, err)
+
+		err = sd.RegisterClient(testClientID)
+		assert.True(t, errors.Is(err, ErrClientsUnsupported))
+		assert.Empty(t, sd.Clients())
+	})
+	t.Run("deregister", func(t *testing.T) {
+		require.Nil(t, sd.DeregisterClient(testClientID))
+		assert.Equal(t, []string{"register-2"}, sd.Clients())
+
+		_, err := sd.Sign(context.Background(), testClientID, "data", nil)
+		assert.True(t, errors.Is(err, ErrClientNotRegistered))
+
+		err = sd.DeregisterClient(testClientID)
+		assert.True(t, errors.Is(err, ErrClientNotRegistered))
+	})
+	t.Run("without client id", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+
+		signature, err := sd.Sign(context.Background(), "", "data", nil)
+		require.Nil(t, err, "devices without registered clients sign without client id")
+		assert.Empty(t, signature.ClientID)
+
+		require.Nil(t, sd.RegisterClient(testClientID))
+		_, err = sd.Sign(context.Background(), "", "data", nil)
+		assert.True(t, errors.Is(err, ErrClientNotRegistered))
+	})
+}
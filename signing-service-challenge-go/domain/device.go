@@ -1,12 +1,16 @@
 package domain
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // SignatureDevice contains all relevant fields for creating signatures
@@ -19,18 +23,45 @@ type SignatureDevice struct {
 	signatureCounter int
 	mu               sync.Mutex
 	lastSignature    string
+
+	transactionCounter int
+	transactions       map[int]*Transaction
+	clients            map[string]struct{}
+	decommissioned     bool
+	timestamper        Timestamper
+	clock              clock.Clock
+	format             SecuredDataFormat
+	metrics            Metrics
+	owner              string
 }
 
 // NewSignatureDevice initializes a SignatureDevice with the provided data a generated key pair for the given signature algorithm
-func NewSignatureDevice(id uuid.UUID, label string, algorithm crypto.SignatureAlgorithm) (*SignatureDevice, error) {
+func NewSignatureDevice(id uuid.UUID, label string, algorithm crypto.SignatureAlgorithm, options ...Option) (*SignatureDevice, error) {
 	if id == uuid.Nil {
 		return nil, fmt.Errorf("NewSignatureDevice | invalid uuid")
 	}
+	opts := deviceOptions{
+		clock:   clock.System,
+		entropy: crypto.SystemEntropy(),
+		format:  DefaultSecuredDataFormat,
+		metrics: nopMetrics{},
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+	if !IsSupportedSecuredDataFormat(string(opts.format)) {
+		return nil, fmt.Errorf("NewSignatureDevice | %q | %w", opts.format, ErrUnsupportedFormat)
+	}
 
-	signer, err := crypto.NewSigner(algorithm)
+	start := time.Now()
+	signer, err := crypto.NewSignerWithKeySize(algorithm, opts.keySize, opts.entropy)
 	if err != nil {
 		return nil, fmt.Errorf("NewSigantureDevice | %w", err)
 	}
+	opts.metrics.ObserveKeyGeneration(algorithm, time.Since(start))
+	if ecdsaSigner, ok := signer.(crypto.ECDSASigner); ok && opts.deterministic {
+		signer = ecdsaSigner.WithDeterministicNonces()
+	}
 	// init lastSignautre for first signing
 
 	uid := []byte(id.String())
@@ -41,28 +72,204 @@ func NewSignatureDevice(id uuid.UUID, label string, algorithm crypto.SignatureAl
 		Label:     label,
 		Algorithm: algorithm,
 		signer:    signer,
+		clock:     opts.clock,
+		format:    opts.format,
+		metrics:   opts.metrics,
+		owner:     opts.owner,
 
 		lastSignature: lastSignature,
 	}, nil
 }
 
-// Sign creates a digital signature for the provided data. The provided dataToBeSigned will be prepended by the
-// signature counter and suffixed by the last signature, each divided witha '_' character
-func (sd *SignatureDevice) Sign(dataToBeSigned string) (string, string, error) {
-	sd.mu.Lock() // prevent sigCounter from being corrupted
+// Sign creates a digital signature for the provided data on behalf of a client, see authorize. The provided
+// dataToBeSigned will be encoded with the signature counter and the last signature in the format of the device; in
+// format v0 it is prepended by the signature counter and suffixed by the last signature, each divided witha '_'
+// character. Only format v1 records the client ID and the signing mode in the signed data.
+// The signature is passed to commit (if not nil) before the signature counter is incremented.
+func (sd *SignatureDevice) Sign(ctx context.Context, clientID string, dataToBeSigned string, commit CommitFunc) (Signature, error) {
+	return sd.signSingle(ctx, "SignatureDevice.Sign", clientID, SigningModeData, dataToBeSigned, commit)
+}
+
+// SignDigest signs content a client has hashed itself. The digest is validated against the hash algorithm and
+// takes the place of the data to be signed, see DigestToBeSigned, so the signature chain still covers counter and
+// last signature. The signature is created in SigningModeDigest, which needs a format that records it.
+func (sd *SignatureDevice) SignDigest(ctx context.Context, clientID string, hashAlgorithm crypto.HashAlgorithm, digest []byte, commit CommitFunc) (Signature, error) {
+	if err := crypto.ValidateDigest(hashAlgorithm, digest); err != nil {
+		return Signature{}, fmt.Errorf("SignatureDevice SignDigest | id: %s | err: %w", sd.ID, err)
+	}
+	return sd.signSingle(ctx, "SignatureDevice.SignDigest", clientID, SigningModeDigest, DigestToBeSigned(hashAlgorithm, digest), commit)
+}
+
+// signSingle signs and commits dataToBeSigned in the mode within a span of the name.
+func (sd *SignatureDevice) signSingle(ctx context.Context, name string, clientID string, mode SigningMode, dataToBeSigned string, commit CommitFunc) (_ Signature, err error) {
+	ctx, span := sd.startSpan(ctx, name)
+	defer endSpan(span, &err)
+
+	// prevent sigCounter from being corrupted
+	if err := sd.lockForSigning(ctx); err != nil {
+		return Signature{}, err
+	}
+	defer sd.mu.Unlock()
+
+	if err := sd.authorize(clientID, mode); err != nil {
+		return Signature{}, err
+	}
+	signature, err := sd.sign(ctx, clientID, mode, dataToBeSigned, sd.signatureCounter, sd.lastSignature)
+	if err != nil {
+		return Signature{}, err
+	}
+	if err := sd.commit(ctx, []Signature{signature}, commit); err != nil {
+		return Signature{}, err
+	}
+	// mux unlocks here
+	return signature, nil
+}
+
+// SignCOSE works like Sign and additionally returns the secured data as a COSE_Sign1 message signed with the
+// device key. The COSE signature is not part of the signature chain.
+func (sd *SignatureDevice) SignCOSE(ctx context.Context, clientID string, dataToBeSigned string, commit CommitFunc) (_ Signature, _ []byte, err error) {
+	ctx, span := sd.startSpan(ctx, "SignatureDevice.SignCOSE")
+	defer endSpan(span, &err)
+
+	if err := sd.lockForSigning(ctx); err != nil {
+		return Signature{}, nil, err
+	}
+	defer sd.mu.Unlock()
+
+	if err := sd.authorize(clientID, SigningModeData); err != nil {
+		return Signature{}, nil, err
+	}
+	signature, err := sd.sign(ctx, clientID, SigningModeData, dataToBeSigned, sd.signatureCounter, sd.lastSignature)
+	if err != nil {
+		return Signature{}, nil, err
+	}
+	coseSign1, err := crypto.SignCOSE1(sd.signer, []byte(signature.SignedData))
+	if err != nil {
+		return Signature{}, nil, fmt.Errorf("SignatureDevice SignCOSE | id: %s | err: %w", sd.ID, err)
+	}
+	if err := sd.commit(ctx, []Signature{signature}, commit); err != nil {
+		return Signature{}, nil, err
+	}
+	return signature, coseSign1, nil
+}
+
+// SignBatch signs all provided data in order with consecutive signature counters, each chained to its
+// predecessor. Either all signatures are committed and the device state is advanced, or none.
+func (sd *SignatureDevice) SignBatch(ctx context.Context, clientID string, dataToBeSigned []string, commit CommitFunc) (_ []Signature, err error) {
+	if len(dataToBeSigned) == 0 {
+		return nil, fmt.Errorf("SignatureDevice SignBatch | id: %s | no data to be signed", sd.ID)
+	}
+	ctx, span := sd.startSpan(ctx, "SignatureDevice.SignBatch", attribute.Int("batch_size", len(dataToBeSigned)))
+	defer endSpan(span, &err)
+
+	if err := sd.lockForSigning(ctx); err != nil {
+		return nil, err
+	}
 	defer sd.mu.Unlock()
-	secDataToBeSigned := prepareSecDataToBeSigned(dataToBeSigned, sd.lastSignature, sd.signatureCounter)
 
+	if err := sd.authorize(clientID, SigningModeData); err != nil {
+		return nil, err
+	}
+
+	signatures := make([]Signature, len(dataToBeSigned))
+	counter := sd.signatureCounter
+	lastSignature := sd.lastSignature
+	for i, data := range dataToBeSigned {
+		signature, err := sd.sign(ctx, clientID, SigningModeData, data, counter, lastSignature)
+		if err != nil {
+			return nil, err
+		}
+		signatures[i] = signature
+		counter++
+		lastSignature = signature.Signature
+	}
+
+	if err := sd.commit(ctx, signatures, commit); err != nil {
+		return nil, err
+	}
+	return signatures, nil
+}
+
+// SupportsMode returns ErrModeUnsupported if the device cannot sign in the mode. Only formats that record the mode
+// sign anything but data, otherwise the signed data of a digest or transaction could pass for plain data.
+func (sd *SignatureDevice) SupportsMode(mode SigningMode) error {
+	if mode != SigningModeData && !sd.format.RecordsMetadata() {
+		return fmt.Errorf("SignatureDevice | id: %s | mode: %s | format: %s | %w", sd.ID, mode, sd.format, ErrModeUnsupported)
+	}
+	return nil
+}
+
+// PublicKey returns the PEM encoded public key of the device.
+func (sd *SignatureDevice) PublicKey() ([]byte, error) {
+	publicKey, err := sd.signer.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("SignatureDevice PublicKey | id: %s | err: %w", sd.ID, err)
+	}
+	return publicKey, nil
+}
+
+// sign creates the signature of dataToBeSigned in the mode for the given chain state without advancing the device
+// state. The caller has to hold sd.mu and to authorize the mode.
+func (sd *SignatureDevice) sign(ctx context.Context, clientID string, mode SigningMode, dataToBeSigned string, counter int, lastSignature string) (Signature, error) {
+	secDataToBeSigned, err := SecuredData{
+		Counter:       counter,
+		ClientID:      clientID,
+		Mode:          mode,
+		Data:          dataToBeSigned,
+		LastSignature: lastSignature,
+	}.Encode(sd.format)
+	if err != nil {
+		return Signature{}, fmt.Errorf("SignatureDevice Sign | id: %s | err: %w", sd.ID, err)
+	}
+
+	_, span := startChildSpan(ctx, "Signer.Sign", attribute.String("algorithm", string(sd.Algorithm)), attribute.Int("counter", counter))
 	rawSig, err := sd.signer.Sign([]byte(secDataToBeSigned))
+	recordError(span, err)
+	span.End()
 	if err != nil {
-		return "", "", fmt.Errorf("SignatureDevice Sign | id: %s | err: %w", sd.ID, err)
+		return Signature{}, fmt.Errorf("SignatureDevice Sign | id: %s | err: %w", sd.ID, err)
 	}
-	encodedSignature := base64.StdEncoding.EncodeToString(rawSig)
-	sd.lastSignature = encodedSignature
+	var timestamp []byte
+	if sd.timestamper != nil {
+		ctx, span := startChildSpan(ctx, "Timestamper.Timestamp")
+		timestamp, err = sd.timestamper.Timestamp(ctx, rawSig)
+		recordError(span, err)
+		span.End()
+		if err != nil {
+			return Signature{}, fmt.Errorf("SignatureDevice Sign timestamp | id: %s | err: %w", sd.ID, err)
+		}
+	}
+	return Signature{
+		DeviceID:   sd.ID,
+		ClientID:   clientID,
+		Counter:    counter,
+		SignedData: secDataToBeSigned,
+		Format:     sd.format,
+		Mode:       mode,
+		Signature:  base64.StdEncoding.EncodeToString(rawSig),
+		CreatedAt:  sd.clock.Now().UTC(),
+		Timestamp:  timestamp,
+	}, nil
+}
 
-	sd.signatureCounter++
-	// mux unlocks here
-	return encodedSignature, secDataToBeSigned, nil
+// commit persists the signatures and advances the signature chain to the last of them. Signatures that have been
+// created are committed even if ctx is canceled meanwhile, so a caller giving up does not discard them.
+// The caller has to hold sd.mu.
+func (sd *SignatureDevice) commit(ctx context.Context, signatures []Signature, commit CommitFunc) error {
+	if commit != nil {
+		ctx, span := startChildSpan(context.WithoutCancel(ctx), "SignatureDevice.commit", attribute.Int("signatures", len(signatures)))
+		err := commit(ctx, signatures)
+		recordError(span, err)
+		span.End()
+		if err != nil {
+			return fmt.Errorf("SignatureDevice commit | id: %s | err: %w", sd.ID, err)
+		}
+	}
+	sd.measurements().AddSignatures(sd.Algorithm, len(signatures))
+	last := signatures[len(signatures)-1]
+	sd.lastSignature = last.Signature
+	sd.signatureCounter = last.Counter + 1
+	return nil
 }
 
 func prepareSecDataToBeSigned(dataToBeSigned string, lastSignature string, signatureCounter int) string {
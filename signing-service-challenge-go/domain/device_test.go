@@ -1,9 +1,16 @@
 package domain
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
@@ -12,6 +19,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+const testClientID = "register-1"
+
 func TestNewSignatureDevice(t *testing.T) {
 	t.Run("no id", func(t *testing.T) {
 		sd, err := NewSignatureDevice(uuid.Nil, "", crypto.SignatureRSA)
@@ -23,6 +32,26 @@ func TestNewSignatureDevice(t *testing.T) {
 		require.Nil(t, sd)
 		assert.NotNil(t, err)
 	})
+	t.Run("unsupported format", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "", crypto.SignatureRSA, WithSecuredDataFormat("v9"))
+		require.Nil(t, sd)
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+	t.Run("key size", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "", crypto.SignautreECDSA, WithKeySize(256))
+		require.Nil(t, err)
+		publicKey, err := sd.PublicKey()
+		require.Nil(t, err)
+		block, _ := pem.Decode(publicKey)
+		require.NotNil(t, block)
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		require.Nil(t, err)
+		assert.Equal(t, "P-256", key.(*ecdsa.PublicKey).Curve.Params().Name)
+
+		sd, err = NewSignatureDevice(uuid.New(), "", crypto.SignautreECDSA, WithKeySize(512))
+		require.Nil(t, sd)
+		assert.NotNil(t, err)
+	})
 	t.Run("default", func(t *testing.T) {
 		id := uuid.New()
 		label := "myDev"
@@ -44,16 +73,19 @@ func TestNewSignatureDevice(t *testing.T) {
 func TestSign(t *testing.T) {
 	dataToBeSigned := "data"
 	t.Run("ecdsa sign", func(t *testing.T) {
-		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV0))
 		require.Nil(t, err)
 		require.NotNil(t, sd)
 
 		base64ID := base64.StdEncoding.EncodeToString([]byte(sd.ID.String()))
 		expectedSecData := fmt.Sprintf("0_data_%s", base64ID)
 
-		signature, secData, err := sd.Sign(dataToBeSigned)
+		sig, err := sd.Sign(context.Background(), "", dataToBeSigned, nil)
 		require.Nil(t, err)
-		assert.Equal(t, expectedSecData, secData)
+		assert.Equal(t, expectedSecData, sig.SignedData)
+		assert.Equal(t, SigningModeData, sig.Mode)
+		assert.Equal(t, 0, sig.Counter)
+		signature := sig.Signature
 
 		rawSig, err := base64.StdEncoding.DecodeString(signature)
 		require.Nil(t, err)
@@ -64,16 +96,19 @@ func TestSign(t *testing.T) {
 		assert.Equal(t, signature, sd.lastSignature)
 	})
 	t.Run("rsa sign", func(t *testing.T) {
-		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignatureRSA)
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignatureRSA, WithSecuredDataFormat(SecuredDataV0))
 		require.Nil(t, err)
 		require.NotNil(t, sd)
 
 		base64ID := base64.StdEncoding.EncodeToString([]byte(sd.ID.String()))
 		expectedSecData := fmt.Sprintf("0_data_%s", base64ID)
 
-		signature, secData, err := sd.Sign(dataToBeSigned)
+		sig, err := sd.Sign(context.Background(), "", dataToBeSigned, nil)
 		require.Nil(t, err)
-		assert.Equal(t, expectedSecData, secData)
+		assert.Equal(t, expectedSecData, sig.SignedData)
+		assert.Equal(t, SigningModeData, sig.Mode)
+		assert.Equal(t, 0, sig.Counter)
+		signature := sig.Signature
 
 		rawSig, err := base64.StdEncoding.DecodeString(signature)
 		require.Nil(t, err)
@@ -86,6 +121,216 @@ func TestSign(t *testing.T) {
 
 }
 
+// cancelingTimestamper cancels the context of the signing call while the signature is created.
+type cancelingTimestamper struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelingTimestamper) Timestamp(context.Context, []byte) ([]byte, error) {
+	c.cancel()
+	return []byte("token"), nil
+}
+
+func TestSignCanceled(t *testing.T) {
+	t.Run("before signing", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		committed := false
+		_, err = sd.Sign(ctx, testClientID, "data", func(context.Context, []Signature) error {
+			committed = true
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, committed)
+		assert.Equal(t, 0, sd.Info().SignatureCounter)
+
+		_, _, err = sd.StartTransaction(ctx, testClientID, "data", nil)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("while signing", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		ctx, cancel := context.WithCancel(context.Background())
+		sd.EnableTimestamps(cancelingTimestamper{cancel: cancel})
+
+		var commitErr error
+		signature, err := sd.Sign(ctx, testClientID, "data", func(ctx context.Context, _ []Signature) error {
+			commitErr = ctx.Err()
+			return nil
+		})
+		require.Nil(t, err, "a created signature is committed")
+		assert.Nil(t, commitErr)
+		assert.Equal(t, 0, signature.Counter)
+		assert.Equal(t, 1, sd.Info().SignatureCounter)
+	})
+}
+
+func TestSignSecuredDataV1(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignatureRSA, WithSecuredDataFormat(SecuredDataV1))
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+	base64ID := base64.StdEncoding.EncodeToString([]byte(sd.ID.String()))
+
+	first, err := sd.Sign(context.Background(), testClientID, "a_b", nil)
+	require.Nil(t, err)
+	assert.Equal(t, SecuredDataV1, first.Format)
+	assert.Equal(t, fmt.Sprintf("v1:1:0,10:%s,4:DATA,3:a_b,%d:%s,", testClientID, len(base64ID), base64ID), first.SignedData)
+
+	second, err := sd.Sign(context.Background(), testClientID, "", nil)
+	require.Nil(t, err)
+	parsed, err := ParseSecuredData(second.Format, second.SignedData)
+	require.Nil(t, err)
+	assert.Equal(t, SecuredData{Counter: 1, ClientID: testClientID, Mode: SigningModeData, LastSignature: first.Signature}, parsed)
+
+	rawSig, err := base64.StdEncoding.DecodeString(second.Signature)
+	require.Nil(t, err)
+	assert.True(t, sd.signer.Verify([]byte(second.SignedData), rawSig))
+	assert.Equal(t, SecuredDataV1, sd.Info().SecuredDataFormat)
+
+	t.Run("digest", func(t *testing.T) {
+		digest := sha256.Sum256([]byte("content"))
+		signed, err := sd.SignDigest(context.Background(), testClientID, crypto.HashSHA256, digest[:], nil)
+		require.Nil(t, err)
+		plain, err := sd.Sign(context.Background(), testClientID, DigestToBeSigned(crypto.HashSHA256, digest[:]), nil)
+		require.Nil(t, err)
+
+		signedData, err := ParseSecuredData(signed.Format, signed.SignedData)
+		require.Nil(t, err)
+		plainData, err := ParseSecuredData(plain.Format, plain.SignedData)
+		require.Nil(t, err)
+		assert.Equal(t, signedData.Data, plainData.Data)
+		assert.Equal(t, SigningModeDigest, signedData.Mode)
+		assert.Equal(t, SigningModeData, plainData.Mode, "plain data cannot pass for a digest")
+	})
+}
+
+func TestSignDigest(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+	digest := sha512.Sum384([]byte("receipt"))
+
+	t.Run("invalid digest", func(t *testing.T) {
+		_, err := sd.SignDigest(context.Background(), testClientID, crypto.HashSHA256, digest[:], nil)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, sd.signatureCounter)
+	})
+	t.Run("default", func(t *testing.T) {
+		base64ID := base64.StdEncoding.EncodeToString([]byte(sd.ID.String()))
+		expectedSecData, err := SecuredData{
+			ClientID:      testClientID,
+			Mode:          SigningModeDigest,
+			Data:          "SHA-384:" + base64.StdEncoding.EncodeToString(digest[:]),
+			LastSignature: base64ID,
+		}.Encode(SecuredDataV1)
+		require.Nil(t, err)
+
+		signature, err := sd.SignDigest(context.Background(), testClientID, crypto.HashSHA384, digest[:], nil)
+		require.Nil(t, err)
+		assert.Equal(t, expectedSecData, signature.SignedData)
+		assert.Equal(t, SigningModeDigest, signature.Mode)
+
+		rawSig, err := base64.StdEncoding.DecodeString(signature.Signature)
+		require.Nil(t, err)
+		assert.True(t, sd.signer.Verify([]byte(expectedSecData), rawSig))
+		assert.Equal(t, 1, sd.signatureCounter)
+	})
+	t.Run("format without mode", func(t *testing.T) {
+		v0, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV0))
+		require.Nil(t, err)
+
+		_, err = v0.SignDigest(context.Background(), "", crypto.HashSHA384, digest[:], nil)
+		assert.ErrorIs(t, err, ErrModeUnsupported, "the digest could pass for plain data")
+		_, _, err = v0.StartTransaction(context.Background(), "", "basket", nil)
+		assert.ErrorIs(t, err, ErrModeUnsupported)
+		assert.Equal(t, 0, v0.signatureCounter)
+	})
+}
+
+func TestSignCOSE(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV0))
+	require.Nil(t, err)
+
+	base64ID := base64.StdEncoding.EncodeToString([]byte(sd.ID.String()))
+	expectedSecData := fmt.Sprintf("0_data_%s", base64ID)
+
+	sig, coseSign1, err := sd.SignCOSE(context.Background(), "", "data", nil)
+	require.Nil(t, err)
+	assert.Equal(t, expectedSecData, sig.SignedData)
+	signature := sig.Signature
+	assert.Equal(t, signature, sd.lastSignature)
+	assert.Equal(t, 1, sd.signatureCounter)
+
+	payload, err := crypto.VerifyCOSE1(sd.signer, coseSign1)
+	require.Nil(t, err)
+	assert.Equal(t, expectedSecData, string(payload))
+
+	rawSig, err := base64.StdEncoding.DecodeString(signature)
+	require.Nil(t, err)
+	assert.True(t, sd.signer.Verify([]byte(expectedSecData), rawSig), "chain signature is independent of COSE")
+}
+
+func TestSignBatch(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+
+		signatures, err := sd.SignBatch(context.Background(), testClientID, nil, nil)
+		assert.NotNil(t, err)
+		assert.Nil(t, signatures)
+	})
+	t.Run("chained", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV0))
+		require.Nil(t, err)
+		_, err = sd.Sign(context.Background(), "", "first", nil)
+		require.Nil(t, err)
+		lastSignature := sd.lastSignature
+
+		var committed []Signature
+		signatures, err := sd.SignBatch(context.Background(), "", []string{"a", "b", "c"}, func(_ context.Context, signatures []Signature) error {
+			committed = signatures
+			return nil
+		})
+		require.Nil(t, err)
+		require.Equal(t, 3, len(signatures))
+		assert.Equal(t, signatures, committed)
+
+		for i, signature := range signatures {
+			assert.Equal(t, sd.ID, signature.DeviceID)
+			assert.Equal(t, i+1, signature.Counter)
+			expectedSecData := prepareSecDataToBeSigned([]string{"a", "b", "c"}[i], lastSignature, i+1)
+			assert.Equal(t, expectedSecData, signature.SignedData)
+
+			rawSig, err := base64.StdEncoding.DecodeString(signature.Signature)
+			require.Nil(t, err)
+			assert.True(t, sd.signer.Verify([]byte(signature.SignedData), rawSig))
+			lastSignature = signature.Signature
+		}
+		assert.Equal(t, 4, sd.signatureCounter)
+		assert.Equal(t, lastSignature, sd.lastSignature)
+	})
+	t.Run("failed commit", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignatureRSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		lastSignature := sd.lastSignature
+
+		signatures, err := sd.SignBatch(context.Background(), testClientID, []string{"a", "b"}, func(_ context.Context, signatures []Signature) error {
+			return fmt.Errorf("store unavailable")
+		})
+		assert.NotNil(t, err)
+		assert.Nil(t, signatures)
+		assert.Equal(t, 0, sd.signatureCounter, "counter must not advance without commit")
+		assert.Equal(t, lastSignature, sd.lastSignature)
+	})
+}
+
 func TestPrepareSecDataToBeSigned(t *testing.T) {
 	t.Run("no input", func(t *testing.T) {
 		expected := "0__"
@@ -103,3 +348,66 @@ func TestPrepareSecDataToBeSigned(t *testing.T) {
 		assert.Equal(t, expected, res)
 	})
 }
+
+// parseSecData splits secured data into its parts. Base64 signatures never contain '_', so the data in the
+// middle may contain anything.
+func parseSecData(t *testing.T, secData string) (int, string, string) {
+	t.Helper()
+	counterPart, rest, ok := strings.Cut(secData, "_")
+	require.True(t, ok)
+	counter, err := strconv.Atoi(counterPart)
+	require.Nil(t, err)
+	separator := strings.LastIndex(rest, "_")
+	require.GreaterOrEqual(t, separator, 0)
+	return counter, rest[:separator], rest[separator+1:]
+}
+
+func FuzzPrepareSecDataToBeSigned(f *testing.F) {
+	f.Add("data", "bGFzdA==", 0)
+	f.Add("with_under_scores", "bGFzdA==", 17)
+	f.Add("line\nbreak", "", 1)
+	f.Add("\xff\xfe invalid utf-8", "+/==", -1)
+	f.Add("", "", 0)
+
+	f.Fuzz(func(t *testing.T, data string, lastSignature string, counter int) {
+		secData := prepareSecDataToBeSigned(data, lastSignature, counter)
+		assert.Equal(t, strconv.Itoa(counter)+"_"+data+"_"+lastSignature, secData)
+
+		if strings.Contains(lastSignature, "_") {
+			return
+		}
+		// the format is unambiguous for base64 signatures
+		gotCounter, gotData, gotLastSignature := parseSecData(t, secData)
+		assert.Equal(t, counter, gotCounter)
+		assert.Equal(t, data, gotData)
+		assert.Equal(t, lastSignature, gotLastSignature)
+	})
+}
+
+func FuzzSign(f *testing.F) {
+	sd, err := NewSignatureDevice(uuid.New(), "fuzz", crypto.SignatureRSA, WithSecuredDataFormat(SecuredDataV0))
+	require.Nil(f, err)
+	publicKey, err := sd.PublicKey()
+	require.Nil(f, err)
+	verifier, _, err := crypto.NewVerifier(publicKey)
+	require.Nil(f, err)
+
+	f.Add("data")
+	f.Add("_")
+	f.Add("__a_b__")
+	f.Add("multi\nline\r\n")
+	f.Add("\xff\x00invalid")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		signature, err := sd.Sign(context.Background(), "", data, nil)
+		require.Nil(t, err)
+
+		counter, gotData, _ := parseSecData(t, signature.SignedData)
+		assert.Equal(t, signature.Counter, counter)
+		assert.Equal(t, data, gotData)
+
+		raw, err := base64.StdEncoding.DecodeString(signature.Signature)
+		require.Nil(t, err)
+		assert.True(t, verifier.Verify([]byte(signature.SignedData), raw))
+	})
+}
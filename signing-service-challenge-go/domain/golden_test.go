@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+const goldenFile = "golden.json"
+
+// goldenVector is the expected output of a device created from fixed entropy, clock and id.
+type goldenVector struct {
+	Algorithm     crypto.SignatureAlgorithm `json:"algorithm"`
+	Deterministic bool                      `json:"deterministic,omitempty"`
+	Format        SecuredDataFormat         `json:"format,omitempty"`
+	PublicKey     string                    `json:"public_key"`
+	Signatures    []goldenSignature         `json:"signatures"`
+}
+
+type goldenSignature struct {
+	Counter    int    `json:"counter"`
+	Data       string `json:"data"`
+	SignedData string `json:"signed_data"`
+	// Signature is empty if the algorithm signs with randomness the entropy does not control.
+	Signature string    `json:"signature,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	goldenDeviceID = uuid.MustParse("4c6f6e67-2074-696d-6520-6e6f20736565")
+	goldenStart    = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	goldenPayloads = []string{"first", "second", "SHA256:n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=", ""}
+)
+
+// goldenDevice creates the device of the golden vectors for the algorithm, deterministic flag and format of config.
+func goldenDevice(t *testing.T, config goldenVector) *SignatureDevice {
+	t.Helper()
+	entropy := crypto.NewDeterministicEntropy([]byte("golden-" + string(config.Algorithm)))
+	options := []Option{WithEntropy(entropy), WithClock(clock.NewFake(goldenStart, time.Second))}
+	if config.Deterministic {
+		options = append(options, WithDeterministicSignatures())
+	}
+	if config.Format != "" {
+		options = append(options, WithSecuredDataFormat(config.Format))
+	}
+	sd, err := NewSignatureDevice(goldenDeviceID, "golden", config.Algorithm, options...)
+	require.Nil(t, err)
+	if clientID := goldenClientID(sd.Info().SecuredDataFormat); clientID != "" {
+		require.Nil(t, sd.RegisterClient(clientID))
+	}
+	return sd
+}
+
+// goldenClientID returns the client that signs the golden payloads in the format; only formats that record it
+// accept clients.
+func goldenClientID(format SecuredDataFormat) string {
+	if formatOrDefault(format).RecordsMetadata() {
+		return testClientID
+	}
+	return ""
+}
+
+// signGolden signs the golden payloads with a fresh device and returns the resulting vector.
+func signGolden(t *testing.T, config goldenVector, payloads []string) (goldenVector, []Signature) {
+	t.Helper()
+	sd := goldenDevice(t, config)
+	publicKey, err := sd.PublicKey()
+	require.Nil(t, err)
+
+	vector := config
+	vector.PublicKey = string(publicKey)
+	var signatures []Signature
+	for _, data := range payloads {
+		sig, err := sd.Sign(context.Background(), goldenClientID(sd.Info().SecuredDataFormat), data, nil)
+		require.Nil(t, err)
+		signatures = append(signatures, sig)
+		golden := goldenSignature{Counter: sig.Counter, Data: data, SignedData: sig.SignedData, CreatedAt: sig.CreatedAt}
+		if sd.Info().Deterministic {
+			golden.Signature = sig.Signature
+		}
+		vector.Signatures = append(vector.Signatures, golden)
+	}
+	return vector, signatures
+}
+
+func TestGoldenVectors(t *testing.T) {
+	// randomized ECDSA signatures are not reproducible, so only the first secured data is
+	cases := []struct {
+		config   goldenVector
+		payloads []string
+	}{
+		{goldenVector{Algorithm: crypto.SignatureRSA, Format: SecuredDataV0}, goldenPayloads},
+		{goldenVector{Algorithm: crypto.SignautreECDSA, Format: SecuredDataV0}, goldenPayloads[:1]},
+		{goldenVector{Algorithm: crypto.SignautreECDSA, Deterministic: true, Format: SecuredDataV0}, goldenPayloads},
+		{goldenVector{Algorithm: crypto.SignautreECDSA, Deterministic: true, Format: SecuredDataV1}, goldenPayloads},
+	}
+
+	var vectors []goldenVector
+	for _, c := range cases {
+		vector, _ := signGolden(t, c.config, c.payloads)
+		vectors = append(vectors, vector)
+	}
+
+	path := filepath.Join("testdata", goldenFile)
+	if *update {
+		encoded, err := json.MarshalIndent(vectors, "", "  ")
+		require.Nil(t, err)
+		require.Nil(t, os.WriteFile(path, append(encoded, '\n'), 0o644))
+	}
+
+	encoded, err := os.ReadFile(path)
+	require.Nil(t, err)
+	var expected []goldenVector
+	require.Nil(t, json.Unmarshal(encoded, &expected))
+
+	require.Len(t, expected, len(vectors))
+	for i, vector := range vectors {
+		t.Run(fmt.Sprintf("%s deterministic=%t format=%s", vector.Algorithm, vector.Deterministic, vector.Format), func(t *testing.T) {
+			assert.Equal(t, expected[i], vector)
+		})
+	}
+}
+
+func TestGoldenVectorsVerify(t *testing.T) {
+	encoded, err := os.ReadFile(filepath.Join("testdata", goldenFile))
+	require.Nil(t, err)
+	var vectors []goldenVector
+	require.Nil(t, json.Unmarshal(encoded, &vectors))
+
+	for _, vector := range vectors {
+		t.Run(string(vector.Algorithm), func(t *testing.T) {
+			verifier, algorithm, err := crypto.NewVerifier([]byte(vector.PublicKey))
+			require.Nil(t, err)
+			assert.Equal(t, vector.Algorithm, algorithm)
+
+			for _, sig := range vector.Signatures {
+				securedData, err := ParseSecuredData(vector.Format, sig.SignedData)
+				require.Nil(t, err)
+				expected := SecuredData{Counter: sig.Counter, ClientID: goldenClientID(vector.Format), Data: sig.Data}
+				if formatOrDefault(vector.Format).RecordsMetadata() {
+					expected.Mode = SigningModeData
+				}
+				securedData.LastSignature = ""
+				assert.Equal(t, expected, securedData)
+				if sig.Signature == "" {
+					continue
+				}
+				raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+				require.Nil(t, err)
+				assert.True(t, verifier.Verify([]byte(sig.SignedData), raw), "counter %d", sig.Counter)
+			}
+		})
+	}
+
+	t.Run("chain", func(t *testing.T) {
+		// the full pipeline of both algorithms produces a valid chain even where bytes are not reproducible
+		for _, algorithm := range []crypto.SignatureAlgorithm{crypto.SignatureRSA, crypto.SignautreECDSA} {
+			vector, signatures := signGolden(t, goldenVector{Algorithm: algorithm}, goldenPayloads)
+			verifier, _, err := crypto.NewVerifier([]byte(vector.PublicKey))
+			require.Nil(t, err)
+			assert.Empty(t, VerifyChain(goldenDeviceID, verifier, signatures), algorithm)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// Metrics receives measurements of signature devices. Implementations have to be safe for concurrent use.
+type Metrics interface {
+	// ObserveKeyGeneration is called with the time it took to generate the key pair of a new device.
+	ObserveKeyGeneration(algorithm crypto.SignatureAlgorithm, d time.Duration)
+	// ObserveLockWait is called with the time a signing call waited for the device to be free.
+	ObserveLockWait(algorithm crypto.SignatureAlgorithm, d time.Duration)
+	// AddSignatures is called with the number of signatures committed to the ledger.
+	AddSignatures(algorithm crypto.SignatureAlgorithm, n int)
+}
+
+// nopMetrics discards all measurements.
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveKeyGeneration(crypto.SignatureAlgorithm, time.Duration) {}
+func (nopMetrics) ObserveLockWait(crypto.SignatureAlgorithm, time.Duration)      {}
+func (nopMetrics) AddSignatures(crypto.SignatureAlgorithm, int)                  {}
+
+// lockForSigning acquires sd.mu and reports the time spent waiting for it. If ctx is done once the device is
+// free, the caller gave up while waiting: the lock is released again and the error of ctx returned, so no
+// signature is created for it.
+func (sd *SignatureDevice) lockForSigning(ctx context.Context) error {
+	_, span := startChildSpan(ctx, "SignatureDevice.lock")
+	start := time.Now()
+	sd.mu.Lock()
+	sd.measurements().ObserveLockWait(sd.Algorithm, time.Since(start))
+	span.End()
+	if err := ctx.Err(); err != nil {
+		sd.mu.Unlock()
+		return fmt.Errorf("SignatureDevice lock | id: %s | err: %w", sd.ID, err)
+	}
+	return nil
+}
+
+// measurements returns the Metrics of the device; devices not created by NewSignatureDevice have none.
+func (sd *SignatureDevice) measurements() Metrics {
+	if sd.metrics == nil {
+		return nopMetrics{}
+	}
+	return sd.metrics
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics counts the measurements per algorithm.
+type recordingMetrics struct {
+	mu             sync.Mutex
+	keyGenerations map[crypto.SignatureAlgorithm]int
+	lockWaits      map[crypto.SignatureAlgorithm]int
+	signatures     map[crypto.SignatureAlgorithm]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		keyGenerations: map[crypto.SignatureAlgorithm]int{},
+		lockWaits:      map[crypto.SignatureAlgorithm]int{},
+		signatures:     map[crypto.SignatureAlgorithm]int{},
+	}
+}
+
+func (m *recordingMetrics) ObserveKeyGeneration(algorithm crypto.SignatureAlgorithm, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyGenerations[algorithm]++
+}
+
+func (m *recordingMetrics) ObserveLockWait(algorithm crypto.SignatureAlgorithm, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockWaits[algorithm]++
+}
+
+func (m *recordingMetrics) AddSignatures(algorithm crypto.SignatureAlgorithm, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signatures[algorithm] += n
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("measurements", func(t *testing.T) {
+		m := newRecordingMetrics()
+		sd, err := NewSignatureDevice(uuid.New(), "", crypto.SignautreECDSA, WithMetrics(m))
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		assert.Equal(t, 1, m.keyGenerations[crypto.SignautreECDSA])
+
+		_, err = sd.Sign(context.Background(), testClientID, "data", nil)
+		require.Nil(t, err)
+		_, err = sd.SignBatch(context.Background(), testClientID, []string{"a", "b", "c"}, nil)
+		require.Nil(t, err)
+		_, _, err = sd.SignCOSE(context.Background(), testClientID, "data", nil)
+		require.Nil(t, err)
+		_, _, err = sd.StartTransaction(context.Background(), testClientID, "data", nil)
+		require.Nil(t, err)
+		_, err = sd.Sign(context.Background(), testClientID, "failed", func(context.Context, []Signature) error { return errors.New("commit failed") })
+		require.NotNil(t, err)
+
+		assert.Equal(t, 5, m.lockWaits[crypto.SignautreECDSA])
+		assert.Equal(t, 6, m.signatures[crypto.SignautreECDSA], "failed commits are not counted")
+		assert.Empty(t, m.signatures[crypto.SignatureRSA])
+	})
+	t.Run("fake clock", func(t *testing.T) {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		sd, err := NewSignatureDevice(uuid.New(), "", crypto.SignautreECDSA, WithMetrics(newRecordingMetrics()), WithClock(clock.NewFake(start, time.Second)))
+		require.Nil(t, err)
+
+		signature, err := sd.Sign(context.Background(), "", "data", nil)
+		require.Nil(t, err)
+		assert.Equal(t, start, signature.CreatedAt, "durations are not measured with the clock")
+	})
+	t.Run("without metrics", func(t *testing.T) {
+		sd := &SignatureDevice{}
+		assert.NotPanics(t, func() { require.Nil(t, sd.lockForSigning(context.Background())); sd.mu.Unlock() })
+	})
+}
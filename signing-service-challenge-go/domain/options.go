@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// Option configures a SignatureDevice on creation.
+type Option func(*deviceOptions)
+
+// deviceOptions holds the dependencies of a SignatureDevice that can be replaced, e.g. for deterministic tests.
+type deviceOptions struct {
+	clock         clock.Clock
+	entropy       crypto.Entropy
+	deterministic bool
+	format        SecuredDataFormat
+	metrics       Metrics
+	keySize       int
+	owner         string
+}
+
+// WithClock sets the clock for the creation time of signatures. The default is the system clock.
+func WithClock(c clock.Clock) Option {
+	return func(o *deviceOptions) {
+		o.clock = c
+	}
+}
+
+// WithEntropy sets the randomness for the key pair and signatures. The default is the system entropy.
+func WithEntropy(entropy crypto.Entropy) Option {
+	return func(o *deviceOptions) {
+		o.entropy = entropy
+	}
+}
+
+// WithSecuredDataFormat sets the encoding of the secured data to be signed. The default is
+// DefaultSecuredDataFormat.
+func WithSecuredDataFormat(format SecuredDataFormat) Option {
+	return func(o *deviceOptions) {
+		o.format = format
+	}
+}
+
+// WithDeterministicSignatures derives the nonces of ECDSA signatures from the key and the secured data (RFC 6979),
+// so signatures can be re-derived byte for byte. RSA signatures are deterministic anyway.
+func WithDeterministicSignatures() Option {
+	return func(o *deviceOptions) {
+		o.deterministic = true
+	}
+}
+
+// WithMetrics reports key generation, lock wait and signature measurements of the device to m.
+func WithMetrics(m Metrics) Option {
+	return func(o *deviceOptions) {
+		o.metrics = m
+	}
+}
+
+// WithKeySize sets the size of the key pair in bits, see crypto.ValidateKeySize. The default is the default size
+// of the algorithm.
+func WithKeySize(bits int) Option {
+	return func(o *deviceOptions) {
+		o.keySize = bits
+	}
+}
+
+// WithOwner restricts the device to the identity, see SignatureDevice.AccessibleBy. The default is no owner.
+func WithOwner(identity string) Option {
+	return func(o *deviceOptions) {
+		o.owner = identity
+	}
+}
@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SecuredDataFormat identifies the encoding of the secured data to be signed. It is chosen per device and
+// recorded in every signature, so verifiers know how to read the signed data.
+type SecuredDataFormat string
+
+const (
+	// SecuredDataV0 is the format of the README: <counter>_<data>_<last_signature>. It contains neither client ID
+	// nor signing mode and can only be read back because base64 signatures never contain '_'; the data itself may.
+	SecuredDataV0 SecuredDataFormat = "v0"
+	// SecuredDataV1 encodes counter, client ID, signing mode, data and last signature as netstrings
+	// (<length>:<bytes>,) after the prefix "v1:", e.g. v1:1:0,10:register-1,4:DATA,4:data,8:bGFzdA==, - lengths
+	// count bytes. Every byte sequence has exactly one encoding and can be read back without restrictions on the
+	// fields.
+	SecuredDataV1 SecuredDataFormat = "v1"
+
+	// DefaultSecuredDataFormat is the format of devices that do not choose one. It records the client ID, so the
+	// client of every signature is covered by it.
+	DefaultSecuredDataFormat = SecuredDataV1
+)
+
+// ErrUnsupportedFormat is returned for unknown secured data formats.
+var ErrUnsupportedFormat = errors.New("unsupported secured data format")
+
+// securedDataV1Prefix starts all secured data in format v1.
+const securedDataV1Prefix = string(SecuredDataV1) + ":"
+
+// IsSupportedSecuredDataFormat checks whether devices can use the format.
+func IsSupportedSecuredDataFormat(format string) bool {
+	switch SecuredDataFormat(format) {
+	case SecuredDataV0, SecuredDataV1:
+		return true
+	}
+	return false
+}
+
+// RecordsMetadata reports whether client ID and signing mode are part of the secured data in the format.
+func (f SecuredDataFormat) RecordsMetadata() bool {
+	return f == SecuredDataV1
+}
+
+// SecuredData are the fields of the secured data to be signed. ClientID and Mode are only encoded by formats that
+// record metadata.
+type SecuredData struct {
+	Counter       int
+	ClientID      string
+	Mode          SigningMode
+	Data          string
+	LastSignature string
+}
+
+// Encode builds the secured data in the format.
+func (d SecuredData) Encode(format SecuredDataFormat) (string, error) {
+	switch format {
+	case SecuredDataV0:
+		return prepareSecDataToBeSigned(d.Data, d.LastSignature, d.Counter), nil
+	case SecuredDataV1:
+		var b strings.Builder
+		b.WriteString(securedDataV1Prefix)
+		for _, field := range []string{strconv.Itoa(d.Counter), d.ClientID, string(d.Mode), d.Data, d.LastSignature} {
+			b.WriteString(strconv.Itoa(len(field)))
+			b.WriteByte(':')
+			b.WriteString(field)
+			b.WriteByte(',')
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("SecuredData Encode | %q | %w", format, ErrUnsupportedFormat)
+}
+
+// ParseSecuredData reads secured data in the format. Signatures created before formats were recorded have an
+// empty format, which is read as v0.
+func ParseSecuredData(format SecuredDataFormat, securedData string) (SecuredData, error) {
+	switch format {
+	case "", SecuredDataV0:
+		return parseSecuredDataV0(securedData)
+	case SecuredDataV1:
+		return parseSecuredDataV1(securedData)
+	}
+	return SecuredData{}, fmt.Errorf("ParseSecuredData | %q | %w", format, ErrUnsupportedFormat)
+}
+
+func parseSecuredDataV0(securedData string) (SecuredData, error) {
+	counterPart, rest, ok := strings.Cut(securedData, "_")
+	separator := strings.LastIndex(rest, "_")
+	if !ok || separator < 0 {
+		return SecuredData{}, fmt.Errorf("parseSecuredDataV0 | missing separator")
+	}
+	counter, err := strconv.Atoi(counterPart)
+	if err != nil {
+		return SecuredData{}, fmt.Errorf("parseSecuredDataV0 | invalid counter: %w", err)
+	}
+	return SecuredData{
+		Counter:       counter,
+		Data:          rest[:separator],
+		LastSignature: rest[separator+1:],
+	}, nil
+}
+
+func parseSecuredDataV1(securedData string) (SecuredData, error) {
+	rest, ok := strings.CutPrefix(securedData, securedDataV1Prefix)
+	if !ok {
+		return SecuredData{}, fmt.Errorf("parseSecuredDataV1 | missing prefix")
+	}
+	fields := make([]string, 5)
+	for i := range fields {
+		field, next, err := cutNetstring(rest)
+		if err != nil {
+			return SecuredData{}, fmt.Errorf("parseSecuredDataV1 | field %d | %w", i, err)
+		}
+		fields[i] = field
+		rest = next
+	}
+	if rest != "" {
+		return SecuredData{}, fmt.Errorf("parseSecuredDataV1 | trailing data")
+	}
+	// the counter has to be canonical as well, otherwise two encodings would read the same
+	counter, err := strconv.Atoi(fields[0])
+	if err != nil || strconv.Itoa(counter) != fields[0] {
+		return SecuredData{}, fmt.Errorf("parseSecuredDataV1 | invalid counter %q", fields[0])
+	}
+	return SecuredData{
+		Counter:       counter,
+		ClientID:      fields[1],
+		Mode:          SigningMode(fields[2]),
+		Data:          fields[3],
+		LastSignature: fields[4],
+	}, nil
+}
+
+// cutNetstring reads the netstring at the start of s and returns its value and the remainder of s.
+func cutNetstring(s string) (string, string, error) {
+	length, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", "", fmt.Errorf("missing length")
+	}
+	n, err := strconv.Atoi(length)
+	if err != nil || n < 0 || strconv.Itoa(n) != length {
+		return "", "", fmt.Errorf("invalid length %q", length)
+	}
+	if len(rest) <= n || rest[n] != ',' {
+		return "", "", fmt.Errorf("value does not end after %d bytes", n)
+	}
+	return rest[:n], rest[n+1:], nil
+}
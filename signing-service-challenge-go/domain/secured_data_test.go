@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecuredData(t *testing.T) {
+	data := SecuredData{Counter: 12, ClientID: testClientID, Mode: SigningModeDigest, Data: "a_b,c:3", LastSignature: "bGFzdA=="}
+	t.Run("v0", func(t *testing.T) {
+		encoded, err := data.Encode(SecuredDataV0)
+		require.Nil(t, err)
+		assert.Equal(t, "12_a_b,c:3_bGFzdA==", encoded)
+
+		parsed, err := ParseSecuredData(SecuredDataV0, encoded)
+		require.Nil(t, err)
+		withoutMetadata := data
+		withoutMetadata.ClientID = ""
+		withoutMetadata.Mode = ""
+		assert.Equal(t, withoutMetadata, parsed, "format v0 records neither client id nor mode")
+		assert.False(t, SecuredDataV0.RecordsMetadata())
+	})
+	t.Run("v1", func(t *testing.T) {
+		encoded, err := data.Encode(SecuredDataV1)
+		require.Nil(t, err)
+		assert.Equal(t, "v1:2:12,10:register-1,6:DIGEST,7:a_b,c:3,8:bGFzdA==,", encoded)
+
+		parsed, err := ParseSecuredData(SecuredDataV1, encoded)
+		require.Nil(t, err)
+		assert.Equal(t, data, parsed)
+		assert.True(t, SecuredDataV1.RecordsMetadata())
+	})
+	t.Run("empty format is v0", func(t *testing.T) {
+		parsed, err := ParseSecuredData("", "0_d_l")
+		require.Nil(t, err)
+		assert.Equal(t, SecuredData{Data: "d", LastSignature: "l"}, parsed)
+	})
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := data.Encode("v9")
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		_, err = ParseSecuredData("v9", "")
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		assert.False(t, IsSupportedSecuredDataFormat("v9"))
+		assert.True(t, IsSupportedSecuredDataFormat("v1"))
+	})
+	t.Run("invalid v0", func(t *testing.T) {
+		for _, input := range []string{"", "0_data", "x_data_last"} {
+			_, err := ParseSecuredData(SecuredDataV0, input)
+			assert.NotNil(t, err, input)
+		}
+	})
+	t.Run("invalid v1", func(t *testing.T) {
+		for _, input := range []string{
+			"",
+			"1:0,1:c,1:m,1:d,1:l,",
+			"v1:1:0,1:c,1:d,1:l,",
+			"v1:1:0,1:c,1:m,1:d,1:l,trailing",
+			"v1:01:0,1:c,1:m,1:d,1:l,",
+			"v1:2:01,1:c,1:m,1:d,1:l,",
+			"v1:1:x,1:c,1:m,1:d,1:l,",
+			"v1:1:0,2:c,1:m,1:d,1:l,",
+			"v1:1:0,1:c,1:m,1:d,5:l,",
+			"v1:-1:0,1:c,1:m,1:d,1:l,",
+		} {
+			_, err := ParseSecuredData(SecuredDataV1, input)
+			assert.NotNil(t, err, input)
+		}
+	})
+}
+
+func FuzzParseSecuredDataV1(f *testing.F) {
+	f.Add("v1:2:12,10:register-1,4:DATA,7:a_b,c:3,8:bGFzdA==,")
+	f.Add("v1:1:0,0:,0:,0:,0:,")
+	f.Add("v1:1:0,1:c,1:m,1:d,1:l,x")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		parsed, err := ParseSecuredData(SecuredDataV1, input)
+		if err != nil {
+			return
+		}
+		// the encoding is canonical: whatever parses encodes to the same bytes
+		encoded, err := parsed.Encode(SecuredDataV1)
+		require.Nil(t, err)
+		assert.Equal(t, input, encoded)
+	})
+}
+
+func FuzzEncodeSecuredDataV1(f *testing.F) {
+	f.Add(0, testClientID, "DATA", "data", "bGFzdA==")
+	f.Add(-3, "", "", "1:x,", ",")
+	f.Add(7, "_", "DIGEST", "\xff\n", "")
+
+	f.Fuzz(func(t *testing.T, counter int, clientID string, mode string, data string, lastSignature string) {
+		securedData := SecuredData{Counter: counter, ClientID: clientID, Mode: SigningMode(mode), Data: data, LastSignature: lastSignature}
+		encoded, err := securedData.Encode(SecuredDataV1)
+		require.Nil(t, err)
+
+		parsed, err := ParseSecuredData(SecuredDataV1, encoded)
+		require.Nil(t, err)
+		assert.Equal(t, securedData, parsed)
+	})
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+)
+
+// Signature is the ledger entry of a signature created by a SignatureDevice
+type Signature struct {
+	DeviceID   uuid.UUID `json:"device_id"`
+	ClientID   string    `json:"client_id"`
+	Counter    int       `json:"counter"`
+	SignedData string    `json:"signed_data"`
+	// Format is the encoding of SignedData. Entries written before formats were recorded have none, which is v0.
+	Format SecuredDataFormat `json:"format"`
+	// Mode tells what the signed data stands for. Entries written before modes were recorded have none.
+	Mode      SigningMode `json:"mode,omitempty"`
+	Signature string      `json:"signature"`
+	CreatedAt time.Time   `json:"created_at"`
+
+	TransactionNumber int `json:"transaction_number,omitempty"`
+	// Timestamp is the DER encoded RFC 3161 timestamp token over the raw signature value, if the device has
+	// timestamps enabled.
+	Timestamp []byte `json:"timestamp,omitempty"`
+}
+
+// SigningMode tells what the data of a signature stands for. The data of digests and transactions can also be
+// sent as plain data, so only the mode tells them apart. Formats that do not record the mode only sign data.
+type SigningMode string
+
+// ErrModeUnsupported is returned for digests and transactions on devices whose secured data format does not record
+// the signing mode.
+var ErrModeUnsupported = errors.New("secured data format does not record the signing mode")
+
+const (
+	// SigningModeData signs the data sent by the client.
+	SigningModeData SigningMode = "DATA"
+	// SigningModeDigest signs the digest of content the client hashed or streamed, see DigestToBeSigned.
+	SigningModeDigest SigningMode = "DIGEST"
+	// SigningModeTransaction signs a state change of a transaction: <operation>:<transaction_number>:<data>.
+	SigningModeTransaction SigningMode = "TRANSACTION"
+)
+
+// CommitFunc persists signatures before the device state is advanced. If it returns an error, the signatures
+// are discarded and the device state stays untouched. The context carries the trace of the signing call.
+type CommitFunc func(ctx context.Context, signatures []Signature) error
+
+// DigestToBeSigned builds the data to be signed for content that is represented by its digest instead of the
+// content itself: <hash_algorithm>:<digest_base64_encoded>.
+func DigestToBeSigned(hashAlgorithm crypto.HashAlgorithm, digest []byte) string {
+	return string(hashAlgorithm) + ":" + base64.StdEncoding.EncodeToString(digest)
+}
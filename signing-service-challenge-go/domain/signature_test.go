@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestToBeSigned(t *testing.T) {
+	digest := sha256.Sum256([]byte("content"))
+	expected := "SHA-256:" + base64.StdEncoding.EncodeToString(digest[:])
+
+	assert.Equal(t, expected, DigestToBeSigned(crypto.HashSHA256, digest[:]))
+	assert.NotContains(t, DigestToBeSigned(crypto.HashSHA256, digest[:]), "_", "digest data must not contain the separator")
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+)
+
+// DeviceStatus describes the lifecycle state of a SignatureDevice
+type DeviceStatus string
+
+const (
+	DeviceActive         DeviceStatus = "ACTIVE"
+	DeviceDecommissioned DeviceStatus = "DECOMMISSIONED"
+)
+
+// ErrDeviceDecommissioned is returned when signing with a device that has been taken out of service.
+var ErrDeviceDecommissioned = errors.New("device decommissioned")
+
+// DeviceInfo is a consistent snapshot of the state of a SignatureDevice.
+type DeviceInfo struct {
+	ID                 uuid.UUID                 `json:"id"`
+	Label              string                    `json:"label"`
+	Algorithm          crypto.SignatureAlgorithm `json:"signature_algorithm"`
+	Status             DeviceStatus              `json:"status"`
+	SignatureCounter   int                       `json:"signature_counter"`
+	TransactionCounter int                       `json:"transaction_counter"`
+	Clients            []string                  `json:"clients"`
+	Timestamps         bool                      `json:"timestamps"`
+	Deterministic      bool                      `json:"deterministic"`
+	SecuredDataFormat  SecuredDataFormat         `json:"secured_data_format"`
+	Owner              string                    `json:"owner,omitempty"`
+}
+
+// Info returns a snapshot of the device state.
+func (sd *SignatureDevice) Info() DeviceInfo {
+	clients := sd.Clients()
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	status := DeviceActive
+	if sd.decommissioned {
+		status = DeviceDecommissioned
+	}
+	return DeviceInfo{
+		ID:                 sd.ID,
+		Label:              sd.Label,
+		Algorithm:          sd.Algorithm,
+		Status:             status,
+		SignatureCounter:   sd.signatureCounter,
+		TransactionCounter: sd.transactionCounter,
+		Clients:            clients,
+		Timestamps:         sd.timestamper != nil,
+		Deterministic:      crypto.Deterministic(sd.signer),
+		SecuredDataFormat:  sd.format,
+		Owner:              sd.owner,
+	}
+}
+
+// Owner returns the identity the device is restricted to, empty if the device has no owner.
+func (sd *SignatureDevice) Owner() string {
+	return sd.owner
+}
+
+// AccessibleBy reports whether the identity may use the device. Devices without owner are accessible by everyone,
+// including callers without identity.
+func (sd *SignatureDevice) AccessibleBy(identity string) bool {
+	return sd.owner == "" || sd.owner == identity
+}
+
+// SetLabel changes the label of the device. The label is not part of the signed data.
+func (sd *SignatureDevice) SetLabel(label string) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.Label = label
+}
+
+// Decommission takes the device out of service. It cannot sign afterwards, but its public key and signatures
+// remain available for verification. Decommissioning is final.
+func (sd *SignatureDevice) Decommission() error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if sd.decommissioned {
+		return fmt.Errorf("SignatureDevice Decommission | id: %s | %w", sd.ID, ErrDeviceDecommissioned)
+	}
+	sd.decommissioned = true
+	return nil
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfo(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+	_, err = sd.SignBatch(context.Background(), testClientID, []string{"a", "b"}, nil)
+	require.Nil(t, err)
+	sd.SetLabel("renamed")
+
+	assert.Equal(t, DeviceInfo{
+		ID:                sd.ID,
+		Label:             "renamed",
+		Algorithm:         crypto.SignautreECDSA,
+		Status:            DeviceActive,
+		SignatureCounter:  2,
+		Clients:           []string{testClientID},
+		SecuredDataFormat: SecuredDataV1,
+	}, sd.Info())
+
+	t.Run("deterministic", func(t *testing.T) {
+		rsa, err := NewSignatureDevice(uuid.New(), "", crypto.SignatureRSA)
+		require.Nil(t, err)
+		assert.True(t, rsa.Info().Deterministic)
+
+		ecdsa, err := NewSignatureDevice(uuid.New(), "", crypto.SignautreECDSA, WithDeterministicSignatures())
+		require.Nil(t, err)
+		assert.True(t, ecdsa.Info().Deterministic)
+	})
+	t.Run("owner", func(t *testing.T) {
+		owned, err := NewSignatureDevice(uuid.New(), "", crypto.SignautreECDSA, WithOwner("pos-1"))
+		require.Nil(t, err)
+		assert.Equal(t, "pos-1", owned.Info().Owner)
+		assert.True(t, owned.AccessibleBy("pos-1"))
+		assert.False(t, owned.AccessibleBy("pos-2"))
+		assert.False(t, owned.AccessibleBy(""))
+
+		assert.True(t, sd.AccessibleBy("pos-2"), "devices without owner are accessible by everyone")
+		assert.True(t, sd.AccessibleBy(""))
+	})
+}
+
+func TestDecommission(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+
+	require.Nil(t, sd.Decommission())
+	assert.Equal(t, DeviceDecommissioned, sd.Info().Status)
+
+	_, err = sd.Sign(context.Background(), testClientID, "data", nil)
+	assert.True(t, errors.Is(err, ErrDeviceDecommissioned))
+	_, _, err = sd.StartTransaction(context.Background(), testClientID, "data", nil)
+	assert.True(t, errors.Is(err, ErrDeviceDecommissioned))
+	assert.True(t, errors.Is(sd.Decommission(), ErrDeviceDecommissioned))
+
+	_, err = sd.PublicKey()
+	assert.Nil(t, err, "public key stays available for verification")
+}
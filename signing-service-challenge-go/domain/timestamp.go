@@ -0,0 +1,17 @@
+package domain
+
+import "context"
+
+// Timestamper obtains trusted timestamp tokens, e.g. RFC 3161 tokens of a Time-Stamp Authority. Timestamp is
+// called while the device is locked for signing, it has to give up once ctx is done.
+type Timestamper interface {
+	Timestamp(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// EnableTimestamps timestamps the value of every further signature of the device with timestamper. The token
+// is stored with the signature. Signing fails if no token can be obtained.
+func (sd *SignatureDevice) EnableTimestamps(timestamper Timestamper) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.timestamper = timestamper
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingTimestamper simulates an unavailable Time-Stamp Authority.
+type failingTimestamper struct{}
+
+func (failingTimestamper) Timestamp(context.Context, []byte) ([]byte, error) {
+	return nil, errors.New("tsa unavailable")
+}
+
+func TestEnableTimestamps(t *testing.T) {
+	authority, err := tsa.NewAuthority()
+	require.Nil(t, err)
+
+	t.Run("disabled", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+
+		signature, err := sd.Sign(context.Background(), testClientID, "data", nil)
+		require.Nil(t, err)
+		assert.Nil(t, signature.Timestamp)
+		assert.False(t, sd.Info().Timestamps)
+	})
+	t.Run("enabled", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		sd.EnableTimestamps(authority)
+		assert.True(t, sd.Info().Timestamps)
+
+		signatures, err := sd.SignBatch(context.Background(), testClientID, []string{"a", "b"}, nil)
+		require.Nil(t, err)
+		for _, signature := range signatures {
+			rawSig, err := base64.StdEncoding.DecodeString(signature.Signature)
+			require.Nil(t, err)
+			_, err = tsa.Verify(signature.Timestamp, rawSig)
+			assert.Nil(t, err)
+		}
+
+		publicKey, err := sd.PublicKey()
+		require.Nil(t, err)
+		verifier, _, err := crypto.NewVerifier(publicKey)
+		require.Nil(t, err)
+		assert.Empty(t, VerifyChain(sd.ID, verifier, signatures))
+
+		signatures[0].Timestamp = signatures[1].Timestamp
+		assert.Equal(t, []ChainViolation{{Counter: 0, Reason: "invalid timestamp"}}, VerifyChain(sd.ID, verifier, signatures))
+	})
+	t.Run("unavailable", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		sd.EnableTimestamps(failingTimestamper{})
+
+		_, err = sd.Sign(context.Background(), testClientID, "data", nil)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, sd.Info().SignatureCounter, "no signature without timestamp")
+	})
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans of the domain.
+const tracerName = "github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+
+// startChildSpan starts a child of the span in ctx with the tracer provider of that span. Without a span in ctx
+// nothing is recorded, so the domain needs no tracer of its own.
+func startChildSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// startSpan starts a span for an operation of the device within the trace in ctx.
+func (sd *SignatureDevice) startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	attributes = append(attributes,
+		attribute.String("device_id", sd.ID.String()),
+		attribute.String("algorithm", string(sd.Algorithm)),
+	)
+	return startChildSpan(ctx, name, attributes...)
+}
+
+// recordError marks the span as failed with err. A nil error is ignored.
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// endSpan records the error err points to, if any, and ends the span.
+func endSpan(span trace.Span, err *error) {
+	recordError(span, *err)
+	span.End()
+}
@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TransactionState describes the lifecycle state of a Transaction
+type TransactionState string
+
+const (
+	TransactionActive   TransactionState = "ACTIVE"
+	TransactionFinished TransactionState = "FINISHED"
+)
+
+// Operations recorded in the signed data of a transaction state change.
+const (
+	TransactionOperationStart  = "StartTransaction"
+	TransactionOperationUpdate = "UpdateTransaction"
+	TransactionOperationFinish = "FinishTransaction"
+)
+
+var (
+	// ErrTransactionNotFound is returned for transaction numbers unknown to the device.
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrTransactionFinished is returned when changing a transaction that has already been finished.
+	ErrTransactionFinished = errors.New("transaction already finished")
+	// ErrTransactionClient is returned when a client changes a transaction another client started.
+	ErrTransactionClient = errors.New("transaction belongs to another client")
+)
+
+// Transaction is a KassenSichV-like transaction of a SignatureDevice. Every state change is signed by the device
+// and the resulting signatures are chained like all other signatures of the device.
+type Transaction struct {
+	Number     int              `json:"number"`
+	ClientID   string           `json:"client_id"`
+	State      TransactionState `json:"state"`
+	StartedAt  time.Time        `json:"started_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	// SignatureCounters references the ledger entries of all state changes in order.
+	SignatureCounters []int `json:"signature_counters"`
+}
+
+// IsTimedOut reports whether the transaction is still active although it has not been changed within timeout.
+func (t Transaction) IsTimedOut(now time.Time, timeout time.Duration) bool {
+	return t.State == TransactionActive && now.Sub(t.UpdatedAt) > timeout
+}
+
+// StartTransaction opens a new transaction for a registered client with the next transaction number and signs
+// its start.
+func (sd *SignatureDevice) StartTransaction(ctx context.Context, clientID string, data string, commit CommitFunc) (_ Transaction, _ Signature, err error) {
+	ctx, span := sd.startSpan(ctx, "SignatureDevice.StartTransaction")
+	defer endSpan(span, &err)
+
+	if err := sd.lockForSigning(ctx); err != nil {
+		return Transaction{}, Signature{}, err
+	}
+	defer sd.mu.Unlock()
+
+	if err := sd.authorize(clientID, SigningModeTransaction); err != nil {
+		return Transaction{}, Signature{}, err
+	}
+	number := sd.transactionCounter + 1
+	signature, err := sd.signTransaction(ctx, clientID, number, TransactionOperationStart, data, commit)
+	if err != nil {
+		return Transaction{}, Signature{}, err
+	}
+
+	tx := &Transaction{
+		Number:            number,
+		ClientID:          clientID,
+		State:             TransactionActive,
+		StartedAt:         signature.CreatedAt,
+		UpdatedAt:         signature.CreatedAt,
+		SignatureCounters: []int{signature.Counter},
+	}
+	if sd.transactions == nil {
+		sd.transactions = map[int]*Transaction{}
+	}
+	sd.transactions[number] = tx
+	sd.transactionCounter = number
+	return tx.copy(), signature, nil
+}
+
+// UpdateTransaction signs a change of an active transaction on behalf of the client that started it. If finish is
+// set, the transaction is closed and cannot be changed any more.
+func (sd *SignatureDevice) UpdateTransaction(ctx context.Context, clientID string, number int, data string, finish bool, commit CommitFunc) (_ Transaction, _ Signature, err error) {
+	ctx, span := sd.startSpan(ctx, "SignatureDevice.UpdateTransaction", attribute.Int("transaction_number", number))
+	defer endSpan(span, &err)
+
+	if err := sd.lockForSigning(ctx); err != nil {
+		return Transaction{}, Signature{}, err
+	}
+	defer sd.mu.Unlock()
+
+	if err := sd.authorize(clientID, SigningModeTransaction); err != nil {
+		return Transaction{}, Signature{}, err
+	}
+	tx, ok := sd.transactions[number]
+	if !ok {
+		return Transaction{}, Signature{}, fmt.Errorf("SignatureDevice UpdateTransaction | id: %s | number: %d | %w", sd.ID, number, ErrTransactionNotFound)
+	}
+	if tx.ClientID != clientID {
+		return Transaction{}, Signature{}, fmt.Errorf("SignatureDevice UpdateTransaction | id: %s | number: %d | client: %q | %w", sd.ID, number, clientID, ErrTransactionClient)
+	}
+	if tx.State == TransactionFinished {
+		return Transaction{}, Signature{}, fmt.Errorf("SignatureDevice UpdateTransaction | id: %s | number: %d | %w", sd.ID, number, ErrTransactionFinished)
+	}
+
+	operation := TransactionOperationUpdate
+	if finish {
+		operation = TransactionOperationFinish
+	}
+	signature, err := sd.signTransaction(ctx, clientID, number, operation, data, commit)
+	if err != nil {
+		return Transaction{}, Signature{}, err
+	}
+
+	tx.UpdatedAt = signature.CreatedAt
+	tx.SignatureCounters = append(tx.SignatureCounters, signature.Counter)
+	if finish {
+		tx.State = TransactionFinished
+		finishedAt := signature.CreatedAt
+		tx.FinishedAt = &finishedAt
+	}
+	return tx.copy(), signature, nil
+}
+
+// Transaction returns the transaction with the given number.
+func (sd *SignatureDevice) Transaction(number int) (Transaction, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	tx, ok := sd.transactions[number]
+	if !ok {
+		return Transaction{}, fmt.Errorf("SignatureDevice Transaction | id: %s | number: %d | %w", sd.ID, number, ErrTransactionNotFound)
+	}
+	return tx.copy(), nil
+}
+
+// Transactions returns the transactions of the device ordered by number. If activeOnly is set, only
+// transactions that have not been finished yet are returned.
+func (sd *SignatureDevice) Transactions(activeOnly bool) []Transaction {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	transactions := make([]Transaction, 0, len(sd.transactions))
+	for _, tx := range sd.transactions {
+		if activeOnly && tx.State != TransactionActive {
+			continue
+		}
+		transactions = append(transactions, tx.copy())
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].Number < transactions[j].Number
+	})
+	return transactions
+}
+
+// signTransaction signs and commits a transaction state change. The signed data is
+// <operation>:<transaction_number>:<data>. The caller has to hold sd.mu.
+func (sd *SignatureDevice) signTransaction(ctx context.Context, clientID string, number int, operation string, data string, commit CommitFunc) (Signature, error) {
+	dataToBeSigned := fmt.Sprintf("%s:%d:%s", operation, number, data)
+	signature, err := sd.sign(ctx, clientID, SigningModeTransaction, dataToBeSigned, sd.signatureCounter, sd.lastSignature)
+	if err != nil {
+		return Signature{}, err
+	}
+	signature.TransactionNumber = number
+	if err := sd.commit(ctx, []Signature{signature}, commit); err != nil {
+		return Signature{}, err
+	}
+	return signature, nil
+}
+
+// copy returns a Transaction that does not share memory with the device state.
+func (t *Transaction) copy() Transaction {
+	tx := *t
+	tx.SignatureCounters = append([]int(nil), t.SignatureCounters...)
+	if t.FinishedAt != nil {
+		finishedAt := *t.FinishedAt
+		tx.FinishedAt = &finishedAt
+	}
+	return tx
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionLifecycle(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+
+	var ledger []Signature
+	commit := func(_ context.Context, signatures []Signature) error {
+		ledger = append(ledger, signatures...)
+		return nil
+	}
+
+	tx, signature, err := sd.StartTransaction(context.Background(), testClientID, "basket", commit)
+	require.Nil(t, err)
+	assert.Equal(t, 1, tx.Number)
+	assert.Equal(t, TransactionActive, tx.State)
+	assert.Equal(t, 1, signature.TransactionNumber)
+	assert.Equal(t, SigningModeTransaction, signature.Mode)
+	assert.Contains(t, signature.SignedData, ":StartTransaction:1:basket,")
+
+	_, err = sd.Sign(context.Background(), testClientID, "interleaved", commit)
+	require.Nil(t, err)
+
+	tx, signature, err = sd.UpdateTransaction(context.Background(), testClientID, 1, "item", false, commit)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionActive, tx.State)
+	assert.Contains(t, signature.SignedData, ":UpdateTransaction:1:item,")
+
+	tx, signature, err = sd.UpdateTransaction(context.Background(), testClientID, 1, "paid", true, commit)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionFinished, tx.State)
+	assert.NotNil(t, tx.FinishedAt)
+	assert.Contains(t, signature.SignedData, ":FinishTransaction:1:paid,")
+	assert.Equal(t, []int{0, 2, 3}, tx.SignatureCounters)
+
+	require.Equal(t, 4, len(ledger))
+	for i, entry := range ledger {
+		assert.Equal(t, i, entry.Counter, "transaction signatures are part of the device chain")
+	}
+
+	_, _, err = sd.UpdateTransaction(context.Background(), testClientID, 1, "late", false, commit)
+	assert.True(t, errors.Is(err, ErrTransactionFinished))
+	_, _, err = sd.UpdateTransaction(context.Background(), testClientID, 2, "unknown", false, commit)
+	assert.True(t, errors.Is(err, ErrTransactionNotFound))
+	assert.Equal(t, 4, len(ledger), "failed changes are not signed")
+}
+
+func TestTransactionFailedCommit(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignatureRSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+
+	_, _, err = sd.StartTransaction(context.Background(), testClientID, "basket", func(_ context.Context, signatures []Signature) error {
+		return fmt.Errorf("store unavailable")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(sd.Transactions(false)), "transaction must not be opened without commit")
+	assert.Equal(t, 0, sd.signatureCounter)
+
+	tx, _, err := sd.StartTransaction(context.Background(), testClientID, "basket", nil)
+	require.Nil(t, err)
+	assert.Equal(t, 1, tx.Number, "failed starts do not consume transaction numbers")
+}
+
+func TestTransactions(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+	for i := 0; i < 3; i++ {
+		_, _, err := sd.StartTransaction(context.Background(), testClientID, "", nil)
+		require.Nil(t, err)
+	}
+	_, _, err = sd.UpdateTransaction(context.Background(), testClientID, 2, "", true, nil)
+	require.Nil(t, err)
+
+	all := sd.Transactions(false)
+	require.Equal(t, 3, len(all))
+	for i, tx := range all {
+		assert.Equal(t, i+1, tx.Number)
+	}
+
+	active := sd.Transactions(true)
+	require.Equal(t, 2, len(active))
+	assert.Equal(t, 1, active[0].Number)
+	assert.Equal(t, 3, active[1].Number)
+}
+
+func TestTransactionClients(t *testing.T) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+	require.Nil(t, sd.RegisterClient("register-2"))
+
+	first, _, err := sd.StartTransaction(context.Background(), testClientID, "basket", nil)
+	require.Nil(t, err)
+	second, _, err := sd.StartTransaction(context.Background(), "register-2", "basket", nil)
+	require.Nil(t, err)
+
+	_, _, err = sd.UpdateTransaction(context.Background(), "register-2", first.Number, "item", false, nil)
+	assert.ErrorIs(t, err, ErrTransactionClient)
+	_, _, err = sd.UpdateTransaction(context.Background(), testClientID, second.Number, "paid", true, nil)
+	assert.ErrorIs(t, err, ErrTransactionClient)
+	assert.Equal(t, 2, sd.signatureCounter, "rejected changes are not signed")
+
+	tx, _, err := sd.UpdateTransaction(context.Background(), testClientID, first.Number, "paid", true, nil)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionFinished, tx.State)
+	tx, _, err = sd.UpdateTransaction(context.Background(), "register-2", second.Number, "item", false, nil)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionActive, tx.State)
+	assert.Equal(t, "register-2", tx.ClientID)
+}
+
+func TestTransactionIsTimedOut(t *testing.T) {
+	now := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	tx := Transaction{State: TransactionActive, UpdatedAt: now.Add(-time.Hour)}
+
+	assert.True(t, tx.IsTimedOut(now, 30*time.Minute))
+	assert.False(t, tx.IsTimedOut(now, 2*time.Hour))
+
+	tx.State = TransactionFinished
+	assert.False(t, tx.IsTimedOut(now, 30*time.Minute), "finished transactions never time out")
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/google/uuid"
+)
+
+// ChainViolation describes a signature that breaks the rules of the signature chain.
+type ChainViolation struct {
+	Counter int    `json:"counter"`
+	Reason  string `json:"reason"`
+}
+
+func (v ChainViolation) String() string {
+	return fmt.Sprintf("counter %d: %s", v.Counter, v.Reason)
+}
+
+// VerifyChain checks signatures ordered by counter against the rules of the signature chain: counters increase by
+// exactly one, the secured data (read in the format of the signature) contains the counter, client ID and signing mode
+// if the format records them, and the previous signature (base64 encoded device ID for counter 0), and every signature
+// verifies with the public key. Timestamp tokens are checked against the signature value, but not whether their
+// authority is trusted. If the first signature does not have counter 0, its predecessor is unknown and not checked.
+func VerifyChain(deviceID uuid.UUID, verifier crypto.Verifier, signatures []Signature) []ChainViolation {
+	violations := []ChainViolation{}
+	violate := func(counter int, format string, args ...interface{}) {
+		violations = append(violations, ChainViolation{Counter: counter, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	for i, signature := range signatures {
+		if signature.DeviceID != deviceID {
+			violate(signature.Counter, "signed by device %s", signature.DeviceID)
+		}
+		if i > 0 && signature.Counter != signatures[i-1].Counter+1 {
+			violate(signature.Counter, "counter does not follow %d", signatures[i-1].Counter)
+		}
+
+		var lastSignature string
+		switch {
+		case signature.Counter == 0:
+			lastSignature = base64.StdEncoding.EncodeToString([]byte(deviceID.String()))
+		case i > 0:
+			lastSignature = signatures[i-1].Signature
+		}
+		securedData, err := ParseSecuredData(signature.Format, signature.SignedData)
+		switch {
+		case err != nil:
+			violate(signature.Counter, "signed data is not in format %s", formatOrDefault(signature.Format))
+		case securedData.Counter != signature.Counter:
+			violate(signature.Counter, "signed data does not contain counter %d", signature.Counter)
+		case signature.Format.RecordsMetadata() && securedData.ClientID != signature.ClientID:
+			violate(signature.Counter, "signed data does not contain client %q", signature.ClientID)
+		case signature.Format.RecordsMetadata() && securedData.Mode != signature.Mode:
+			violate(signature.Counter, "signed data is not signed in mode %q", signature.Mode)
+		case lastSignature != "" && securedData.LastSignature != lastSignature:
+			violate(signature.Counter, "signed data is not chained to the previous signature")
+		}
+
+		rawSig, err := base64.StdEncoding.DecodeString(signature.Signature)
+		if err != nil {
+			violate(signature.Counter, "signature is not base64 encoded")
+			continue
+		}
+		if !verifier.Verify([]byte(signature.SignedData), rawSig) {
+			violate(signature.Counter, "invalid signature")
+		}
+		if len(signature.Timestamp) > 0 {
+			if _, err := tsa.Verify(signature.Timestamp, rawSig); err != nil {
+				violate(signature.Counter, "invalid timestamp")
+			}
+		}
+	}
+	return violations
+}
+
+// formatOrDefault returns the format of a signature, which is v0 for signatures without one.
+func formatOrDefault(format SecuredDataFormat) SecuredDataFormat {
+	if format == "" {
+		return SecuredDataV0
+	}
+	return format
+}
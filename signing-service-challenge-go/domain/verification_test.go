@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signChain creates n chained signatures with a new device and returns them with a verifier for the device key.
+func signChain(t *testing.T, n int) (*SignatureDevice, crypto.Verifier, []Signature) {
+	sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA)
+	require.Nil(t, err)
+	require.Nil(t, sd.RegisterClient(testClientID))
+	data := make([]string, n)
+	for i := range data {
+		data[i] = "data"
+	}
+	signatures, err := sd.SignBatch(context.Background(), testClientID, data, nil)
+	require.Nil(t, err)
+
+	publicKey, err := sd.PublicKey()
+	require.Nil(t, err)
+	verifier, _, err := crypto.NewVerifier(publicKey)
+	require.Nil(t, err)
+	return sd, verifier, signatures
+}
+
+func TestVerifyChain(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		sd, verifier, signatures := signChain(t, 3)
+		assert.Empty(t, VerifyChain(sd.ID, verifier, signatures))
+	})
+	t.Run("partial chain", func(t *testing.T) {
+		sd, verifier, signatures := signChain(t, 4)
+		assert.Empty(t, VerifyChain(sd.ID, verifier, signatures[2:]))
+	})
+	t.Run("missing signature", func(t *testing.T) {
+		sd, verifier, signatures := signChain(t, 3)
+		violations := VerifyChain(sd.ID, verifier, []Signature{signatures[0], signatures[2]})
+		require.Len(t, violations, 2)
+		assert.Equal(t, 2, violations[0].Counter)
+		assert.Contains(t, violations[0].Reason, "counter")
+		assert.Contains(t, violations[1].Reason, "chained")
+	})
+	t.Run("tampered data", func(t *testing.T) {
+		sd, verifier, signatures := signChain(t, 2)
+		signatures[1].SignedData = signatures[1].SignedData + "x"
+		violations := VerifyChain(sd.ID, verifier, signatures)
+		require.NotEmpty(t, violations)
+		assert.Equal(t, 1, violations[0].Counter)
+	})
+	t.Run("tampered signature", func(t *testing.T) {
+		sd, verifier, signatures := signChain(t, 2)
+		signatures[0].Signature = signatures[1].Signature
+		violations := VerifyChain(sd.ID, verifier, signatures)
+		assert.Contains(t, violations, ChainViolation{Counter: 0, Reason: "invalid signature"})
+	})
+	t.Run("v1", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV1))
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		signatures, err := sd.SignBatch(context.Background(), testClientID, []string{"a_", "_b", ""}, nil)
+		require.Nil(t, err)
+		publicKey, err := sd.PublicKey()
+		require.Nil(t, err)
+		verifier, _, err := crypto.NewVerifier(publicKey)
+		require.Nil(t, err)
+		assert.Empty(t, VerifyChain(sd.ID, verifier, signatures))
+
+		signatures[1].Format = SecuredDataV0
+		assert.Equal(t, []ChainViolation{{Counter: 1, Reason: "signed data is not in format v0"}}, VerifyChain(sd.ID, verifier, signatures))
+	})
+	t.Run("without format", func(t *testing.T) {
+		// ledger entries written before formats were recorded
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV0))
+		require.Nil(t, err)
+		signatures, err := sd.SignBatch(context.Background(), "", []string{"a", "b"}, nil)
+		require.Nil(t, err)
+		publicKey, err := sd.PublicKey()
+		require.Nil(t, err)
+		verifier, _, err := crypto.NewVerifier(publicKey)
+		require.Nil(t, err)
+		for i := range signatures {
+			signatures[i].Format = ""
+		}
+		assert.Empty(t, VerifyChain(sd.ID, verifier, signatures))
+	})
+	t.Run("wrong client or mode", func(t *testing.T) {
+		sd, err := NewSignatureDevice(uuid.New(), "myDev", crypto.SignautreECDSA, WithSecuredDataFormat(SecuredDataV1))
+		require.Nil(t, err)
+		require.Nil(t, sd.RegisterClient(testClientID))
+		signature, err := sd.Sign(context.Background(), testClientID, "data", nil)
+		require.Nil(t, err)
+		publicKey, err := sd.PublicKey()
+		require.Nil(t, err)
+		verifier, _, err := crypto.NewVerifier(publicKey)
+		require.Nil(t, err)
+
+		tampered := signature
+		tampered.ClientID = "other"
+		assert.Equal(t, []ChainViolation{{Counter: 0, Reason: `signed data does not contain client "other"`}},
+			VerifyChain(sd.ID, verifier, []Signature{tampered}))
+
+		tampered = signature
+		tampered.Mode = SigningModeDigest
+		assert.Equal(t, []ChainViolation{{Counter: 0, Reason: `signed data is not signed in mode "DIGEST"`}},
+			VerifyChain(sd.ID, verifier, []Signature{tampered}))
+	})
+	t.Run("wrong device", func(t *testing.T) {
+		_, verifier, signatures := signChain(t, 1)
+		assert.NotEmpty(t, VerifyChain(uuid.New(), verifier, signatures))
+	})
+}
+
+// randomData returns short payloads that favour the separator, line breaks and invalid UTF-8.
+func randomData(r *rand.Rand) string {
+	alphabet := []string{"_", "a", "Z", "0", "\n", "\r", " ", "\xff", "\x00", "ü", "=="}
+	var b strings.Builder
+	for n := r.Intn(12); n > 0; n-- {
+		b.WriteString(alphabet[r.Intn(len(alphabet))])
+	}
+	return b.String()
+}
+
+// TestChainProperties runs random sequences of concurrent sign calls, some of them with failing commits, and
+// checks the invariants of the ledger built from the committed signatures.
+func TestChainProperties(t *testing.T) {
+	errCommit := errors.New("commit failed")
+
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			r := rand.New(rand.NewSource(seed))
+			algorithm := crypto.SignautreECDSA
+			if r.Intn(2) == 0 {
+				algorithm = crypto.SignatureRSA
+			}
+			sd, err := NewSignatureDevice(uuid.New(), "property", algorithm)
+			require.Nil(t, err)
+			require.Nil(t, sd.RegisterClient(testClientID))
+
+			var mu sync.Mutex
+			var ledger []Signature
+			var returned []Signature
+
+			var wg sync.WaitGroup
+			for worker := 2 + r.Intn(6); worker > 0; worker-- {
+				// math/rand sources are not safe for concurrent use
+				wr := rand.New(rand.NewSource(r.Int63()))
+				operations := 1 + r.Intn(10)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < operations; i++ {
+						fail := wr.Intn(5) == 0
+						commit := func(_ context.Context, signatures []Signature) error {
+							if fail {
+								return errCommit
+							}
+							mu.Lock()
+							defer mu.Unlock()
+							ledger = append(ledger, signatures...)
+							return nil
+						}
+
+						var signatures []Signature
+						var err error
+						switch wr.Intn(4) {
+						case 0:
+							var signature Signature
+							signature, err = sd.Sign(context.Background(), testClientID, randomData(wr), commit)
+							signatures = []Signature{signature}
+						case 1:
+							data := make([]string, 1+wr.Intn(4))
+							for j := range data {
+								data[j] = randomData(wr)
+							}
+							signatures, err = sd.SignBatch(context.Background(), testClientID, data, commit)
+						case 2:
+							digest := make([]byte, 32)
+							wr.Read(digest)
+							var signature Signature
+							signature, err = sd.SignDigest(context.Background(), testClientID, crypto.HashSHA256, digest, commit)
+							signatures = []Signature{signature}
+						case 3:
+							var signature Signature
+							signature, _, err = sd.SignCOSE(context.Background(), testClientID, randomData(wr), commit)
+							signatures = []Signature{signature}
+						}
+
+						if fail {
+							assert.ErrorIs(t, err, errCommit)
+							continue
+						}
+						if assert.Nil(t, err) {
+							mu.Lock()
+							returned = append(returned, signatures...)
+							mu.Unlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			// counters are gapless and the ledger is committed in counter order
+			for i, signature := range ledger {
+				require.Equal(t, i, signature.Counter)
+			}
+			assert.Equal(t, len(ledger), sd.Info().SignatureCounter)
+
+			// every returned signature is the committed one and failed commits left no trace
+			require.Len(t, returned, len(ledger))
+			for _, signature := range returned {
+				assert.Equal(t, ledger[signature.Counter], signature)
+			}
+
+			publicKey, err := sd.PublicKey()
+			require.Nil(t, err)
+			verifier, _, err := crypto.NewVerifier(publicKey)
+			require.Nil(t, err)
+			assert.Empty(t, VerifyChain(sd.ID, verifier, ledger))
+		})
+	}
+}
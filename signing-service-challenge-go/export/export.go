@@ -0,0 +1,245 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+)
+
+// File names inside an export archive.
+const (
+	PublicKeyFile    = "public_key.pem"
+	ManifestFile     = "manifest.json"
+	ManifestCSVFile  = "manifest.csv"
+	SignatureFileDir = "signatures/"
+)
+
+// Device describes the exported signature device.
+type Device struct {
+	ID        uuid.UUID                 `json:"id"`
+	Label     string                    `json:"label"`
+	Algorithm crypto.SignatureAlgorithm `json:"signature_algorithm"`
+}
+
+// Filter restricts an export to a time and/or counter range. All bounds are inclusive and optional.
+type Filter struct {
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	CounterFrom *int       `json:"counter_from,omitempty"`
+	CounterTo   *int       `json:"counter_to,omitempty"`
+}
+
+// Match reports whether the signature is within the bounds of the filter.
+func (f Filter) Match(signature domain.Signature) bool {
+	if f.From != nil && signature.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && signature.CreatedAt.After(*f.To) {
+		return false
+	}
+	if f.CounterFrom != nil && signature.Counter < *f.CounterFrom {
+		return false
+	}
+	if f.CounterTo != nil && signature.Counter > *f.CounterTo {
+		return false
+	}
+	return true
+}
+
+// Manifest describes the content of an export archive.
+type Manifest struct {
+	Device     Device     `json:"device"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Filter     Filter     `json:"filter"`
+	PublicKey  FileEntry  `json:"public_key"`
+	Entries    []LogEntry `json:"entries"`
+}
+
+// FileEntry references a file of the archive together with its SHA-256 checksum.
+type FileEntry struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+}
+
+// LogEntry references the log file of a single signature.
+type LogEntry struct {
+	FileEntry
+	Counter   int       `json:"counter"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Write streams a TAR archive with the public key of the device, a JSON and a CSV manifest and one JSON log file
+// per signature matching the filter. Signatures have to be ordered by counter.
+func Write(w io.Writer, device Device, publicKey []byte, signatures []domain.Signature, filter Filter, exportedAt time.Time) error {
+	tw := tar.NewWriter(w)
+	manifest := Manifest{
+		Device:     device,
+		ExportedAt: exportedAt.UTC(),
+		Filter:     filter,
+		PublicKey:  FileEntry{File: PublicKeyFile, SHA256: checksum(publicKey)},
+		Entries:    []LogEntry{},
+	}
+
+	if err := writeFile(tw, PublicKeyFile, publicKey, exportedAt); err != nil {
+		return fmt.Errorf("export.Write | %w", err)
+	}
+	for _, signature := range signatures {
+		if !filter.Match(signature) {
+			continue
+		}
+		content, err := json.MarshalIndent(signature, "", "  ")
+		if err != nil {
+			return fmt.Errorf("export.Write signature %d | %w", signature.Counter, err)
+		}
+		entry := LogEntry{
+			FileEntry: FileEntry{File: SignatureFile(signature.Counter), SHA256: checksum(content)},
+			Counter:   signature.Counter,
+			CreatedAt: signature.CreatedAt,
+		}
+		if err := writeFile(tw, entry.File, content, signature.CreatedAt); err != nil {
+			return fmt.Errorf("export.Write | %w", err)
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("export.Write manifest | %w", err)
+	}
+	if err := writeFile(tw, ManifestFile, content, exportedAt); err != nil {
+		return fmt.Errorf("export.Write | %w", err)
+	}
+	content, err = manifestCSV(manifest)
+	if err != nil {
+		return fmt.Errorf("export.Write | %w", err)
+	}
+	if err := writeFile(tw, ManifestCSVFile, content, exportedAt); err != nil {
+		return fmt.Errorf("export.Write | %w", err)
+	}
+	return tw.Close()
+}
+
+// SignatureFile returns the archive path of the log file of a signature.
+func SignatureFile(counter int) string {
+	return fmt.Sprintf("%s%010d.json", SignatureFileDir, counter)
+}
+
+// manifestCSV renders the log entries of the manifest as CSV with a header line.
+func manifestCSV(manifest Manifest) ([]byte, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	records := [][]string{{"counter", "created_at", "file", "sha256"}}
+	for _, entry := range manifest.Entries {
+		records = append(records, []string{
+			strconv.Itoa(entry.Counter),
+			entry.CreatedAt.Format(time.RFC3339Nano),
+			entry.File,
+			entry.SHA256,
+		})
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return nil, fmt.Errorf("manifestCSV | %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+		Format:  tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("writeFile header %s | %w", name, err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		return fmt.Errorf("writeFile %s | %w", name, err)
+	}
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Archive is the parsed content of an export archive.
+type Archive struct {
+	Manifest   Manifest
+	PublicKey  []byte
+	Signatures []domain.Signature
+}
+
+// Read parses an export archive created by Write. All files referenced by the manifest have to be present and
+// match their checksums; signatures are returned in manifest order.
+func Read(r io.Reader) (*Archive, error) {
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("export.Read | %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("export.Read %s | %w", header.Name, err)
+		}
+		files[header.Name] = content
+	}
+
+	archive := &Archive{}
+	content, ok := files[ManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("export.Read | %s missing", ManifestFile)
+	}
+	if err := json.Unmarshal(content, &archive.Manifest); err != nil {
+		return nil, fmt.Errorf("export.Read %s | %w", ManifestFile, err)
+	}
+
+	publicKey, err := readEntry(files, archive.Manifest.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("export.Read | %w", err)
+	}
+	archive.PublicKey = publicKey
+
+	for _, entry := range archive.Manifest.Entries {
+		content, err := readEntry(files, entry.FileEntry)
+		if err != nil {
+			return nil, fmt.Errorf("export.Read | %w", err)
+		}
+		signature := domain.Signature{}
+		if err := json.Unmarshal(content, &signature); err != nil {
+			return nil, fmt.Errorf("export.Read %s | %w", entry.File, err)
+		}
+		archive.Signatures = append(archive.Signatures, signature)
+	}
+	return archive, nil
+}
+
+// readEntry returns the content of a file referenced by the manifest after checking its checksum.
+func readEntry(files map[string][]byte, entry FileEntry) ([]byte, error) {
+	content, ok := files[entry.File]
+	if !ok {
+		return nil, fmt.Errorf("readEntry | %s missing", entry.File)
+	}
+	if checksum(content) != entry.SHA256 {
+		return nil, fmt.Errorf("readEntry | %s checksum mismatch", entry.File)
+	}
+	return content, nil
+}
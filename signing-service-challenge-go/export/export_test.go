@@ -0,0 +1,152 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatch(t *testing.T) {
+	start := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	from, to := 2, 5
+	signature := domain.Signature{Counter: 3, CreatedAt: start.Add(time.Minute)}
+
+	assert.True(t, Filter{}.Match(signature))
+	assert.True(t, Filter{From: &start, To: &end, CounterFrom: &from, CounterTo: &to}.Match(signature))
+	assert.True(t, Filter{CounterFrom: &signature.Counter, CounterTo: &signature.Counter}.Match(signature), "bounds are inclusive")
+	assert.False(t, Filter{From: &end}.Match(signature))
+	assert.False(t, Filter{To: &start}.Match(signature))
+	assert.False(t, Filter{CounterFrom: &to}.Match(signature))
+	assert.False(t, Filter{CounterTo: &from}.Match(signature))
+}
+
+func TestWrite(t *testing.T) {
+	device := Device{ID: uuid.New(), Label: "myDev", Algorithm: crypto.SignautreECDSA}
+	publicKey := []byte("-----BEGIN PUBLIC_KEY-----\n-----END PUBLIC_KEY-----\n")
+	start := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	signatures := make([]domain.Signature, 5)
+	for i := range signatures {
+		signatures[i] = domain.Signature{
+			DeviceID:   device.ID,
+			ClientID:   "register-1",
+			Counter:    i,
+			SignedData: "data",
+			Signature:  "c2lnbmF0dXJl",
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	from, to := 1, 3
+
+	var buf bytes.Buffer
+	err := Write(&buf, device, publicKey, signatures, Filter{CounterFrom: &from, CounterTo: &to}, start.Add(time.Hour))
+	require.Nil(t, err)
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		content, err := io.ReadAll(tr)
+		require.Nil(t, err)
+		files[header.Name] = content
+	}
+
+	assert.Equal(t, publicKey, files[PublicKeyFile])
+	assert.Equal(t, 6, len(files), "public key, two manifests and three log entries expected")
+
+	manifest := Manifest{}
+	require.Nil(t, json.Unmarshal(files[ManifestFile], &manifest))
+	assert.Equal(t, device, manifest.Device)
+	assert.Equal(t, checksum(publicKey), manifest.PublicKey.SHA256)
+	require.Equal(t, 3, len(manifest.Entries))
+	for i, entry := range manifest.Entries {
+		assert.Equal(t, i+1, entry.Counter)
+		content, ok := files[entry.File]
+		require.True(t, ok, "log file %s missing", entry.File)
+		assert.Equal(t, checksum(content), entry.SHA256)
+
+		signature := domain.Signature{}
+		require.Nil(t, json.Unmarshal(content, &signature))
+		assert.Equal(t, signatures[i+1], signature)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(files[ManifestCSVFile])).ReadAll()
+	require.Nil(t, err)
+	require.Equal(t, 4, len(records))
+	assert.Equal(t, []string{"counter", "created_at", "file", "sha256"}, records[0])
+	assert.Equal(t, SignatureFile(1), records[1][2])
+}
+
+func TestSignatureFile(t *testing.T) {
+	assert.Equal(t, "signatures/0000000042.json", SignatureFile(42))
+}
+
+func TestRead(t *testing.T) {
+	device := Device{ID: uuid.New(), Label: "myDev", Algorithm: crypto.SignautreECDSA}
+	publicKey := []byte("-----BEGIN PUBLIC_KEY-----\n-----END PUBLIC_KEY-----\n")
+	start := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	signatures := make([]domain.Signature, 3)
+	for i := range signatures {
+		signatures[i] = domain.Signature{
+			DeviceID:   device.ID,
+			ClientID:   "register-1",
+			Counter:    i,
+			SignedData: "data",
+			Signature:  "c2lnbmF0dXJl",
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, device, publicKey, signatures, Filter{}, start.Add(time.Hour)))
+
+	t.Run("default", func(t *testing.T) {
+		archive, err := Read(bytes.NewReader(buf.Bytes()))
+		require.Nil(t, err)
+		assert.Equal(t, device, archive.Manifest.Device)
+		assert.Equal(t, publicKey, archive.PublicKey)
+		assert.Equal(t, signatures, archive.Signatures)
+	})
+	t.Run("tampered file", func(t *testing.T) {
+		var tampered bytes.Buffer
+		tw := tar.NewWriter(&tampered)
+		tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(t, err)
+			content, err := io.ReadAll(tr)
+			require.Nil(t, err)
+			if header.Name == SignatureFile(1) {
+				content = bytes.Replace(content, []byte("data"), []byte("atad"), 1)
+			}
+			header.Size = int64(len(content))
+			require.Nil(t, tw.WriteHeader(header))
+			_, err = tw.Write(content)
+			require.Nil(t, err)
+		}
+		require.Nil(t, tw.Close())
+
+		_, err := Read(&tampered)
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+	t.Run("no archive", func(t *testing.T) {
+		_, err := Read(bytes.NewReader([]byte("no archive")))
+		assert.NotNil(t, err)
+	})
+}
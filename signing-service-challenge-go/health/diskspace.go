@@ -0,0 +1,27 @@
+package health
+
+import (
+	"context"
+	"fmt"
+)
+
+// DiskSpace checks that the file system of path has at least minFree bytes available, e.g. for a ledger on disk.
+// It warns below twice the minimum and fails below the minimum.
+func DiskSpace(path string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) Result {
+		free, err := freeBytes(path)
+		if err != nil {
+			return Result{ComponentID: path, Status: StatusFail, Output: err.Error()}
+		}
+		result := Result{ComponentID: path, Status: StatusPass, ObservedValue: free, ObservedUnit: "bytes"}
+		switch {
+		case free < minFree:
+			result.Status = StatusFail
+			result.Output = fmt.Sprintf("less than %d bytes available", minFree)
+		case free < 2*minFree:
+			result.Status = StatusWarn
+			result.Output = fmt.Sprintf("less than %d bytes available", 2*minFree)
+		}
+		return result
+	}
+}
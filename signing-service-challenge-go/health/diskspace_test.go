@@ -0,0 +1,40 @@
+//go:build linux || darwin || freebsd
+
+package health
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("pass", func(t *testing.T) {
+		result := DiskSpace(dir, 1)(context.Background())
+		assert.Equal(t, StatusPass, result.Status)
+		assert.Equal(t, dir, result.ComponentID)
+		assert.Equal(t, "bytes", result.ObservedUnit)
+		assert.NotZero(t, result.ObservedValue)
+	})
+	t.Run("warn", func(t *testing.T) {
+		free, err := freeBytes(dir)
+		if err != nil || free < 2 {
+			t.Skip("free space unknown")
+		}
+		result := DiskSpace(dir, free/2+free/4)(context.Background())
+		assert.Equal(t, StatusWarn, result.Status)
+	})
+	t.Run("fail", func(t *testing.T) {
+		result := DiskSpace(dir, ^uint64(0))(context.Background())
+		assert.Equal(t, StatusFail, result.Status)
+		assert.NotEmpty(t, result.Output)
+	})
+	t.Run("missing path", func(t *testing.T) {
+		result := DiskSpace(filepath.Join(dir, "missing"), 1)(context.Background())
+		assert.Equal(t, StatusFail, result.Status)
+	})
+}
@@ -0,0 +1,215 @@
+// Package health runs pluggable checks and reports them in the format of the IETF draft "Health Check Response
+// Format for HTTP APIs" (draft-inadarei-api-health-check): an overall status plus the details of every check,
+// keyed by "<component>:<measurement>".
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+)
+
+// ContentType is the media type of health responses.
+const ContentType = "application/health+json"
+
+// DefaultTimeout limits the duration of a single check.
+const DefaultTimeout = 2 * time.Second
+
+// Status is the health of the service or a single check.
+type Status string
+
+const (
+	// StatusPass is healthy.
+	StatusPass Status = "pass"
+	// StatusWarn is healthy with concerns.
+	StatusWarn Status = "warn"
+	// StatusFail is unhealthy.
+	StatusFail Status = "fail"
+)
+
+// severity orders the statuses, the overall status is the most severe of all checks.
+func (s Status) severity() int {
+	switch s {
+	case StatusPass:
+		return 0
+	case StatusWarn:
+		return 1
+	}
+	return 2
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	ComponentID   string      `json:"componentId,omitempty"`
+	ComponentType string      `json:"componentType,omitempty"`
+	ObservedValue interface{} `json:"observedValue,omitempty"`
+	ObservedUnit  string      `json:"observedUnit,omitempty"`
+	Status        Status      `json:"status"`
+	Time          time.Time   `json:"time"`
+	Output        string      `json:"output,omitempty"`
+}
+
+// CheckFunc checks a component. It has to return when ctx is done.
+type CheckFunc func(ctx context.Context) Result
+
+// Response is the health of the service.
+type Response struct {
+	Status      Status              `json:"status"`
+	Version     string              `json:"version,omitempty"`
+	ReleaseID   string              `json:"releaseId,omitempty"`
+	ServiceID   string              `json:"serviceId,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Checks      map[string][]Result `json:"checks,omitempty"`
+}
+
+// check is a registered CheckFunc.
+type check struct {
+	name          string
+	componentType string
+	run           CheckFunc
+}
+
+// Checker runs the registered checks. The fields describe the service in the response and must not be changed
+// while checks run.
+type Checker struct {
+	Version     string
+	ReleaseID   string
+	ServiceID   string
+	Description string
+	// Timeout limits every check; checks that do not finish in time fail.
+	Timeout time.Duration
+	// Clock provides the time of results that do not set one.
+	Clock clock.Clock
+
+	mu     sync.Mutex
+	checks []check
+}
+
+// NewChecker creates a Checker without checks. Without checks the service passes.
+func NewChecker(description string) *Checker {
+	return &Checker{
+		Description: description,
+		Timeout:     DefaultTimeout,
+		Clock:       clock.System,
+	}
+}
+
+// Register adds a check. The name is "<component>:<measurement>", e.g. "store:reachable"; componentType
+// classifies the component, e.g. "datastore" or "system".
+func (c *Checker) Register(name string, componentType string, run CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, componentType: componentType, run: run})
+}
+
+// Run runs all checks concurrently and aggregates their results.
+func (c *Checker) Run(ctx context.Context) Response {
+	c.mu.Lock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	response := Response{
+		Status:      StatusPass,
+		Version:     c.Version,
+		ReleaseID:   c.ReleaseID,
+		ServiceID:   c.ServiceID,
+		Description: c.Description,
+	}
+	if len(checks) > 0 {
+		response.Checks = map[string][]Result{}
+	}
+	for i, check := range checks {
+		result := results[i]
+		if result.Status.severity() > response.Status.severity() {
+			response.Status = result.Status
+		}
+		response.Checks[check.name] = append(response.Checks[check.name], result)
+	}
+	for _, results := range response.Checks {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].ComponentID < results[j].ComponentID })
+	}
+	return response
+}
+
+// runCheck runs a single check within the timeout and completes its result.
+func (c *Checker) runCheck(ctx context.Context, check check) Result {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan Result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- Result{Status: StatusFail, Output: fmt.Sprintf("check panicked: %v", r)}
+			}
+		}()
+		done <- check.run(ctx)
+	}()
+
+	var result Result
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = Result{Status: StatusFail, Output: fmt.Sprintf("check did not finish within %s", timeout)}
+	}
+	if result.Status == "" {
+		result.Status = StatusFail
+	}
+	if result.ComponentType == "" {
+		result.ComponentType = check.componentType
+	}
+	if result.Time.IsZero() {
+		result.Time = c.Clock.Now().UTC()
+	}
+	return result
+}
+
+// ServeHTTP runs the checks and answers with 200 for pass and warn and with 503 for fail.
+func (c *Checker) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	health := c.Run(request.Context())
+	code := http.StatusOK
+	if health.Status == StatusFail {
+		code = http.StatusServiceUnavailable
+	}
+	body, err := json.MarshalIndent(health, "", "  ")
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	response.Header().Set("Content-Type", ContentType)
+	response.Header().Set("Cache-Control", "no-store")
+	response.WriteHeader(code)
+	response.Write(body)
+}
+
+// Uptime passes and reports the time since start in seconds.
+func Uptime(c clock.Clock, start time.Time) CheckFunc {
+	return func(ctx context.Context) Result {
+		return Result{
+			Status:        StatusPass,
+			ObservedValue: c.Now().Sub(start).Seconds(),
+			ObservedUnit:  "s",
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func result(status Status) CheckFunc {
+	return func(ctx context.Context) Result {
+		return Result{Status: status}
+	}
+}
+
+func TestChecker(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("no checks", func(t *testing.T) {
+		response := NewChecker("empty").Run(context.Background())
+		assert.Equal(t, StatusPass, response.Status)
+		assert.Nil(t, response.Checks)
+	})
+	t.Run("aggregation", func(t *testing.T) {
+		for _, tt := range []struct {
+			statuses []Status
+			expected Status
+		}{
+			{[]Status{StatusPass, StatusPass}, StatusPass},
+			{[]Status{StatusPass, StatusWarn}, StatusWarn},
+			{[]Status{StatusWarn, StatusFail, StatusPass}, StatusFail},
+			{[]Status{""}, StatusFail},
+		} {
+			c := NewChecker("")
+			for _, status := range tt.statuses {
+				c.Register("component:measurement", "system", result(status))
+			}
+			response := c.Run(context.Background())
+			assert.Equal(t, tt.expected, response.Status, tt.statuses)
+			assert.Len(t, response.Checks["component:measurement"], len(tt.statuses))
+		}
+	})
+	t.Run("result details", func(t *testing.T) {
+		c := NewChecker("service")
+		c.Clock = clock.NewFake(now, 0)
+		c.Register("store:reachable", "datastore", result(StatusPass))
+		c.Register("pool:size", "component", func(ctx context.Context) Result {
+			return Result{ComponentType: "keys", Status: StatusWarn, ObservedValue: 3, ObservedUnit: "keys", Time: now.Add(-time.Minute)}
+		})
+
+		response := c.Run(context.Background())
+		assert.Equal(t, []Result{{ComponentType: "datastore", Status: StatusPass, Time: now}}, response.Checks["store:reachable"])
+		assert.Equal(t, []Result{{ComponentType: "keys", Status: StatusWarn, ObservedValue: 3, ObservedUnit: "keys", Time: now.Add(-time.Minute)}}, response.Checks["pool:size"])
+	})
+	t.Run("timeout", func(t *testing.T) {
+		c := NewChecker("")
+		c.Timeout = 10 * time.Millisecond
+		c.Register("slow:check", "system", func(ctx context.Context) Result {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			return Result{Status: StatusPass}
+		})
+		response := c.Run(context.Background())
+		assert.Equal(t, StatusFail, response.Status)
+		assert.Contains(t, response.Checks["slow:check"][0].Output, "did not finish")
+	})
+	t.Run("panic", func(t *testing.T) {
+		c := NewChecker("")
+		c.Register("broken:check", "system", func(ctx context.Context) Result { panic("broken") })
+		response := c.Run(context.Background())
+		assert.Equal(t, StatusFail, response.Status)
+		assert.Contains(t, response.Checks["broken:check"][0].Output, "broken")
+	})
+	t.Run("uptime", func(t *testing.T) {
+		c := NewChecker("")
+		c.Register("uptime", "system", Uptime(clock.NewFake(now.Add(90*time.Second), 0), now))
+		r := c.Run(context.Background()).Checks["uptime"][0]
+		assert.Equal(t, StatusPass, r.Status)
+		assert.Equal(t, float64(90), r.ObservedValue)
+		assert.Equal(t, "s", r.ObservedUnit)
+	})
+}
+
+func TestServeHTTP(t *testing.T) {
+	for _, tt := range []struct {
+		status Status
+		code   int
+	}{
+		{StatusPass, http.StatusOK},
+		{StatusWarn, http.StatusOK},
+		{StatusFail, http.StatusServiceUnavailable},
+	} {
+		t.Run(string(tt.status), func(t *testing.T) {
+			c := NewChecker("signing service")
+			c.Version = "v1.2.0"
+			c.ReleaseID = "0123abc"
+			c.Register("component:check", "system", result(tt.status))
+
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+			body := map[string]interface{}{}
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, string(tt.status), body["status"])
+			assert.Equal(t, "v1.2.0", body["version"])
+			assert.Equal(t, "0123abc", body["releaseId"])
+			assert.Equal(t, "signing service", body["description"])
+			assert.Contains(t, body["checks"], "component:check")
+		})
+	}
+}
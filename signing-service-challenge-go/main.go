@@ -1,20 +1,168 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
-)
-
-const (
-	ListenAddress = ":8080"
-	// TODO: add further configuration parameters here ...
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/buildinfo"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/config"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
-	server := api.NewServer(ListenAddress)
+	cfg, printConfig, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	server, closeServer, err := newServer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeServer()
 
-	if err := server.Run(); err != nil {
-		log.Fatal("Could not start server on ", ListenAddress)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Run(ctx); err != nil {
+		server.Logger.Error("Server failed", "address", cfg.Server.ListenAddress, "err", err)
+		closeServer()
+		os.Exit(1)
+	}
+}
+
+// newServer creates the server for the configuration. The returned function releases its resources.
+func newServer(cfg config.Config) (*api.Server, func(), error) {
+	level, err := cfg.Log.SlogLevel()
+	if err != nil {
+		return nil, nil, err
+	}
+	logger, err := api.NewLogger(os.Stderr, cfg.Log.Format, level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server := api.NewServer(cfg.Server.ListenAddress)
+	server.Logger = logger
+	server.LogPayloads = cfg.Log.Payloads
+	server.ReadHeaderTimeout = cfg.Server.ReadHeaderTimeout.Duration()
+	server.ReadTimeout = cfg.Server.ReadTimeout.Duration()
+	server.WriteTimeout = cfg.Server.WriteTimeout.Duration()
+	server.IdleTimeout = cfg.Server.IdleTimeout.Duration()
+	server.ShutdownTimeout = cfg.Server.ShutdownTimeout.Duration()
+	server.MaxBodyBytes = cfg.Limits.MaxBodyBytes
+	server.BulkConcurrency = cfg.Limits.BulkConcurrency
+	server.TransactionTimeout = cfg.Signing.TransactionTimeout.Duration()
+	for _, algorithm := range cfg.Signing.Algorithms {
+		server.Algorithms = append(server.Algorithms, crypto.SignatureAlgorithm(algorithm))
+	}
+	server.RequireAPIKey = cfg.Auth.Enabled
+	server.AdminKey = cfg.Auth.AdminKey
+	server.KeySizes = map[crypto.SignatureAlgorithm]int{
+		crypto.SignatureRSA:   cfg.Signing.RSAKeySize,
+		crypto.SignautreECDSA: cfg.Signing.ECDSAKeySize,
+	}
+
+	if cfg.TLS.CertFile != "" {
+		tlsConfig, reloader, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: %w", err)
+		}
+		server.TLSConfig = tlsConfig
+		server.Readiness.Register("tls:certificate", "component", reloader.Check)
+	}
+	if cfg.TLS.ClientIdentity == config.ClientIdentitySubject {
+		server.ClientIdentity = api.IdentitySubject
+	}
+	if cfg.Health.DiskPath != "" {
+		server.Readiness.Register("disk:free", "system", health.DiskSpace(cfg.Health.DiskPath, cfg.Health.DiskMinFree))
+	}
+
+	closeServer := func() {}
+	if cfg.Trace.Output != "" {
+		provider, closeProvider, err := newTracerProvider(cfg.Trace.Output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("trace: %w", err)
+		}
+		server.TracerProvider = provider
+		closeServer = closeProvider
+	}
+	return server, closeServer, nil
+}
+
+// newTracerProvider creates an OpenTelemetry tracer provider exporting spans as JSON to stdout or a file. The
+// returned function flushes pending spans and closes the file.
+func newTracerProvider(output string) (trace.TracerProvider, func(), error) {
+	w, closeOutput := io.Writer(os.Stdout), func() error { return nil }
+	if output != "stdout" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closeOutput = f, f.Close
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		closeOutput()
+		return nil, nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName("signing-service"),
+			semconv.ServiceVersion(buildinfo.Version),
+		)),
+	)
+	return provider, func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			log.Printf("trace: %v", err)
+		}
+		closeOutput()
+	}, nil
+}
+
+// newTLSConfig loads the server certificate, which is reloaded when its files change, and the CAs of client
+// certificates.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, *tlsconfig.Reloader, error) {
+	reloader, err := tlsconfig.NewReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	reloader.Interval = cfg.ReloadInterval.Duration()
+	clientAuth, err := tlsconfig.ParseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+	var clientCAs *x509.CertPool
+	if cfg.ClientCAFile != "" {
+		if clientCAs, err = tlsconfig.LoadCertPool(cfg.ClientCAFile); err != nil {
+			return nil, nil, err
+		}
 	}
+	return tlsconfig.ServerConfig(reloader, clientCAs, clientAuth), reloader, nil
 }
@@ -0,0 +1,314 @@
+// Package metrics collects counters and histograms and exposes them in the Prometheus text format, so the service
+// can be scraped without depending on a Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds in seconds of histograms for request and operation latencies.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds all metrics of a process and writes them in registration order.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+	names    map[string]struct{}
+}
+
+// family is a metric with all its label combinations.
+type family interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]struct{}{}}
+}
+
+// Counter registers a counter with the label names. Registering a name twice panics.
+func (r *Registry) Counter(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(name, help, labelNames)}
+	r.register(name, c)
+	return c
+}
+
+// Histogram registers a histogram with the bucket upper bounds and label names. Registering a name twice panics.
+func (r *Registry) Histogram(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{vec: newVec(name, help, labelNames), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.names[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = struct{}{}
+	r.families = append(r.families, f)
+}
+
+// WriteText writes all metrics in the Prometheus text format. Series of a metric are ordered by label values.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]family(nil), r.families...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (r *Registry) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", ContentType)
+	response.WriteHeader(http.StatusOK)
+	r.WriteText(response)
+}
+
+// vec is the label handling shared by all metric types.
+type vec struct {
+	name       string
+	help       string
+	labelNames []string
+}
+
+func newVec(name string, help string, labelNames []string) vec {
+	return vec{name: name, help: help, labelNames: labelNames}
+}
+
+// key joins label values to a map key; the values are checked against the label names.
+func (v vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (v vec) writeHeader(w *bufio.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, metricType)
+}
+
+// labels formats the label pairs of a series including the extra pairs, e.g. {route="x",le="0.1"}.
+func (v vec) labels(labelValues []string, extra ...string) string {
+	if len(labelValues) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labelValues)+len(extra)/2)
+	for i, value := range labelValues {
+		pairs = append(pairs, v.labelNames[i]+`="`+escapeLabel(value)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec
+	mu     sync.Mutex
+	series map[string]*Counter
+}
+
+// With returns the counter for the label values, in the order of the label names.
+func (c *CounterVec) With(labelValues ...string) *Counter {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.series == nil {
+		c.series = map[string]*Counter{}
+	}
+	counter, ok := c.series[key]
+	if !ok {
+		counter = &Counter{labelValues: append([]string(nil), labelValues...)}
+		c.series[key] = counter
+	}
+	return counter
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	counters := make([]*Counter, 0, len(c.series))
+	for _, counter := range c.series {
+		counters = append(counters, counter)
+	}
+	c.mu.Unlock()
+	sort.Slice(counters, func(i, j int) bool { return lessValues(counters[i].labelValues, counters[j].labelValues) })
+
+	for _, counter := range counters {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(counter.labelValues), formatFloat(counter.Value()))
+	}
+}
+
+// Counter is a single series of a CounterVec. It only goes up.
+type Counter struct {
+	labelValues []string
+	mu          sync.Mutex
+	value       float64
+}
+
+// Inc adds 1 to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds a non-negative value to the counter; negative values are ignored.
+func (c *Counter) Add(value float64) {
+	if value < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += value
+}
+
+// Value returns the current count.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	vec
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*Histogram
+}
+
+// With returns the histogram for the label values, in the order of the label names.
+func (h *HistogramVec) With(labelValues ...string) *Histogram {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.series == nil {
+		h.series = map[string]*Histogram{}
+	}
+	histogram, ok := h.series[key]
+	if !ok {
+		histogram = &Histogram{
+			labelValues: append([]string(nil), labelValues...),
+			buckets:     h.buckets,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = histogram
+	}
+	return histogram
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	histograms := make([]*Histogram, 0, len(h.series))
+	for _, histogram := range h.series {
+		histograms = append(histograms, histogram)
+	}
+	h.mu.Unlock()
+	sort.Slice(histograms, func(i, j int) bool {
+		return lessValues(histograms[i].labelValues, histograms[j].labelValues)
+	})
+
+	for _, histogram := range histograms {
+		counts, count, sum := histogram.snapshot()
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(histogram.labelValues, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(histogram.labelValues, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(histogram.labelValues), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(histogram.labelValues), count)
+	}
+}
+
+// Histogram is a single series of a HistogramVec.
+type Histogram struct {
+	labelValues []string
+	buckets     []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe adds a value to the histogram.
+func (h *Histogram) Observe(value float64) {
+	// the first bucket with an upper bound of at least the value, values above all bounds only count for +Inf
+	i := sort.SearchFloat64s(h.buckets, value)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]uint64(nil), h.counts...), h.count, h.sum
+}
+
+func lessValues(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
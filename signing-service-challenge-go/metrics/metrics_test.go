@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("text format", func(t *testing.T) {
+		r := NewRegistry()
+		requests := r.Counter("requests_total", "Handled requests.", "route", "status")
+		latency := r.Histogram("latency_seconds", "Latency\nin seconds.", []float64{1, 0.1}, "route")
+		plain := r.Counter("plain_total", "Without labels.")
+
+		requests.With("b", "200").Add(2)
+		requests.With("a", "500").Inc()
+		requests.With("a", "500").Add(-1)
+		requests.With(`q"\`+"\n", "200").Inc()
+		latency.With("a").Observe(0.1)
+		latency.With("a").Observe(0.5)
+		latency.With("a").Observe(3)
+		plain.With().Inc()
+
+		var buf bytes.Buffer
+		require.Nil(t, r.WriteText(&buf))
+		assert.Equal(t, `# HELP requests_total Handled requests.
+# TYPE requests_total counter
+requests_total{route="a",status="500"} 1
+requests_total{route="b",status="200"} 2
+requests_total{route="q\"\\\n",status="200"} 1
+# HELP latency_seconds Latency\nin seconds.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="a",le="0.1"} 1
+latency_seconds_bucket{route="a",le="1"} 2
+latency_seconds_bucket{route="a",le="+Inf"} 3
+latency_seconds_sum{route="a"} 3.6
+latency_seconds_count{route="a"} 3
+# HELP plain_total Without labels.
+# TYPE plain_total counter
+plain_total 1
+`, buf.String())
+	})
+	t.Run("handler", func(t *testing.T) {
+		r := NewRegistry()
+		r.Counter("up_total", "Up.").With().Inc()
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "up_total 1\n")
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		r := NewRegistry()
+		counter := r.Counter("concurrent_total", "Concurrent.", "worker")
+		histogram := r.Histogram("concurrent_seconds", "Concurrent.", DefaultBuckets)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					counter.With("shared").Inc()
+					histogram.With().Observe(0.01)
+					r.WriteText(&bytes.Buffer{})
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, float64(800), counter.With("shared").Value())
+		assert.Equal(t, uint64(800), histogram.With().Count())
+	})
+	t.Run("misuse", func(t *testing.T) {
+		r := NewRegistry()
+		counter := r.Counter("twice_total", "Twice.", "label")
+		assert.Panics(t, func() { r.Counter("twice_total", "Twice.") })
+		assert.Panics(t, func() { counter.With() })
+		assert.Panics(t, func() { counter.With("a", "b") })
+	})
+}
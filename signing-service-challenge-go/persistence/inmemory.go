@@ -1,7 +1,11 @@
 package persistence
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 	"github.com/google/uuid"
@@ -9,21 +13,52 @@ import (
 
 type InMemoryStorer struct {
 	Devices map[string]*domain.SignatureDevice
+
+	signatures map[string][]domain.Signature
+	// apiKeys are keyed by ID, apiKeyIDs maps hashes to IDs.
+	apiKeys   map[string]domain.APIKey
+	apiKeyIDs map[string]string
+	mu        sync.RWMutex
+}
+
+// NewInMemoryStorer creates an empty InMemoryStorer.
+func NewInMemoryStorer() *InMemoryStorer {
+	return &InMemoryStorer{
+		Devices:    map[string]*domain.SignatureDevice{},
+		signatures: map[string][]domain.Signature{},
+		apiKeys:    map[string]domain.APIKey{},
+		apiKeyIDs:  map[string]string{},
+	}
 }
 
-// CreateSignatureDevice stores a domain.SignatureDevice in the memory store. Expects a valid UUID. If the id already exists, the entry is updated.
-func (s InMemoryStorer) CreateSignatureDevice(device *domain.SignatureDevice) (*domain.SignatureDevice, error) {
+// CreateSignatureDevice stores a domain.SignatureDevice in the memory store. Expects a valid UUID. If the id already exists, ErrConflict is returned.
+func (s *InMemoryStorer) CreateSignatureDevice(ctx context.Context, device *domain.SignatureDevice) (*domain.SignatureDevice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("CreateSignatureDevice | %w", err)
+	}
 	if device == nil {
 		return nil, fmt.Errorf("CreateSignatureDevice | device is nil")
 	}
 	if device.ID == uuid.Nil {
 		return nil, fmt.Errorf("CreateSignatureDevice | no id")
 	}
-	s.Devices[string(device.ID.String())] = device
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := device.ID.String()
+	if _, ok := s.Devices[id]; ok {
+		return nil, fmt.Errorf("CreateSignatureDevice | device: %s | %w", id, ErrConflict)
+	}
+	s.Devices[id] = device
 	return device, nil
 }
 
-func (s InMemoryStorer) ReadSignatureDevices() ([]*domain.SignatureDevice, error) {
+// ReadSignatureDevices returns all devices ordered by ID.
+func (s *InMemoryStorer) ReadSignatureDevices(ctx context.Context) ([]*domain.SignatureDevice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ReadSignatureDevices | %w", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	devices := make([]*domain.SignatureDevice, len(s.Devices))
 
 	i := 0
@@ -31,13 +66,154 @@ func (s InMemoryStorer) ReadSignatureDevices() ([]*domain.SignatureDevice, error
 		devices[i] = dev
 		i++
 	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID.String() < devices[j].ID.String() })
 	return devices, nil
 }
 
-func (s InMemoryStorer) ReadSignatureDevice(id string) (*domain.SignatureDevice, error) {
+// ReadSignatureDevice returns the device with the id. Unknown ids result in ErrNotFound.
+func (s *InMemoryStorer) ReadSignatureDevice(ctx context.Context, id string) (*domain.SignatureDevice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ReadSignatureDevice | %w", err)
+	}
 	_, err := uuid.Parse(id)
 	if err != nil {
 		return nil, fmt.Errorf("ReadSignatureDevice | invalid uuid")
 	}
-	return s.Devices[id], nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	device, ok := s.Devices[id]
+	if !ok {
+		return nil, fmt.Errorf("ReadSignatureDevice | device: %s | %w", id, ErrNotFound)
+	}
+	return device, nil
+}
+
+// CreateSignatures appends the signatures to the ledger of the device. The first signature has to carry the
+// next expected counter and all following ones have to be consecutive; otherwise nothing is stored and ErrConflict
+// is returned.
+func (s *InMemoryStorer) CreateSignatures(ctx context.Context, deviceID string, signatures []domain.Signature) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("CreateSignatures | %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Devices[deviceID]; !ok {
+		return fmt.Errorf("CreateSignatures | device: %s | %w", deviceID, ErrNotFound)
+	}
+	if s.signatures == nil {
+		s.signatures = map[string][]domain.Signature{}
+	}
+
+	ledger := s.signatures[deviceID]
+	next := len(ledger)
+	for _, signature := range signatures {
+		if signature.Counter != next {
+			return fmt.Errorf("CreateSignatures | device: %s | expected counter %d, got %d | %w", deviceID, next, signature.Counter, ErrConflict)
+		}
+		next++
+	}
+	s.signatures[deviceID] = append(ledger, signatures...)
+	return nil
+}
+
+// ReadSignatures returns the ledger of a device ordered by signature counter.
+func (s *InMemoryStorer) ReadSignatures(ctx context.Context, deviceID string) ([]domain.Signature, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ReadSignatures | %w", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.Devices[deviceID]; !ok {
+		return nil, fmt.Errorf("ReadSignatures | device: %s | %w", deviceID, ErrNotFound)
+	}
+	ledger := s.signatures[deviceID]
+	signatures := make([]domain.Signature, len(ledger))
+	copy(signatures, ledger)
+	return signatures, nil
+}
+
+// CreateAPIKey stores a new API key. If the ID or hash already exists, ErrConflict is returned.
+func (s *InMemoryStorer) CreateAPIKey(ctx context.Context, key domain.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("CreateAPIKey | %w", err)
+	}
+	if key.ID == "" || key.Hash == "" {
+		return fmt.Errorf("CreateAPIKey | id or hash missing")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.apiKeys == nil {
+		s.apiKeys = map[string]domain.APIKey{}
+		s.apiKeyIDs = map[string]string{}
+	}
+	if _, ok := s.apiKeys[key.ID]; ok {
+		return fmt.Errorf("CreateAPIKey | key: %s | %w", key.ID, ErrConflict)
+	}
+	if _, ok := s.apiKeyIDs[key.Hash]; ok {
+		return fmt.Errorf("CreateAPIKey | key: %s | hash exists | %w", key.ID, ErrConflict)
+	}
+	s.apiKeys[key.ID] = key
+	s.apiKeyIDs[key.Hash] = key.ID
+	return nil
+}
+
+// ReadAPIKeys returns all API keys ordered by ID.
+func (s *InMemoryStorer) ReadAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ReadAPIKeys | %w", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]domain.APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+// ReadAPIKey returns the API key with the id. Unknown ids result in ErrNotFound.
+func (s *InMemoryStorer) ReadAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.APIKey{}, fmt.Errorf("ReadAPIKey | %w", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return domain.APIKey{}, fmt.Errorf("ReadAPIKey | key: %s | %w", id, ErrNotFound)
+	}
+	return key, nil
+}
+
+// ReadAPIKeyByHash returns the API key with the hash. Unknown hashes result in ErrNotFound.
+func (s *InMemoryStorer) ReadAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.APIKey{}, fmt.Errorf("ReadAPIKeyByHash | %w", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.apiKeyIDs[hash]
+	if !ok {
+		return domain.APIKey{}, fmt.Errorf("ReadAPIKeyByHash | %w", ErrNotFound)
+	}
+	return s.apiKeys[id], nil
+}
+
+// RevokeAPIKey marks the API key with the id as revoked. The time of an earlier revocation is kept.
+func (s *InMemoryStorer) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (domain.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.APIKey{}, fmt.Errorf("RevokeAPIKey | %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return domain.APIKey{}, fmt.Errorf("RevokeAPIKey | key: %s | %w", id, ErrNotFound)
+	}
+	if !key.Revoked() {
+		key.RevokedAt = &revokedAt
+		s.apiKeys[id] = key
+	}
+	return key, nil
 }
@@ -1,6 +1,7 @@
 package persistence
 
 import (
+	"context"
 	"testing"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
@@ -13,13 +14,13 @@ import (
 func TestCreateSignatureDevice(t *testing.T) {
 	t.Run("nil", func(t *testing.T) {
 		s := getEmptyStorer()
-		sd, err := s.CreateSignatureDevice(nil)
+		sd, err := s.CreateSignatureDevice(context.Background(), nil)
 		assert.NotNil(t, err, "storing nil expects error")
 		assert.Nil(t, sd, "nil return value expected on error")
 	})
 	t.Run("empty", func(t *testing.T) {
 		s := getEmptyStorer()
-		sd, err := s.CreateSignatureDevice(&domain.SignatureDevice{})
+		sd, err := s.CreateSignatureDevice(context.Background(), &domain.SignatureDevice{})
 		assert.NotNil(t, err, "storing nil expects error")
 		assert.Nil(t, sd, "nil return value expected on error")
 	})
@@ -27,7 +28,7 @@ func TestCreateSignatureDevice(t *testing.T) {
 		s := getEmptyStorer()
 		devices := getDeviceMap(t)
 		for _, dev := range devices {
-			sd, err := s.CreateSignatureDevice(dev)
+			sd, err := s.CreateSignatureDevice(context.Background(), dev)
 			assert.Nil(t, err)
 			dev := s.Devices[dev.ID.String()]
 			assert.Equal(t, dev.ID, sd.ID, "device shoudld be stored with its id")
@@ -36,10 +37,11 @@ func TestCreateSignatureDevice(t *testing.T) {
 	t.Run("collision", func(t *testing.T) {
 		s := getStorerWithData(t)
 		devices := s.Devices
-		for _, dev := range devices {
-			sd, err := s.CreateSignatureDevice(dev)
-			assert.Nil(t, err)
-			assert.Equal(t, dev, sd, "expected devices to be equal")
+		for id, dev := range devices {
+			sd, err := s.CreateSignatureDevice(context.Background(), dev)
+			assert.ErrorIs(t, err, ErrConflict)
+			assert.Nil(t, sd, "nil return value expected on error")
+			assert.Same(t, dev, s.Devices[id], "existing device is kept")
 		}
 	})
 }
@@ -48,7 +50,7 @@ func TestReadSignatureDevices(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		s := getEmptyStorer()
 
-		gotDevices, err := s.ReadSignatureDevices()
+		gotDevices, err := s.ReadSignatureDevices(context.Background())
 		assert.Nil(t, err)
 		assert.Equal(t, 0, len(gotDevices))
 	})
@@ -56,7 +58,7 @@ func TestReadSignatureDevices(t *testing.T) {
 		s := getStorerWithData(t)
 		devices := s.Devices
 
-		gotDevices, err := s.ReadSignatureDevices()
+		gotDevices, err := s.ReadSignatureDevices(context.Background())
 		assert.Nil(t, err)
 		for _, gotDev := range gotDevices {
 			device := devices[gotDev.ID.String()]
@@ -69,14 +71,14 @@ func TestReadSignatureDevice(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		s := getEmptyStorer()
 
-		dev, err := s.ReadSignatureDevice(uuid.NewString())
-		assert.Nil(t, err)
+		dev, err := s.ReadSignatureDevice(context.Background(), uuid.NewString())
+		assert.ErrorIs(t, err, ErrNotFound)
 		assert.Nil(t, dev, "no device expected")
 	})
 
 	t.Run("empty string", func(t *testing.T) {
 		s := getStorerWithData(t)
-		dev, err := s.ReadSignatureDevice("")
+		dev, err := s.ReadSignatureDevice(context.Background(), "")
 		assert.NotNil(t, err, "expect error for invalid id")
 		assert.Nil(t, dev, "expect no device for invalid id")
 	})
@@ -85,7 +87,7 @@ func TestReadSignatureDevice(t *testing.T) {
 		devices := s.Devices
 
 		for id, device := range devices {
-			gotDev, err := s.ReadSignatureDevice(id)
+			gotDev, err := s.ReadSignatureDevice(context.Background(), id)
 			assert.Nil(t, err)
 			assert.Equal(t, device, gotDev, "devices not equal")
 		}
@@ -93,10 +95,44 @@ func TestReadSignatureDevice(t *testing.T) {
 
 }
 
-func getEmptyStorer() InMemoryStorer {
-	return InMemoryStorer{
-		Devices: map[string]*domain.SignatureDevice{},
-	}
+func TestCreateSignatures(t *testing.T) {
+	deviceID := "38da2fb6-c293-4a63-a349-835330f0aca7"
+	t.Run("unknown device", func(t *testing.T) {
+		s := getEmptyStorer()
+		err := s.CreateSignatures(context.Background(), deviceID, []domain.Signature{{Counter: 0}})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("append", func(t *testing.T) {
+		s := getStorerWithData(t)
+		require.Nil(t, s.CreateSignatures(context.Background(), deviceID, []domain.Signature{{Counter: 0}}))
+		require.Nil(t, s.CreateSignatures(context.Background(), deviceID, []domain.Signature{{Counter: 1}, {Counter: 2}}))
+
+		signatures, err := s.ReadSignatures(context.Background(), deviceID)
+		assert.Nil(t, err)
+		require.Equal(t, 3, len(signatures))
+		for i, signature := range signatures {
+			assert.Equal(t, i, signature.Counter)
+		}
+	})
+	t.Run("gap", func(t *testing.T) {
+		s := getStorerWithData(t)
+		err := s.CreateSignatures(context.Background(), deviceID, []domain.Signature{{Counter: 0}, {Counter: 2}})
+		assert.ErrorIs(t, err, ErrConflict, "expect error for counter gap")
+
+		signatures, err := s.ReadSignatures(context.Background(), deviceID)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(signatures), "nothing is stored on error")
+	})
+	t.Run("duplicate", func(t *testing.T) {
+		s := getStorerWithData(t)
+		require.Nil(t, s.CreateSignatures(context.Background(), deviceID, []domain.Signature{{Counter: 0}}))
+		err := s.CreateSignatures(context.Background(), deviceID, []domain.Signature{{Counter: 0}})
+		assert.ErrorIs(t, err, ErrConflict, "expect error for reused counter")
+	})
+}
+
+func getEmptyStorer() *InMemoryStorer {
+	return NewInMemoryStorer()
 }
 
 func getDeviceMap(t *testing.T) map[string]*domain.SignatureDevice {
@@ -129,10 +165,8 @@ func getDeviceMap(t *testing.T) map[string]*domain.SignatureDevice {
 	return deviceMap
 }
 
-func getStorerWithData(t *testing.T) InMemoryStorer {
-	deviceMap := getDeviceMap(t)
-
-	return InMemoryStorer{
-		Devices: deviceMap,
-	}
+func getStorerWithData(t *testing.T) *InMemoryStorer {
+	s := NewInMemoryStorer()
+	s.Devices = getDeviceMap(t)
+	return s
 }
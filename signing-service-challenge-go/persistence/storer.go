@@ -1,9 +1,51 @@
 package persistence
 
-import "github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+import (
+	"context"
+	"errors"
+	"time"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+var (
+	// ErrNotFound is returned for devices and API keys that are not stored.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned if a write contradicts the stored state, e.g. an existing device or a reused counter.
+	ErrConflict = errors.New("conflict")
+)
+
+// Storer persists signature devices, their signature ledgers and API keys. Implementations have to be safe for concurrent
+// use; storertest.Run checks the contract below. Operations fail with the error of ctx once it is done.
 type Storer interface {
-	CreateSignatureDevice(device *domain.SignatureDevice) (*domain.SignatureDevice, error)
-	ReadSignatureDevices() ([]*domain.SignatureDevice, error)
-	ReadSignatureDevice(id string) (*domain.SignatureDevice, error)
+	// CreateSignatureDevice stores a new device. It returns ErrConflict if a device with the ID exists already.
+	CreateSignatureDevice(ctx context.Context, device *domain.SignatureDevice) (*domain.SignatureDevice, error)
+	// ReadSignatureDevices returns all devices ordered by ID.
+	ReadSignatureDevices(ctx context.Context) ([]*domain.SignatureDevice, error)
+	// ReadSignatureDevice returns the device with the ID or ErrNotFound.
+	ReadSignatureDevice(ctx context.Context, id string) (*domain.SignatureDevice, error)
+
+	// CreateSignatures appends signatures to the ledger of a device. The signatures have to continue the
+	// device's counter sequence without gaps; otherwise nothing is stored and ErrConflict is returned.
+	CreateSignatures(ctx context.Context, deviceID string, signatures []domain.Signature) error
+	// ReadSignatures returns the ledger of a device ordered by counter or ErrNotFound for unknown devices.
+	ReadSignatures(ctx context.Context, deviceID string) ([]domain.Signature, error)
+
+	// CreateAPIKey stores a new API key. It returns ErrConflict if a key with the ID or hash exists already.
+	CreateAPIKey(ctx context.Context, key domain.APIKey) error
+	// ReadAPIKeys returns all API keys including revoked ones ordered by ID.
+	ReadAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+	// ReadAPIKey returns the API key with the ID or ErrNotFound.
+	ReadAPIKey(ctx context.Context, id string) (domain.APIKey, error)
+	// ReadAPIKeyByHash returns the API key with the hash (see domain.HashAPIKey) or ErrNotFound.
+	ReadAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error)
+	// RevokeAPIKey marks the API key as revoked at revokedAt and returns it, or ErrNotFound. Revoking a key again
+	// keeps the time of the first revocation.
+	RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (domain.APIKey, error)
+}
+
+// Flusher is implemented by stores that buffer writes. The server flushes the store on shutdown, after the last
+// signature has been committed.
+type Flusher interface {
+	Flush(ctx context.Context) error
 }
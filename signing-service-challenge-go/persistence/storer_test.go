@@ -0,0 +1,14 @@
+package persistence_test
+
+import (
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence/storertest"
+)
+
+func TestInMemoryStorerContract(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) persistence.Storer {
+		return persistence.NewInMemoryStorer()
+	})
+}
@@ -0,0 +1,402 @@
+// Package storertest provides the behaviour tests every persistence.Storer implementation has to pass.
+//
+// A store runs the whole suite with a single call from its own tests:
+//
+//	func TestStorer(t *testing.T) {
+//		storertest.Run(t, func(t *testing.T) persistence.Storer { return NewStore(...) })
+//	}
+package storertest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStorer returns an empty store for a single test.
+type NewStorer func(t *testing.T) persistence.Storer
+
+// clientID is registered to the devices signing in the tests.
+const clientID = "storertest"
+
+// Run runs the contract tests as subtests of t. Every subtest gets its own store from newStorer.
+func Run(t *testing.T, newStorer NewStorer) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s persistence.Storer)
+	}{
+		{"create", testCreate},
+		{"create invalid", testCreateInvalid},
+		{"conflict", testConflict},
+		{"not found", testNotFound},
+		{"canceled context", testCanceledContext},
+		{"list ordering", testListOrdering},
+		{"ledger append", testLedgerAppend},
+		{"ledger rejects gaps", testLedgerGaps},
+		{"ledgers are separate", testLedgersSeparate},
+		{"concurrent appends", testConcurrentAppends},
+		{"concurrent counter updates", testConcurrentCounterUpdates},
+		{"api keys", testAPIKeys},
+		{"api key conflict", testAPIKeyConflict},
+		{"api key revocation", testAPIKeyRevocation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorer(t))
+		})
+	}
+}
+
+func testCreate(t *testing.T, s persistence.Storer) {
+	device := newDevice(t, uuid.New(), "created")
+
+	created, err := s.CreateSignatureDevice(context.Background(), device)
+	require.NoError(t, err)
+	assertSameDevice(t, device, created)
+
+	read, err := s.ReadSignatureDevice(context.Background(), device.ID.String())
+	require.NoError(t, err)
+	assertSameDevice(t, device, read)
+}
+
+func testCreateInvalid(t *testing.T, s persistence.Storer) {
+	created, err := s.CreateSignatureDevice(context.Background(), nil)
+	assert.Error(t, err, "nil device")
+	assert.Nil(t, created)
+
+	created, err = s.CreateSignatureDevice(context.Background(), &domain.SignatureDevice{})
+	assert.Error(t, err, "device without id")
+	assert.Nil(t, created)
+}
+
+func testConflict(t *testing.T, s persistence.Storer) {
+	id := uuid.New()
+	first := newDevice(t, id, "first")
+	_, err := s.CreateSignatureDevice(context.Background(), first)
+	require.NoError(t, err)
+
+	created, err := s.CreateSignatureDevice(context.Background(), newDevice(t, id, "second"))
+	assert.ErrorIs(t, err, persistence.ErrConflict)
+	assert.Nil(t, created)
+
+	read, err := s.ReadSignatureDevice(context.Background(), id.String())
+	require.NoError(t, err)
+	assert.Equal(t, "first", read.Label, "the existing device is kept")
+}
+
+func testNotFound(t *testing.T, s persistence.Storer) {
+	id := uuid.NewString()
+
+	device, err := s.ReadSignatureDevice(context.Background(), id)
+	assert.ErrorIs(t, err, persistence.ErrNotFound)
+	assert.Nil(t, device)
+
+	_, err = s.ReadSignatures(context.Background(), id)
+	assert.ErrorIs(t, err, persistence.ErrNotFound)
+
+	err = s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 0}})
+	assert.ErrorIs(t, err, persistence.ErrNotFound)
+
+	_, err = s.ReadSignatureDevice(context.Background(), "not-a-uuid")
+	assert.Error(t, err)
+}
+
+func testCanceledContext(t *testing.T, s persistence.Storer) {
+	id := createDevice(t, s).ID.String()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.CreateSignatureDevice(ctx, newDevice(t, uuid.New(), "canceled"))
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.ReadSignatureDevices(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.ReadSignatureDevice(ctx, id)
+	assert.ErrorIs(t, err, context.Canceled)
+	err = s.CreateSignatures(ctx, id, []domain.Signature{{Counter: 0}})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.ReadSignatures(ctx, id)
+	assert.ErrorIs(t, err, context.Canceled)
+	err = s.CreateAPIKey(ctx, newAPIKey(t, "canceled"))
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.ReadAPIKeys(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	devices, err := s.ReadSignatureDevices(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, devices, 1, "nothing is stored with a canceled context")
+	signatures, err := s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	assert.Empty(t, signatures)
+}
+
+func testListOrdering(t *testing.T, s persistence.Storer) {
+	devices, err := s.ReadSignatureDevices(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+
+	ids := []string{
+		"ff50085e-463d-4b83-a4e6-94e9eae3dbaf",
+		"1727d3e0-e1ae-410c-97d2-70da0ae0abc4",
+		"e2a31dd8-1356-4c73-980a-69fd86af0dc9",
+		"38da2fb6-c293-4a63-a349-835330f0aca7",
+	}
+	for _, id := range ids {
+		_, err := s.CreateSignatureDevice(context.Background(), newDevice(t, uuid.MustParse(id), id))
+		require.NoError(t, err)
+	}
+
+	devices, err = s.ReadSignatureDevices(context.Background())
+	require.NoError(t, err)
+	got := make([]string, len(devices))
+	for i, device := range devices {
+		got[i] = device.ID.String()
+		assert.Equal(t, got[i], device.Label)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, ids, got, "devices are ordered by id")
+}
+
+func testLedgerAppend(t *testing.T, s persistence.Storer) {
+	id := createDevice(t, s).ID.String()
+
+	signatures, err := s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	assert.Empty(t, signatures)
+
+	require.NoError(t, s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 0, SignedData: "0"}}))
+	require.NoError(t, s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 1, SignedData: "1"}, {Counter: 2, SignedData: "2"}}))
+
+	signatures, err = s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, signatures, 3)
+	for i, signature := range signatures {
+		assert.Equal(t, i, signature.Counter)
+		assert.Equal(t, fmt.Sprint(i), signature.SignedData)
+	}
+
+	// the returned ledger is a copy
+	signatures[0].SignedData = "changed"
+	signatures, err = s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "0", signatures[0].SignedData)
+}
+
+func testLedgerGaps(t *testing.T, s persistence.Storer) {
+	id := createDevice(t, s).ID.String()
+
+	err := s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 1}})
+	assert.ErrorIs(t, err, persistence.ErrConflict, "the ledger starts at counter 0")
+	err = s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 0}, {Counter: 2}})
+	assert.ErrorIs(t, err, persistence.ErrConflict, "counters are consecutive")
+
+	signatures, err := s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	assert.Empty(t, signatures, "nothing is stored on error")
+
+	require.NoError(t, s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 0}}))
+	err = s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: 0}})
+	assert.ErrorIs(t, err, persistence.ErrConflict, "counters are not reused")
+}
+
+func testLedgersSeparate(t *testing.T, s persistence.Storer) {
+	first := createDevice(t, s).ID.String()
+	second := createDevice(t, s).ID.String()
+
+	require.NoError(t, s.CreateSignatures(context.Background(), first, []domain.Signature{{Counter: 0}, {Counter: 1}}))
+	require.NoError(t, s.CreateSignatures(context.Background(), second, []domain.Signature{{Counter: 0}}))
+
+	signatures, err := s.ReadSignatures(context.Background(), first)
+	require.NoError(t, err)
+	assert.Len(t, signatures, 2)
+	signatures, err = s.ReadSignatures(context.Background(), second)
+	require.NoError(t, err)
+	assert.Len(t, signatures, 1)
+}
+
+// testConcurrentAppends races appends of the same counter: exactly one of them may win.
+func testConcurrentAppends(t *testing.T, s persistence.Storer) {
+	id := createDevice(t, s).ID.String()
+	const rounds, writers = 20, 8
+
+	for counter := 0; counter < rounds; counter++ {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		stored := 0
+		for writer := 0; writer < writers; writer++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := s.CreateSignatures(context.Background(), id, []domain.Signature{{Counter: counter, SignedData: fmt.Sprint(writer)}})
+				if err == nil {
+					mu.Lock()
+					stored++
+					mu.Unlock()
+					return
+				}
+				assert.ErrorIs(t, err, persistence.ErrConflict)
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, 1, stored, "appends of counter %d", counter)
+	}
+
+	signatures, err := s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	assert.Len(t, signatures, rounds)
+}
+
+// testConcurrentCounterUpdates signs concurrently with a stored device and commits every signature to the
+// store, as the API does. The ledger has to end up gapless and chained.
+func testConcurrentCounterUpdates(t *testing.T, s persistence.Storer) {
+	stored := createDevice(t, s)
+	id := stored.ID.String()
+	const workers, perWorker = 8, 10
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				// every signature reads the device again, like a request would
+				device, err := s.ReadSignatureDevice(context.Background(), id)
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, err = device.Sign(context.Background(), clientID, fmt.Sprintf("%d-%d", worker, i), func(_ context.Context, signatures []domain.Signature) error {
+					return s.CreateSignatures(context.Background(), id, signatures)
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	signatures, err := s.ReadSignatures(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, signatures, workers*perWorker)
+
+	device, err := s.ReadSignatureDevice(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, workers*perWorker, device.Info().SignatureCounter)
+
+	publicKey, err := device.PublicKey()
+	require.NoError(t, err)
+	verifier, _, err := crypto.NewVerifier(publicKey)
+	require.NoError(t, err)
+	assert.Empty(t, domain.VerifyChain(stored.ID, verifier, signatures))
+}
+
+// newDevice creates a device with a registered client that is not stored yet.
+func newDevice(t *testing.T, id uuid.UUID, label string) *domain.SignatureDevice {
+	t.Helper()
+	device, err := domain.NewSignatureDevice(id, label, crypto.SignautreECDSA)
+	require.NoError(t, err)
+	require.NoError(t, device.RegisterClient(clientID))
+	return device
+}
+
+// createDevice stores a new device with a random id.
+func createDevice(t *testing.T, s persistence.Storer) *domain.SignatureDevice {
+	t.Helper()
+	device, err := s.CreateSignatureDevice(context.Background(), newDevice(t, uuid.New(), "storertest"))
+	require.NoError(t, err)
+	return device
+}
+
+// assertSameDevice checks the persisted fields of a device.
+func assertSameDevice(t *testing.T, expected *domain.SignatureDevice, actual *domain.SignatureDevice) {
+	t.Helper()
+	require.NotNil(t, actual)
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Label, actual.Label)
+	assert.Equal(t, expected.Algorithm, actual.Algorithm)
+
+	expectedKey, err := expected.PublicKey()
+	require.NoError(t, err)
+	actualKey, err := actual.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, expectedKey, actualKey)
+}
+
+func testAPIKeys(t *testing.T, s persistence.Storer) {
+	keys, err := s.ReadAPIKeys(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	first, second := newAPIKey(t, "first"), newAPIKey(t, "second")
+	require.NoError(t, s.CreateAPIKey(context.Background(), first))
+	require.NoError(t, s.CreateAPIKey(context.Background(), second))
+
+	read, err := s.ReadAPIKey(context.Background(), first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, read)
+	read, err = s.ReadAPIKeyByHash(context.Background(), second.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, second, read)
+
+	keys, err = s.ReadAPIKeys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.True(t, keys[0].ID < keys[1].ID, "ordered by id")
+
+	_, err = s.ReadAPIKey(context.Background(), uuid.NewString())
+	assert.ErrorIs(t, err, persistence.ErrNotFound)
+	_, err = s.ReadAPIKeyByHash(context.Background(), domain.HashAPIKey("unknown"))
+	assert.ErrorIs(t, err, persistence.ErrNotFound)
+}
+
+func testAPIKeyConflict(t *testing.T, s persistence.Storer) {
+	key := newAPIKey(t, "first")
+	require.NoError(t, s.CreateAPIKey(context.Background(), key))
+
+	sameID := newAPIKey(t, "same id")
+	sameID.ID = key.ID
+	assert.ErrorIs(t, s.CreateAPIKey(context.Background(), sameID), persistence.ErrConflict)
+	sameHash := newAPIKey(t, "same hash")
+	sameHash.Hash = key.Hash
+	assert.ErrorIs(t, s.CreateAPIKey(context.Background(), sameHash), persistence.ErrConflict)
+
+	read, err := s.ReadAPIKeyByHash(context.Background(), key.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "first", read.Name, "the existing key is kept")
+}
+
+func testAPIKeyRevocation(t *testing.T, s persistence.Storer) {
+	key := newAPIKey(t, "revoked")
+	require.NoError(t, s.CreateAPIKey(context.Background(), key))
+
+	revokedAt := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	revoked, err := s.RevokeAPIKey(context.Background(), key.ID, revokedAt)
+	require.NoError(t, err)
+	require.True(t, revoked.Revoked())
+	assert.True(t, revokedAt.Equal(*revoked.RevokedAt))
+
+	again, err := s.RevokeAPIKey(context.Background(), key.ID, revokedAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(*again.RevokedAt), "the first revocation is kept")
+
+	read, err := s.ReadAPIKeyByHash(context.Background(), key.Hash)
+	require.NoError(t, err)
+	assert.True(t, read.Revoked(), "revoked keys stay readable")
+
+	_, err = s.RevokeAPIKey(context.Background(), uuid.NewString(), revokedAt)
+	assert.ErrorIs(t, err, persistence.ErrNotFound)
+}
+
+// newAPIKey issues an API key that signs with all devices.
+func newAPIKey(t *testing.T, name string) domain.APIKey {
+	key, _, err := domain.NewAPIKey(name, []domain.Scope{domain.ScopeSign}, nil, time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	return key
+}
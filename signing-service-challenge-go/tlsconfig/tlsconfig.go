@@ -0,0 +1,215 @@
+// Package tlsconfig provides the TLS configuration of the server: a certificate that is reloaded from disk when its
+// files change, so renewed certificates are served without restart, and the verification of client certificates
+// against a CA bundle (mutual TLS).
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+)
+
+// DefaultReloadInterval is the minimum time between two checks of the certificate files.
+const DefaultReloadInterval = 10 * time.Second
+
+// expiryWarning is the remaining validity below which the certificate check warns.
+const expiryWarning = 30 * 24 * time.Hour
+
+// Client authentication modes.
+const (
+	// ClientAuthNone does not ask clients for certificates.
+	ClientAuthNone = "none"
+	// ClientAuthOptional verifies client certificates if clients send one.
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects connections without a valid client certificate.
+	ClientAuthRequire = "require"
+)
+
+// ErrNoCertificates is returned for CA bundles without a PEM certificate.
+var ErrNoCertificates = errors.New("no certificates found")
+
+// ParseClientAuth maps a client authentication mode to its tls.ClientAuthType.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("ParseClientAuth | unsupported client authentication %q", mode)
+}
+
+// LoadCertPool reads a bundle of PEM certificates.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCertPool | %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("LoadCertPool | %s: %w", file, ErrNoCertificates)
+	}
+	return pool, nil
+}
+
+// ServerConfig returns the configuration of a server presenting the certificate of the reloader. Client
+// certificates are verified against clientCAs according to clientAuth, see ParseClientAuth.
+func ServerConfig(reloader *Reloader, clientCAs *x509.CertPool, clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		ClientCAs:      clientCAs,
+		ClientAuth:     clientAuth,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// Reloader serves a certificate and its key from PEM files. The files are checked for changes on handshakes, at
+// most once per Interval, and reloaded once both have been replaced consistently. If reloading fails, the
+// previous certificate is kept and the error is reported by Check.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	// Interval is the minimum time between two checks of the files.
+	Interval time.Duration
+	// Clock provides the time of the checks and of the certificate validity.
+	Clock clock.Clock
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	versions    [2]fileVersion
+	checked     time.Time
+	err         error
+}
+
+// fileVersion identifies the content of a file without reading it.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the certificate and key. It fails if they cannot be loaded.
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		Interval: DefaultReloadInterval,
+		Clock:    clock.System,
+	}
+	if err := r.reload(); err != nil {
+		return nil, fmt.Errorf("NewReloader | %w", err)
+	}
+	r.checked = r.Clock.Now()
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, see tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maybeReload()
+	return r.certificate, nil
+}
+
+// Certificate returns the leaf of the current certificate.
+func (r *Reloader) Certificate() *x509.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.certificate.Leaf
+}
+
+// Check reports the remaining validity of the current certificate in seconds. It warns if the certificate
+// expires soon or the last reload failed.
+func (r *Reloader) Check(ctx context.Context) health.Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maybeReload()
+
+	leaf := r.certificate.Leaf
+	now := r.Clock.Now()
+	remaining := leaf.NotAfter.Sub(now)
+	result := health.Result{
+		ComponentID:   leaf.SerialNumber.String(),
+		Status:        health.StatusPass,
+		ObservedValue: remaining.Seconds(),
+		ObservedUnit:  "s",
+	}
+	switch {
+	case remaining <= 0 || now.Before(leaf.NotBefore):
+		result.Status = health.StatusFail
+		result.Output = "certificate not valid"
+	case r.err != nil:
+		result.Status = health.StatusWarn
+		result.Output = r.err.Error()
+	case remaining < expiryWarning:
+		result.Status = health.StatusWarn
+		result.Output = "certificate expires soon"
+	}
+	return result
+}
+
+// maybeReload reloads the files if they changed since the last load. r.mu has to be held.
+func (r *Reloader) maybeReload() {
+	now := r.Clock.Now()
+	if now.Sub(r.checked) < r.Interval {
+		return
+	}
+	r.checked = now
+
+	versions, err := r.stat()
+	if err != nil {
+		r.err = fmt.Errorf("Reloader | %w", err)
+		return
+	}
+	if versions == r.versions {
+		return
+	}
+	if err := r.reload(); err != nil {
+		// the files might be replaced one after the other, a consistent pair is loaded on a later check
+		r.err = fmt.Errorf("Reloader | %w", err)
+		return
+	}
+	r.err = nil
+}
+
+// reload loads the certificate and key and remembers the versions of their files.
+func (r *Reloader) reload() error {
+	versions, err := r.stat()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if certificate.Leaf == nil {
+		if certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+			return err
+		}
+	}
+	r.certificate = &certificate
+	r.versions = versions
+	return nil
+}
+
+func (r *Reloader) stat() ([2]fileVersion, error) {
+	var versions [2]fileVersion
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return versions, err
+		}
+		versions[i] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return versions, nil
+}
@@ -0,0 +1,188 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/health"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tlsconfig/tlsconfigtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replace writes the certificate to the files and moves their modification time forward, so the change is
+// detected on file systems with coarse timestamps.
+func replace(t *testing.T, certificate tlsconfigtest.Certificate, dir string, modTime time.Time) {
+	certFile, keyFile := certificate.WriteFiles(t, dir)
+	require.Nil(t, os.Chtimes(certFile, modTime, modTime))
+	require.Nil(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func TestReloader(t *testing.T) {
+	ca := tlsconfigtest.NewCA(t)
+
+	newReloader := func(t *testing.T) (*Reloader, string, *clock.Fake) {
+		dir := t.TempDir()
+		certFile, keyFile := ca.Issue(t, "first").WriteFiles(t, dir)
+		r, err := NewReloader(certFile, keyFile)
+		require.Nil(t, err)
+		c := clock.NewFake(time.Now(), 0)
+		r.Clock = c
+		return r, dir, c
+	}
+	served := func(t *testing.T, r *Reloader) string {
+		certificate, err := r.GetCertificate(&tls.ClientHelloInfo{})
+		require.Nil(t, err)
+		return certificate.Leaf.Subject.CommonName
+	}
+
+	t.Run("missing files", func(t *testing.T) {
+		_, err := NewReloader(filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("reload", func(t *testing.T) {
+		r, dir, c := newReloader(t)
+		assert.Equal(t, "first", served(t, r))
+
+		replace(t, ca.Issue(t, "second"), dir, time.Now().Add(time.Minute))
+		assert.Equal(t, "first", served(t, r), "files are checked once per interval")
+		c.Advance(DefaultReloadInterval)
+		assert.Equal(t, "second", served(t, r))
+		assert.Equal(t, "second", r.Certificate().Subject.CommonName)
+	})
+	t.Run("failed reload", func(t *testing.T) {
+		r, dir, c := newReloader(t)
+		r.Interval = 0
+		first := r.Certificate()
+
+		modTime := time.Now().Add(time.Minute)
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "key.pem"), []byte("invalid"), 0o600))
+		require.Nil(t, os.Chtimes(filepath.Join(dir, "key.pem"), modTime, modTime))
+		assert.Equal(t, "first", served(t, r), "the previous certificate is kept")
+		result := r.Check(context.Background())
+		assert.Equal(t, health.StatusWarn, result.Status)
+		assert.NotEmpty(t, result.Output)
+		assert.Equal(t, first.SerialNumber.String(), result.ComponentID)
+
+		replace(t, ca.Issue(t, "second"), dir, modTime.Add(time.Minute))
+		c.Advance(time.Second)
+		assert.Equal(t, health.StatusPass, r.Check(context.Background()).Status)
+		assert.Equal(t, "second", served(t, r))
+	})
+	t.Run("expiry", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := ca.IssueValid(t, time.Now().Add(24*time.Hour), "expiring").WriteFiles(t, dir)
+		r, err := NewReloader(certFile, keyFile)
+		require.Nil(t, err)
+		assert.Equal(t, health.StatusWarn, r.Check(context.Background()).Status)
+
+		r.Clock = clock.NewFake(time.Now().Add(48*time.Hour), 0)
+		assert.Equal(t, health.StatusFail, r.Check(context.Background()).Status)
+	})
+}
+
+func TestParseClientAuth(t *testing.T) {
+	for mode, expected := range map[string]tls.ClientAuthType{
+		ClientAuthNone:     tls.NoClientCert,
+		ClientAuthOptional: tls.VerifyClientCertIfGiven,
+		ClientAuthRequire:  tls.RequireAndVerifyClientCert,
+	} {
+		t.Run(mode, func(t *testing.T) {
+			clientAuth, err := ParseClientAuth(mode)
+			require.Nil(t, err)
+			assert.Equal(t, expected, clientAuth)
+		})
+	}
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := ParseClientAuth("request")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("bundle", func(t *testing.T) {
+		file := filepath.Join(dir, "ca.pem")
+		require.Nil(t, os.WriteFile(file, append(tlsconfigtest.NewCA(t).PEM(), tlsconfigtest.NewCA(t).PEM()...), 0o600))
+		_, err := LoadCertPool(file)
+		assert.Nil(t, err)
+	})
+	t.Run("no certificates", func(t *testing.T) {
+		file := filepath.Join(dir, "empty.pem")
+		require.Nil(t, os.WriteFile(file, []byte("no pem"), 0o600))
+		_, err := LoadCertPool(file)
+		assert.ErrorIs(t, err, ErrNoCertificates)
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := LoadCertPool(filepath.Join(dir, "missing.pem"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestServerConfig(t *testing.T) {
+	ca := tlsconfigtest.NewCA(t)
+	certFile, keyFile := ca.Issue(t, "localhost").WriteFiles(t, t.TempDir())
+	reloader, err := NewReloader(certFile, keyFile)
+	require.Nil(t, err)
+	config := ServerConfig(reloader, ca.Pool(), tls.RequireAndVerifyClientCert)
+
+	// handshake connects a client with the certificates and returns the client certificate seen by the server.
+	handshake := func(t *testing.T, certificates ...tls.Certificate) (string, error) {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+		require.Nil(t, err)
+		defer listener.Close()
+		seen := make(chan string, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				seen <- ""
+				return
+			}
+			defer conn.Close()
+			tlsConn := conn.(*tls.Conn)
+			if tlsConn.Handshake() != nil || len(tlsConn.ConnectionState().VerifiedChains) == 0 {
+				seen <- ""
+				return
+			}
+			seen <- tlsConn.ConnectionState().VerifiedChains[0][0].Subject.CommonName
+		}()
+
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			RootCAs:      ca.Pool(),
+			Certificates: certificates,
+			ServerName:   "localhost",
+		})
+		if err == nil {
+			// TLS 1.3 clients learn about rejected certificates on the first read
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = conn.Read(make([]byte, 1))
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				err = nil
+			}
+			conn.Close()
+		}
+		return <-seen, err
+	}
+
+	t.Run("client certificate", func(t *testing.T) {
+		seen, _ := handshake(t, ca.Issue(t, "pos-1").TLS())
+		assert.Equal(t, "pos-1", seen)
+	})
+	t.Run("no client certificate", func(t *testing.T) {
+		seen, err := handshake(t)
+		assert.NotNil(t, err)
+		assert.Empty(t, seen)
+	})
+	t.Run("untrusted client certificate", func(t *testing.T) {
+		seen, err := handshake(t, tlsconfigtest.NewCA(t).Issue(t, "pos-1").TLS())
+		assert.NotNil(t, err)
+		assert.Empty(t, seen)
+	})
+}
@@ -0,0 +1,114 @@
+// Package tlsconfigtest issues certificates for tests of TLS servers and clients:
+//
+//	ca := tlsconfigtest.NewCA(t)
+//	server := ca.Issue(t, "localhost")
+//	certFile, keyFile := server.WriteFiles(t, t.TempDir())
+package tlsconfigtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serial numbers the certificates issued in a test run.
+var serial atomic.Int64
+
+// CA is a certificate authority.
+type CA struct {
+	Certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// NewCA creates a self-signed certificate authority.
+func NewCA(t *testing.T) *CA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial.Add(1)),
+		Subject:               pkix.Name{CommonName: "tlsconfigtest CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(2, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.Nil(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return &CA{Certificate: certificate, key: key}
+}
+
+// Pool returns a pool trusting the CA.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate)
+	return pool
+}
+
+// PEM encodes the certificate of the CA.
+func (ca *CA) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate.Raw})
+}
+
+// Issue creates a certificate for client and server authentication. The subject has the common name and the
+// organizations, the certificate is valid for a year for 127.0.0.1 and localhost.
+func (ca *CA) Issue(t *testing.T, commonName string, organizations ...string) Certificate {
+	return ca.IssueValid(t, time.Now().AddDate(1, 0, 0), commonName, organizations...)
+}
+
+// IssueValid is Issue with a certificate that expires at notAfter.
+func (ca *CA) IssueValid(t *testing.T, notAfter time.Time, commonName string, organizations ...string) Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial.Add(1)),
+		Subject:      pkix.Name{CommonName: commonName, Organization: organizations},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, key.Public(), ca.key)
+	require.Nil(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return Certificate{Leaf: leaf, key: key}
+}
+
+// Certificate is a certificate issued by a CA together with its private key.
+type Certificate struct {
+	Leaf *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// TLS returns the certificate for a tls.Config.
+func (c Certificate) TLS() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.Leaf.Raw}, PrivateKey: c.key, Leaf: c.Leaf}
+}
+
+// WriteFiles writes the certificate and key as PEM files cert.pem and key.pem to dir.
+func (c Certificate) WriteFiles(t *testing.T, dir string) (certFile string, keyFile string) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(c.key)
+	require.Nil(t, err)
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Leaf.Raw}), 0o600))
+	require.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
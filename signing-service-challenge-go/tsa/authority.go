@@ -0,0 +1,254 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// DefaultPolicy is the TSA policy of the built-in authority, the example policy of the OpenSSL TSA
+// configuration. Authorities operated for third parties have to use a registered policy.
+var DefaultPolicy = asn1.ObjectIdentifier{1, 2, 3, 4, 1}
+
+// certificateValidity is the validity period of the self-signed authority certificate.
+const certificateValidity = 10 * 365 * 24 * time.Hour
+
+// Authority is a local Time-Stamp Authority (RFC 3161). It signs timestamp tokens with its own ECDSA key and a
+// self-signed certificate restricted to time stamping. The key only lives in memory.
+type Authority struct {
+	Policy asn1.ObjectIdentifier
+	// Clock provides the generation time of tokens.
+	Clock clock.Clock
+
+	key         *ecdsa.PrivateKey
+	certificate *x509.Certificate
+
+	mu     sync.Mutex
+	serial *big.Int
+}
+
+// NewAuthority generates the key and certificate of a local Time-Stamp Authority.
+func NewAuthority() (*Authority, error) {
+	generator := crypto.ECCGenerator{}
+	keyPair, err := generator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("NewAuthority generate key | %w", err)
+	}
+
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidKeyPurposeTimeStamping})
+	if err != nil {
+		return nil, fmt.Errorf("NewAuthority | %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("NewAuthority serial | %w", err)
+	}
+	now := clock.System.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "Signing Service Local TSA"},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		// RFC 3161 Section 2.3 requires time stamping as the only, critical extended key usage
+		ExtraExtensions: []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: eku}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, keyPair.Public, keyPair.Private)
+	if err != nil {
+		return nil, fmt.Errorf("NewAuthority create certificate | %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("NewAuthority parse certificate | %w", err)
+	}
+
+	return &Authority{
+		Policy:      DefaultPolicy,
+		Clock:       clock.System,
+		key:         keyPair.Private,
+		certificate: certificate,
+		serial:      big.NewInt(0),
+	}, nil
+}
+
+// Certificate returns the certificate of the authority to verify its tokens.
+func (a *Authority) Certificate() *x509.Certificate {
+	return a.certificate
+}
+
+// CertificatePEM returns the PEM encoded certificate of the authority.
+func (a *Authority) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.certificate.Raw})
+}
+
+// Timestamp requests a token for data from the authority without a network round trip. The local authority
+// does not block, ctx is only checked before the token is created.
+func (a *Authority) Timestamp(ctx context.Context, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("Authority Timestamp | %w", err)
+	}
+	request, err := NewRequest(data)
+	if err != nil {
+		return nil, fmt.Errorf("Authority Timestamp | %w", err)
+	}
+	token, err := ParseResponse(request, a.Respond(request))
+	if err != nil {
+		return nil, fmt.Errorf("Authority Timestamp | %w", err)
+	}
+	return token, nil
+}
+
+// Respond answers a DER encoded TimeStampReq with a DER encoded TimeStampResp. Invalid requests are answered
+// with a rejection as RFC 3161 requires.
+func (a *Authority) Respond(request []byte) []byte {
+	req := timeStampReq{}
+	rest, err := asn1.Unmarshal(request, &req)
+	if err != nil || len(rest) > 0 || req.Version != 1 {
+		return rejection(failureBadDataFormat, "invalid request")
+	}
+	hash, err := hashFromOID(req.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return rejection(failureBadAlg, "unsupported hash algorithm")
+	}
+	if len(req.MessageImprint.HashedMessage) != hash.Size() {
+		return rejection(failureBadDataFormat, "invalid message imprint")
+	}
+	if len(req.ReqPolicy) > 0 && !req.ReqPolicy.Equal(a.Policy) {
+		return rejection(failureBadRequest, "unsupported policy")
+	}
+
+	token, err := a.sign(req)
+	if err != nil {
+		// the response cannot carry the cause, the request itself was fine
+		return rejection(failureBadRequest, "timestamp creation failed")
+	}
+	resp, err := asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: statusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+	if err != nil {
+		return rejection(failureBadRequest, "timestamp creation failed")
+	}
+	return resp
+}
+
+// sign creates the DER encoded TimeStampToken for a validated request.
+func (a *Authority) sign(req timeStampReq) ([]byte, error) {
+	a.mu.Lock()
+	serial := new(big.Int).Add(a.serial, big.NewInt(1))
+	a.serial = serial
+	a.mu.Unlock()
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         a.Policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        a.Clock.Now().UTC().Truncate(time.Second),
+		Nonce:          req.Nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Authority sign tst info | %w", err)
+	}
+
+	signedAttrs, err := a.signedAttributes(info)
+	if err != nil {
+		return nil, fmt.Errorf("Authority sign | %w", err)
+	}
+	// the signature covers the attributes with the universal SET tag instead of the implicit [0] tag
+	toBeSigned, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttrs})
+	if err != nil {
+		return nil, fmt.Errorf("Authority sign | %w", err)
+	}
+	digest := sha512.Sum384(toBeSigned)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("Authority sign | %w", err)
+	}
+
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA384}},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidContentTypeTSTInfo, EContent: info},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			SID: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: a.certificate.RawIssuer},
+				SerialNumber: a.certificate.SerialNumber,
+			},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA384},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA384},
+			Signature:          signature,
+		}},
+	}
+	if req.CertReq {
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: a.certificate.Raw}
+	}
+	content, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("Authority sign signed data | %w", err)
+	}
+	token, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Authority sign content info | %w", err)
+	}
+	return token, nil
+}
+
+// signedAttributes returns the DER encoded content of the signed attributes SET for the TSTInfo.
+func (a *Authority) signedAttributes(info []byte) ([]byte, error) {
+	infoDigest := sha512.Sum384(info)
+	certHash := sha256.Sum256(a.certificate.Raw)
+	values := []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidAttributeContentType, oidContentTypeTSTInfo},
+		{oidAttributeMessageDigest, infoDigest[:]},
+		{oidAttributeSigningCertV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}},
+	}
+
+	attributes := make([][]byte, len(values))
+	for i, v := range values {
+		value, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, fmt.Errorf("signedAttributes | %w", err)
+		}
+		attributes[i], err = asn1.Marshal(attribute{Type: v.oid, Values: []asn1.RawValue{{FullBytes: value}}})
+		if err != nil {
+			return nil, fmt.Errorf("signedAttributes | %w", err)
+		}
+	}
+	// DER requires the elements of a SET OF to be sorted by their encoding
+	sort.Slice(attributes, func(i, j int) bool { return bytes.Compare(attributes[i], attributes[j]) < 0 })
+	return bytes.Join(attributes, nil), nil
+}
+
+// rejection returns a DER encoded TimeStampResp rejecting the request.
+func rejection(failure int, reason string) []byte {
+	failInfo := asn1.BitString{Bytes: []byte{0x80 >> uint(failure)}, BitLength: failure + 1}
+	statusString := []asn1.RawValue{{Tag: asn1.TagUTF8String, Bytes: []byte(reason)}}
+	resp, _ := asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{Status: statusRejection, StatusString: statusString, FailInfo: failInfo},
+	})
+	return resp
+}
@@ -0,0 +1,112 @@
+package tsa
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuthority(t *testing.T) {
+	authority, err := NewAuthority()
+	require.Nil(t, err)
+
+	certificate := authority.Certificate()
+	assert.Equal(t, x509.ECDSA, certificate.PublicKeyAlgorithm)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}, certificate.ExtKeyUsage)
+	for _, extension := range certificate.Extensions {
+		if extension.Id.Equal(oidExtKeyUsage) {
+			assert.True(t, extension.Critical, "extended key usage has to be critical")
+		}
+	}
+	assert.Contains(t, string(authority.CertificatePEM()), "BEGIN CERTIFICATE")
+}
+
+func TestRespond(t *testing.T) {
+	authority, err := NewAuthority()
+	require.Nil(t, err)
+	genTime := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	authority.Clock = clock.NewFake(genTime, 0)
+
+	t.Run("granted", func(t *testing.T) {
+		request, err := NewRequest([]byte("data"))
+		require.Nil(t, err)
+
+		token, err := ParseResponse(request, authority.Respond(request))
+		require.Nil(t, err)
+		info, err := Verify(token, []byte("data"))
+		require.Nil(t, err)
+		assert.Equal(t, genTime, info.GenTime)
+		assert.Equal(t, DefaultPolicy, info.Policy)
+		assert.Equal(t, authority.Certificate().Raw, info.Certificate.Raw)
+	})
+	t.Run("serial numbers increase", func(t *testing.T) {
+		first, err := authority.Timestamp(context.Background(), []byte("data"))
+		require.Nil(t, err)
+		second, err := authority.Timestamp(context.Background(), []byte("data"))
+		require.Nil(t, err)
+
+		firstInfo, err := Verify(first, []byte("data"))
+		require.Nil(t, err)
+		secondInfo, err := Verify(second, []byte("data"))
+		require.Nil(t, err)
+		assert.Equal(t, 1, secondInfo.SerialNumber.Cmp(firstInfo.SerialNumber))
+	})
+
+	reject := func(t *testing.T, request timeStampReq) {
+		raw, err := asn1.Marshal(request)
+		require.Nil(t, err)
+		_, err = ParseResponse(raw, authority.Respond(raw))
+		assert.ErrorContains(t, err, "rejected")
+	}
+	imprint := messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		HashedMessage: hashOf(RequestHash, []byte("data")),
+	}
+	t.Run("invalid request", func(t *testing.T) {
+		_, err := ParseResponse([]byte("no request"), authority.Respond([]byte("no request")))
+		assert.ErrorContains(t, err, "invalid request")
+	})
+	t.Run("unsupported version", func(t *testing.T) {
+		reject(t, timeStampReq{Version: 2, MessageImprint: imprint})
+	})
+	t.Run("unsupported hash", func(t *testing.T) {
+		sha1 := messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+			HashedMessage: make([]byte, 20),
+		}
+		reject(t, timeStampReq{Version: 1, MessageImprint: sha1})
+	})
+	t.Run("invalid imprint", func(t *testing.T) {
+		short := imprint
+		short.HashedMessage = short.HashedMessage[:16]
+		reject(t, timeStampReq{Version: 1, MessageImprint: short})
+	})
+	t.Run("unknown policy", func(t *testing.T) {
+		reject(t, timeStampReq{Version: 1, MessageImprint: imprint, ReqPolicy: asn1.ObjectIdentifier{1, 2, 3}})
+	})
+	t.Run("nonce", func(t *testing.T) {
+		raw, err := asn1.Marshal(timeStampReq{Version: 1, MessageImprint: imprint, Nonce: big.NewInt(42), CertReq: true})
+		require.Nil(t, err)
+		token, err := ParseResponse(raw, authority.Respond(raw))
+		require.Nil(t, err)
+
+		ci := contentInfo{}
+		_, err = asn1.Unmarshal(token, &ci)
+		require.Nil(t, err)
+		sd := signedData{}
+		_, err = asn1.Unmarshal(ci.Content.Bytes, &sd)
+		require.Nil(t, err)
+		info := tstInfo{}
+		_, err = asn1.Unmarshal(sd.EncapContentInfo.EContent, &info)
+		require.Nil(t, err)
+		assert.Equal(t, big.NewInt(42), info.Nonce)
+	})
+}
@@ -0,0 +1,160 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Media types of RFC 3161 Section 3.4.
+const (
+	ContentTypeQuery = "application/timestamp-query"
+	ContentTypeReply = "application/timestamp-reply"
+)
+
+// RequestHash is the hash algorithm of the message imprints requested by this package.
+const RequestHash = crypto.SHA256
+
+// maxResponseSize limits the size of responses read from a TSA.
+const maxResponseSize = 1 << 20
+
+// DefaultTimeout limits requests of an HTTPClient without own http.Client.
+const DefaultTimeout = 10 * time.Second
+
+// defaultHTTPClient is used by HTTPClient if HTTP is nil.
+var defaultHTTPClient = &http.Client{Timeout: DefaultTimeout}
+
+// Client obtains RFC 3161 timestamp tokens. Timestamp returns the DER encoded TimeStampToken for data.
+type Client interface {
+	Timestamp(ctx context.Context, data []byte) ([]byte, error)
+}
+
+var (
+	_ Client = (*Authority)(nil)
+	_ Client = HTTPClient{}
+)
+
+// HTTPClient requests timestamp tokens from an external TSA via HTTP (RFC 3161 Section 3.4).
+type HTTPClient struct {
+	URL string
+	// HTTP sends the requests, a client with DefaultTimeout if nil.
+	HTTP *http.Client
+}
+
+// Timestamp posts a request for data to the TSA and returns the token of the response. The request is
+// canceled with ctx.
+func (c HTTPClient) Timestamp(ctx context.Context, data []byte) ([]byte, error) {
+	request, err := NewRequest(data)
+	if err != nil {
+		return nil, fmt.Errorf("HTTPClient Timestamp | %w", err)
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("HTTPClient Timestamp | %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", ContentTypeQuery)
+	resp, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("HTTPClient Timestamp | %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTPClient Timestamp | unexpected status: %s", resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), ContentTypeReply) {
+		return nil, fmt.Errorf("HTTPClient Timestamp | unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("HTTPClient Timestamp | %w", err)
+	}
+	token, err := ParseResponse(request, body)
+	if err != nil {
+		return nil, fmt.Errorf("HTTPClient Timestamp | %w", err)
+	}
+	return token, nil
+}
+
+// NewRequest creates a DER encoded TimeStampReq for the SHA-256 hash of data. The request asks for the TSA
+// certificate to be included in the token and carries a random nonce.
+func NewRequest(data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest nonce | %w", err)
+	}
+	request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: hashes[RequestHash]},
+			HashedMessage: hashOf(RequestHash, data),
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest | %w", err)
+	}
+	return request, nil
+}
+
+// ParseResponse returns the DER encoded TimeStampToken of a DER encoded TimeStampResp to the DER encoded
+// TimeStampReq request. Rejections are errors, and so are tokens whose message imprint or nonce differ from the
+// request (RFC 3161 Section 2.4.2). The signature of the token is not checked, see Verify.
+func ParseResponse(request []byte, response []byte) ([]byte, error) {
+	resp := timeStampResp{}
+	rest, err := asn1.Unmarshal(response, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("ParseResponse | %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("ParseResponse | trailing data")
+	}
+	// granted (0) and grantedWithMods (1) carry a token
+	if resp.Status.Status > 1 {
+		reasons := make([]string, len(resp.Status.StatusString))
+		for i, reason := range resp.Status.StatusString {
+			reasons[i] = string(reason.Bytes)
+		}
+		return nil, fmt.Errorf("ParseResponse | rejected with status %d: %s", resp.Status.Status, strings.Join(reasons, ", "))
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("ParseResponse | no token")
+	}
+
+	req := timeStampReq{}
+	if _, err := asn1.Unmarshal(request, &req); err != nil {
+		return nil, fmt.Errorf("ParseResponse request | %w", err)
+	}
+	_, info, err := parseToken(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, fmt.Errorf("ParseResponse | %w", err)
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(req.MessageImprint.HashAlgorithm.Algorithm) ||
+		!bytes.Equal(info.MessageImprint.HashedMessage, req.MessageImprint.HashedMessage) {
+		return nil, fmt.Errorf("ParseResponse | message imprint does not match the request")
+	}
+	if req.Nonce != nil && (info.Nonce == nil || info.Nonce.Cmp(req.Nonce) != 0) {
+		return nil, fmt.Errorf("ParseResponse | nonce does not match the request")
+	}
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// hashOf hashes data with a supported algorithm.
+func hashOf(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,103 @@
+package tsa
+
+import (
+	"context"
+	"encoding/asn1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	authority, err := NewAuthority()
+	require.Nil(t, err)
+	// tsaServer answers with a token of the authority for the request that respond returns for the received one.
+	tsaServer := func(respond func(request []byte) []byte) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != ContentTypeQuery {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			request, err := io.ReadAll(r.Body)
+			require.Nil(t, err)
+			w.Header().Set("Content-Type", ContentTypeReply)
+			w.Write(authority.Respond(respond(request)))
+		}))
+	}
+	ts := tsaServer(func(request []byte) []byte { return request })
+	defer ts.Close()
+
+	t.Run("timestamp", func(t *testing.T) {
+		token, err := HTTPClient{URL: ts.URL}.Timestamp(context.Background(), []byte("data"))
+		require.Nil(t, err)
+		_, err = Verify(token, []byte("data"))
+		assert.Nil(t, err)
+	})
+	t.Run("error status", func(t *testing.T) {
+		failing := httptest.NewServer(http.NotFoundHandler())
+		defer failing.Close()
+
+		_, err := HTTPClient{URL: failing.URL, HTTP: &http.Client{}}.Timestamp(context.Background(), []byte("data"))
+		assert.ErrorContains(t, err, "unexpected status")
+	})
+	t.Run("other message imprint", func(t *testing.T) {
+		other := tsaServer(func([]byte) []byte {
+			request, err := NewRequest([]byte("other data"))
+			require.Nil(t, err)
+			return request
+		})
+		defer other.Close()
+
+		_, err := HTTPClient{URL: other.URL}.Timestamp(context.Background(), []byte("data"))
+		assert.ErrorContains(t, err, "message imprint")
+	})
+	t.Run("other nonce", func(t *testing.T) {
+		replayed := tsaServer(func([]byte) []byte {
+			request, err := NewRequest([]byte("data"))
+			require.Nil(t, err)
+			return request
+		})
+		defer replayed.Close()
+
+		_, err := HTTPClient{URL: replayed.URL}.Timestamp(context.Background(), []byte("data"))
+		assert.ErrorContains(t, err, "nonce")
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := HTTPClient{URL: ts.URL}.Timestamp(ctx, []byte("data"))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("default timeout", func(t *testing.T) {
+		assert.Equal(t, DefaultTimeout, defaultHTTPClient.Timeout)
+	})
+}
+
+func TestParseResponse(t *testing.T) {
+	request, err := NewRequest([]byte("data"))
+	require.Nil(t, err)
+
+	_, err = ParseResponse(request, rejection(failureBadAlg, "unsupported hash algorithm"))
+	assert.ErrorContains(t, err, "unsupported hash algorithm")
+
+	_, err = ParseResponse(request, []byte("no response"))
+	assert.NotNil(t, err)
+
+	t.Run("request without nonce", func(t *testing.T) {
+		req := timeStampReq{}
+		_, err := asn1.Unmarshal(request, &req)
+		require.Nil(t, err)
+		req.Nonce = nil
+		withoutNonce, err := asn1.Marshal(req)
+		require.Nil(t, err)
+
+		authority, err := NewAuthority()
+		require.Nil(t, err)
+		_, err = ParseResponse(withoutNonce, authority.Respond(withoutNonce))
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,150 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// TokenInfo describes a verified timestamp token.
+type TokenInfo struct {
+	GenTime      time.Time
+	SerialNumber *big.Int
+	Policy       asn1.ObjectIdentifier
+	// Certificate is the TSA certificate embedded in the token. Verify does not check whether it is trusted.
+	Certificate *x509.Certificate
+}
+
+// signatureAlgorithms maps the supported CMS signature algorithms to their x509 counterparts.
+var signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}.String():   x509.ECDSAWithSHA256,
+	oidECDSAWithSHA384.String():                                 x509.ECDSAWithSHA384,
+	asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}.String():   x509.ECDSAWithSHA512,
+	asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}.String(): x509.SHA256WithRSA,
+	asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}.String(): x509.SHA384WithRSA,
+	asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}.String(): x509.SHA512WithRSA,
+}
+
+// Verify checks that the DER encoded TimeStampToken covers data and is correctly signed by the TSA certificate
+// embedded in the token. The caller decides whether the returned certificate is trusted.
+func Verify(token []byte, data []byte) (TokenInfo, error) {
+	sd, info, err := parseToken(token)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("Verify | %w", err)
+	}
+	imprintHash, err := hashFromOID(info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("Verify | %w", err)
+	}
+	if !bytes.Equal(hashOf(imprintHash, data), info.MessageImprint.HashedMessage) {
+		return TokenInfo{}, fmt.Errorf("Verify | message imprint does not match data")
+	}
+
+	certificates, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("Verify certificates | %w", err)
+	}
+	if len(certificates) == 0 || len(sd.SignerInfos) != 1 {
+		return TokenInfo{}, fmt.Errorf("Verify | expected one signer with certificate")
+	}
+	certificate := certificates[0]
+	if !hasTimeStampingUsage(certificate) {
+		return TokenInfo{}, fmt.Errorf("Verify | certificate is not valid for time stamping")
+	}
+
+	signer := sd.SignerInfos[0]
+	if err := verifySignedAttributes(signer, sd.EncapContentInfo.EContent); err != nil {
+		return TokenInfo{}, fmt.Errorf("Verify | %w", err)
+	}
+	algorithm, ok := signatureAlgorithms[signer.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return TokenInfo{}, fmt.Errorf("Verify | unsupported signature algorithm: %s", signer.SignatureAlgorithm.Algorithm)
+	}
+	toBeSigned, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: signer.SignedAttrs.Bytes})
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("Verify | %w", err)
+	}
+	if err := certificate.CheckSignature(algorithm, toBeSigned, signer.Signature); err != nil {
+		return TokenInfo{}, fmt.Errorf("Verify signature | %w", err)
+	}
+
+	return TokenInfo{
+		GenTime:      info.GenTime,
+		SerialNumber: info.SerialNumber,
+		Policy:       info.Policy,
+		Certificate:  certificate,
+	}, nil
+}
+
+// parseToken decodes the SignedData of a DER encoded TimeStampToken and the TSTInfo it encapsulates. Signatures
+// are not checked.
+func parseToken(token []byte) (signedData, tstInfo, error) {
+	ci := contentInfo{}
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return signedData{}, tstInfo{}, fmt.Errorf("parseToken content info | %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return signedData{}, tstInfo{}, fmt.Errorf("parseToken | unexpected content type: %s", ci.ContentType)
+	}
+	sd := signedData{}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return signedData{}, tstInfo{}, fmt.Errorf("parseToken signed data | %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidContentTypeTSTInfo) {
+		return signedData{}, tstInfo{}, fmt.Errorf("parseToken | unexpected encapsulated content type: %s", sd.EncapContentInfo.EContentType)
+	}
+	info := tstInfo{}
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &info); err != nil {
+		return signedData{}, tstInfo{}, fmt.Errorf("parseToken tst info | %w", err)
+	}
+	return sd, info, nil
+}
+
+// verifySignedAttributes checks the content type and message digest attributes against the TSTInfo.
+func verifySignedAttributes(signer signerInfo, content []byte) error {
+	digestHash, err := hashFromOID(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return fmt.Errorf("verifySignedAttributes | %w", err)
+	}
+
+	var contentTypeOK, messageDigestOK bool
+	rest := signer.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		attr := attribute{}
+		var err error
+		rest, err = asn1.Unmarshal(rest, &attr)
+		if err != nil {
+			return fmt.Errorf("verifySignedAttributes | %w", err)
+		}
+		if len(attr.Values) != 1 {
+			continue
+		}
+		switch {
+		case attr.Type.Equal(oidAttributeContentType):
+			var contentType asn1.ObjectIdentifier
+			_, err := asn1.Unmarshal(attr.Values[0].FullBytes, &contentType)
+			contentTypeOK = err == nil && contentType.Equal(oidContentTypeTSTInfo)
+		case attr.Type.Equal(oidAttributeMessageDigest):
+			var digest []byte
+			_, err := asn1.Unmarshal(attr.Values[0].FullBytes, &digest)
+			messageDigestOK = err == nil && bytes.Equal(digest, hashOf(digestHash, content))
+		}
+	}
+	if !contentTypeOK || !messageDigestOK {
+		return fmt.Errorf("verifySignedAttributes | content type or message digest mismatch")
+	}
+	return nil
+}
+
+// hasTimeStampingUsage reports whether time stamping is an extended key usage of the certificate.
+func hasTimeStampingUsage(certificate *x509.Certificate) bool {
+	for _, usage := range certificate.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageTimeStamping {
+			return true
+		}
+	}
+	return false
+}
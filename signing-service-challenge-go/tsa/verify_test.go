@@ -0,0 +1,41 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	authority, err := NewAuthority()
+	require.Nil(t, err)
+	token, err := authority.Timestamp(context.Background(), []byte("data"))
+	require.Nil(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		_, err := Verify(token, []byte("data"))
+		assert.Nil(t, err)
+	})
+	t.Run("other data", func(t *testing.T) {
+		_, err := Verify(token, []byte("other"))
+		assert.ErrorContains(t, err, "message imprint")
+	})
+	t.Run("tampered token", func(t *testing.T) {
+		info, err := Verify(token, []byte("data"))
+		require.Nil(t, err)
+		// replace the generation time inside the signed TSTInfo
+		genTime := []byte(info.GenTime.Format("20060102150405Z"))
+		tampered := bytes.Replace(token, genTime, []byte("19700101000000Z"), 1)
+		require.NotEqual(t, token, tampered)
+
+		_, err = Verify(tampered, []byte("data"))
+		assert.NotNil(t, err)
+	})
+	t.Run("no token", func(t *testing.T) {
+		_, err := Verify([]byte("no token"), []byte("data"))
+		assert.NotNil(t, err)
+	})
+}